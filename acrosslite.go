@@ -4,12 +4,17 @@ import (
 	"bytes"
 	"encoding/binary"
 	"errors"
+	"fmt"
 	"io"
+	"regexp"
+	"strconv"
+	"strings"
 )
 
 // AcrossLite holds the parsed data from an AcrossLite (".puz") puzzle file
 type AcrossLite struct {
 	Data      []byte
+	Version   string
 	Cols      int
 	Rows      int
 	Title     []byte
@@ -22,8 +27,20 @@ type AcrossLite struct {
 	CksumCib  uint16
 	CksumMsk  [8]byte
 	CksumScr  uint16
+	Extras    []Extra
 }
 
+// Extra is an extra section from the end of an AcrossLite puzzle file, such as
+// GEXT (cell flags) or LTIM (timer state).
+type Extra struct {
+	Name   string
+	Offset int // The offset of the section's header within the file
+	Data   []byte
+	Cksum  uint16
+}
+
+var BadChecksums = errors.New("checksums aren't correct")
+
 // Sniff looks at the provided data slice and returns a boolean indicating
 // whether it looks like an AcrossLite puzzle.
 func (a *AcrossLite) Sniff(data []byte) bool {
@@ -73,77 +90,47 @@ func (a *AcrossLite) Parse(data []byte) error {
 	a.Data = make([]byte, len(data))
 	copy(a.Data, data)
 
-	buf := bytes.NewBuffer(data)
-
-	// Whole-file checksum
-	err := binary.Read(buf, binary.LittleEndian, &a.CksumFil)
-	if err != nil {
-		return err
+	// The header is read straight from the file data, and the rest through a
+	// buffer over it, so that the grids and strings are slices of a.Data
+	// rather than copies: loading is then a matter of a handful of
+	// allocations, however big the puzzle.
+	d := a.Data
+	if len(d) < 0x34 {
+		if len(d) == 0 {
+			return io.EOF
+		}
+		return io.ErrUnexpectedEOF
 	}
 
-	// Magic bytes
-	buf.Next(12)
+	// Checksums. The magic bytes in between aren't checked.
+	a.CksumFil = binary.LittleEndian.Uint16(d[0x00:])
+	a.CksumCib = binary.LittleEndian.Uint16(d[0x0E:])
+	copy(a.CksumMsk[:], d[0x10:0x18])
 
-	// Checksums
-	err = binary.Read(buf, binary.LittleEndian, &a.CksumCib)
-	if err != nil {
-		return err
-	}
-	err = binary.Read(buf, binary.LittleEndian, &a.CksumMsk)
-	if err != nil {
-		return err
+	// Version string. This has an impact on how the checksum is calculated,
+	// but that's easily dealt with in other ways (see Verify).
+	version := d[0x18:0x1C]
+	if i := bytes.IndexByte(version, 0x0); i >= 0 {
+		version = version[:i]
 	}
-
-	// Version string. We skip this because we just don't care, and while it has
-	// an impact on how the checksum is calculated that's easily dealt with in
-	// other ways (see below).
-	buf.Next(4)
-
-	// Junk (Reserved1C)
-	buf.Next(2)
+	a.Version = string(version)
 
 	// Scrambled puzzle checksum
-	err = binary.Read(buf, binary.LittleEndian, &a.CksumScr)
-	if err != nil {
-		return err
-	}
-
-	// Junk (Reserved20)
-	buf.Next(12)
-
-	// Dimensions
-	var cols, rows byte
-	err = binary.Read(buf, binary.LittleEndian, &cols)
-	if err != nil {
-		return err
-	}
-	err = binary.Read(buf, binary.LittleEndian, &rows)
-	if err != nil {
-		return err
-	}
-	a.Cols = int(cols)
-	a.Rows = int(rows)
-
-	// Number of clues
-	var numClues uint16
-	err = binary.Read(buf, binary.LittleEndian, &numClues)
-	if err != nil {
-		return err
-	}
+	a.CksumScr = binary.LittleEndian.Uint16(d[0x1E:])
 
-	// Junk (Unknown bitmask)
-	buf.Next(2)
+	// Dimensions and number of clues
+	a.Cols = int(d[0x2C])
+	a.Rows = int(d[0x2D])
+	numClues := binary.LittleEndian.Uint16(d[0x2E:])
 
 	// Scrambled tag
-	var scrambled uint16
-	err = binary.Read(buf, binary.LittleEndian, &scrambled)
-	if err != nil {
-		return err
-	}
-	if scrambled != 0x0000 {
+	if binary.LittleEndian.Uint16(d[0x32:]) != 0x0000 {
 		return errors.New("no support yet for scrambled puzzles")
 	}
 
+	buf := bytes.NewBuffer(d[0x34:])
+	var err error
+
 	// Read solution matrix
 	a.Solution, err = readMatrix(buf, a.Rows, a.Cols)
 	if err != nil {
@@ -172,10 +159,10 @@ func (a *AcrossLite) Parse(data []byte) error {
 	a.Clues = make([][]byte, 0)
 	for i := 0; i < int(numClues); i++ {
 		str, err := readBytes(buf)
+		a.Clues = append(a.Clues, str)
 		if err != nil {
 			return err
 		}
-		a.Clues = append(a.Clues, str)
 	}
 
 	a.Notes, err = readBytes(buf)
@@ -185,20 +172,92 @@ func (a *AcrossLite) Parse(data []byte) error {
 		return err
 	}
 
+	a.parseExtras(len(data) - buf.Len())
+
 	// Having verified the structure of the puzzle, now check the checksums.
 	if !a.Verify() {
-		return errors.New("checksums aren't correct")
+		return BadChecksums
 	}
 
-	// TODO: parse extra data
-	//
-	// https://code.google.com/p/puz/wiki/FileFormat#Extra_Sections
-
 	return nil
 }
 
+// parseExtras parses the extra sections starting at the given offset, taking
+// the format from
+//
+//	https://code.google.com/p/puz/wiki/FileFormat#Extra_Sections
+//
+// Component  Length  Type     Description
+// ---------  ------  ----     -----------
+// Title      0x04    string   the name of the section, e.g. "GEXT"
+// Length     0x02    uint16   the length of the data section, in bytes
+// Checksum   0x02    uint16   a checksum of the data section
+// Data       varies  []byte   the data, followed by a NUL
+//
+// Anything that doesn't look like a complete section is ignored.
+func (a *AcrossLite) parseExtras(offset int) {
+	a.Extras = make([]Extra, 0)
+	d := a.Data[offset:]
+	for len(d) >= 8 {
+		n := int(binary.LittleEndian.Uint16(d[4:6]))
+		if len(d) < 8+n+1 {
+			return
+		}
+		a.Extras = append(a.Extras, Extra{
+			Name:   string(d[0:4]),
+			Offset: offset,
+			Data:   d[8 : 8+n],
+			Cksum:  binary.LittleEndian.Uint16(d[6:8]),
+		})
+		d = d[8+n+1:]
+		offset += 8 + n + 1
+	}
+}
+
 // Verify checks that the puzzle's checksums are valid.
 func (a *AcrossLite) Verify() bool {
+	c := a.checksums()
+	return c.cib == a.CksumCib && c.file == a.CksumFil && c.masked() == a.CksumMsk
+}
+
+// checksumSet holds the checksums computed from the puzzle data.
+type checksumSet struct {
+	cib  uint16 // The header fields from the width onwards
+	file uint16 // The whole file
+	sol  uint16 // The solution grid
+	grid uint16 // The cell grid
+	part uint16 // The strings
+}
+
+// masked returns the masked checksums as stored in the header.
+func (c checksumSet) masked() [8]byte {
+	// ICHEATED. Oh ho ho. Very funny.
+	return [8]byte{
+		0x49 ^ byte(c.cib&0xFF),
+		0x43 ^ byte(c.sol&0xFF),
+		0x48 ^ byte(c.grid&0xFF),
+		0x45 ^ byte(c.part&0xFF),
+		0x41 ^ byte((c.cib&0xFF00)>>8),
+		0x54 ^ byte((c.sol&0xFF00)>>8),
+		0x45 ^ byte((c.grid&0xFF00)>>8),
+		0x44 ^ byte((c.part&0xFF00)>>8),
+	}
+}
+
+// checksums computes the puzzle's checksums from its data.
+func (a *AcrossLite) checksums() checksumSet {
+	// The notes field is only included in the file and masked checksums from
+	// 1.3 onwards. In older versions (1.2 and 1.2c are the only ones I've seen)
+	// the file checksum should be correct without it, so we only include it if
+	// that fails.
+	c := a.computeChecksums(false)
+	if c.file != a.CksumFil && len(a.Notes) > 0 {
+		c = a.computeChecksums(true)
+	}
+	return c
+}
+
+func (a *AcrossLite) computeChecksums(withNotes bool) checksumSet {
 	size := a.Cols * a.Rows
 
 	dCib := a.Data[0x2c : 0x2c+8]
@@ -206,9 +265,6 @@ func (a *AcrossLite) Verify() bool {
 	dGrid := a.Data[0x34+size : 0x34+size+size]
 	// 8 bytes starting at the puzzle width
 	cCib := cksum(dCib, 0x0000)
-	if cCib != a.CksumCib {
-		return false
-	}
 
 	// Whole-file checksum
 	cFile := cCib
@@ -253,84 +309,660 @@ func (a *AcrossLite) Verify() bool {
 		c += len(clue) + 1
 	}
 
-	// The notes field is only included in the file and masked checksums from
-	// 1.3 onwards. In older versions (1.2 and 1.2c are the only ones I've seen)
-	// the file checksum should be correct at this point, so we won't enter this
-	// conditional in those cases.
-	if cFile != a.CksumFil && len(a.Notes) > 0 {
-		cFile = cksum(d[c:c+len(a.Notes)+1], cFile)
-		cPart = cksum(d[c:c+len(a.Notes)+1], cPart)
+	if withNotes && len(a.Notes) > 0 {
+		end := c + len(a.Notes) + 1
+		if end > len(d) {
+			end = len(d)
+		}
+		cFile = cksum(d[c:end], cFile)
+		cPart = cksum(d[c:end], cPart)
 	}
 
-	if cFile != a.CksumFil {
-		return false
+	return checksumSet{cib: cCib, file: cFile, sol: cSol, grid: cGrid, part: cPart}
+}
+
+// ChecksumReport compares a checksum stored in an AcrossLite file with the
+// value computed from its contents.
+type ChecksumReport struct {
+	Name     string
+	Stored   uint16
+	Computed uint16
+}
+
+// Checksums reports every checksum in the file: the header and whole-file
+// checksums, each of the four masked checksums (unmasked), and the checksum
+// of each extra section.
+func (a *AcrossLite) Checksums() []ChecksumReport {
+	c := a.checksums()
+	m := a.CksumMsk
+	stored := func(lo, hi int, klo, khi byte) uint16 {
+		return uint16(m[lo]^klo) | uint16(m[hi]^khi)<<8
 	}
+	reports := []ChecksumReport{
+		{"CIB", a.CksumCib, c.cib},
+		{"File", a.CksumFil, c.file},
+		{"Masked CIB", stored(0, 4, 0x49, 0x41), c.cib},
+		{"Masked solution", stored(1, 5, 0x43, 0x54), c.sol},
+		{"Masked grid", stored(2, 6, 0x48, 0x45), c.grid},
+		{"Masked strings", stored(3, 7, 0x45, 0x44), c.part},
+	}
+	for _, e := range a.Extras {
+		reports = append(reports, ChecksumReport{e.Name, e.Cksum, cksum(e.Data, 0x0000)})
+	}
+	return reports
+}
 
-	// ICHEATED. Oh ho ho. Very funny.
-	cMasked := [8]byte{
-		0x49 ^ byte(cCib&0xFF),
-		0x43 ^ byte(cSol&0xFF),
-		0x48 ^ byte(cGrid&0xFF),
-		0x45 ^ byte(cPart&0xFF),
-		0x41 ^ byte((cCib&0xFF00)>>8),
-		0x54 ^ byte((cSol&0xFF00)>>8),
-		0x45 ^ byte((cGrid&0xFF00)>>8),
-		0x44 ^ byte((cPart&0xFF00)>>8),
+// ClueCountError is returned when an AcrossLite file doesn't have as many
+// clues as its grid needs.
+type ClueCountError struct {
+	Want int // The number of clues the grid needs
+	Got  int // The number of clues in the file
+
+	// Filled in by Recover: the entries left without a clue, and the clues
+	// from the file that couldn't be placed.
+	Unfilled []Entry
+	Unplaced []string
+}
+
+func (e *ClueCountError) Error() string {
+	return fmt.Sprintf("the file has %d clues, but the grid needs %d", e.Got, e.Want)
+}
+
+// Load loads data from the parsed AcrossLite puzzle into the provided Puzzle
+// object. If the file has the wrong number of clues for its grid, the clues
+// are left blank and a *ClueCountError is returned (see Recover).
+func (a *AcrossLite) Load(p *Puzzle) error {
+	a.loadMetadata(p)
+	a.loadGrid(p)
+	want := len(p.cluesAcross) + len(p.cluesDown)
+	if len(a.Clues) != want {
+		return &ClueCountError{Want: want, Got: len(a.Clues)}
 	}
+	a.loadClues(p)
+	return nil
+}
 
-	if cMasked != a.CksumMsk {
-		return false
+// Recover loads data into the provided Puzzle as Load does, but when the file
+// has the wrong number of clues it places as many as it can rather than none.
+// Clues are matched to entries in order, using the enumerations at the ends of
+// the clues, where present, to work out which entries are missing clues and
+// which clues are surplus. Within a run of entries of the same length there's
+// no telling where a clue went missing, and the gap is left at the end of the
+// run. The unfilled entries and surplus clues are reported in the returned
+// error, which is nil if the clue count was correct.
+func (a *AcrossLite) Recover(p *Puzzle) *ClueCountError {
+	a.loadMetadata(p)
+	a.loadGrid(p)
+	order := clueOrder(p)
+	if len(a.Clues) == len(order) {
+		a.loadClues(p)
+		return nil
 	}
 
-	return true
+	entries := make(map[clueKey]Entry)
+	for _, e := range p.Entries() {
+		entries[clueKey{e.Direction, e.Num}] = e
+	}
+	entryLens := make([]int, len(order))
+	for i, ref := range order {
+		entryLens[i] = len(entries[clueKey{ref.dir, ref.clue.Num}].Cells)
+	}
+	clueLens := make([]int, len(a.Clues))
+	for i, clue := range a.Clues {
+		clueLens[i] = enumerationLength(asString(clue))
+	}
+
+	cerr := &ClueCountError{Want: len(order), Got: len(a.Clues)}
+	placed := make([]bool, len(order))
+	for i, k := range alignClues(clueLens, entryLens) {
+		if k < 0 {
+			cerr.Unplaced = append(cerr.Unplaced, asString(a.Clues[i]))
+			continue
+		}
+		order[k].clue.Clue = asString(a.Clues[i])
+		placed[k] = true
+	}
+	for k, ref := range order {
+		if !placed[k] {
+			cerr.Unfilled = append(cerr.Unfilled, entries[clueKey{ref.dir, ref.clue.Num}])
+		}
+	}
+	return cerr
 }
 
-// Load loads data from the parsed AcrossLite puzzle into the provided Puzzle
-// object.
-func (a *AcrossLite) Load(p *Puzzle) {
-	p.Rows = a.Rows
-	p.Cols = a.Cols
+func (a *AcrossLite) loadMetadata(p *Puzzle) {
 	p.Title = asString(a.Title)
 	p.Author = asString(a.Author)
 	p.Copyright = asString(a.Copyright)
 	p.Notes = asString(a.Notes)
+}
+
+func (a *AcrossLite) loadGrid(p *Puzzle) {
+	p.Rows = a.Rows
+	p.Cols = a.Cols
 	solution := make([]string, len(a.Solution))
 	for i, s := range a.Solution {
 		solution[i] = asString(s)
 	}
 	p.SetSolution(solution)
-	a.loadClues(p)
+	for c, rebus := range a.rebus() {
+		p.SetRebus(c/a.Cols, c%a.Cols, rebus)
+	}
+}
+
+// rebus returns the solutions of the rebus cells, by cell index, as given by
+// the GRBS section (marking each rebus cell with one more than its key in the
+// table) and the RTBL section (the table of solutions, in the form
+// " 1:HEART; 2:CLUB;").
+func (a *AcrossLite) rebus() map[int]string {
+	grbs, rtbl := a.extra("GRBS"), a.extra("RTBL")
+	if grbs == nil || rtbl == nil {
+		return nil
+	}
+	table := make(map[int]string)
+	for _, item := range strings.Split(asString(rtbl.Data), ";") {
+		i := strings.IndexByte(item, ':')
+		if i < 0 {
+			continue
+		}
+		key, err := strconv.Atoi(strings.TrimSpace(item[:i]))
+		if err != nil {
+			continue
+		}
+		table[key] = item[i+1:]
+	}
+	rebus := make(map[int]string)
+	for c, b := range grbs.Data {
+		if c >= a.Rows*a.Cols {
+			break
+		}
+		if solution, ok := table[int(b)-1]; b != 0 && ok && solution != "" {
+			rebus[c] = solution
+		}
+	}
+	return rebus
 }
 
 func (a *AcrossLite) loadClues(p *Puzzle) {
+	for i, ref := range clueOrder(p) {
+		ref.clue.Clue = asString(a.Clues[i])
+	}
+}
+
+// Store copies the metadata and clues of p back into the AcrossLite puzzle,
+// and rebuilds the file data with all the checksums recalculated. Everything
+// else in the file (the grids, the reserved header fields and any extra
+// sections) is preserved byte for byte. The grid of p must be the one that
+// was loaded from this file, and it can't have custom entries, which the
+// format has no way to represent.
+func (a *AcrossLite) Store(p *Puzzle) error {
+	if p.HasCustomEntries() {
+		return errors.New("AcrossLite files can't hold custom entries")
+	}
+	if p.Rows != a.Rows || p.Cols != a.Cols {
+		return errors.New("puzzle dimensions don't match the AcrossLite file")
+	}
+	rebus := a.rebus()
+	for i, row := range a.Solution {
+		for j := range row {
+			cell := p.cells[i*p.Cols+j]
+			want, ok := rebus[i*a.Cols+j]
+			if !ok {
+				want = asString(row[j : j+1])
+			}
+			if cell.Black != (row[j] == P_BLACK[0]) || !cell.Black && cell.Solution != want {
+				return fmt.Errorf("puzzle grid doesn't match the AcrossLite file (at row %d, column %d)", i+1, j+1)
+			}
+		}
+	}
+
+	title, err := asBytes(p.Title)
+	if err != nil {
+		return err
+	}
+	author, err := asBytes(p.Author)
+	if err != nil {
+		return err
+	}
+	copyright, err := asBytes(p.Copyright)
+	if err != nil {
+		return err
+	}
+	notes, err := asBytes(p.Notes)
+	if err != nil {
+		return err
+	}
+	order := clueOrder(p)
+	clues := make([][]byte, len(order))
+	for i, ref := range order {
+		clues[i], err = asBytes(ref.clue.Clue)
+		if err != nil {
+			return fmt.Errorf("%d %s: %v", ref.clue.Num+1, ref.dir, err)
+		}
+	}
+
+	// Work out what to keep from the end of the file, and whether the notes
+	// belong in the checksums, before anything changes.
+	// Some files end without a NUL after the notes, in which case neither
+	// does the new one.
+	end := a.stringsEnd()
+	terminated := end <= len(a.Data)
+	if !terminated {
+		end = len(a.Data)
+	}
+	tail := a.Data[end:]
+	withNotes := a.notesChecksummed()
+
+	a.Title, a.Author, a.Copyright, a.Notes, a.Clues = title, author, copyright, notes, clues
+
+	size := a.Rows * a.Cols
+	data := make([]byte, 0, len(a.Data))
+	data = append(data, a.Data[:0x34+size+size]...)
+	binary.LittleEndian.PutUint16(data[0x2e:], uint16(len(a.Clues)))
+	for _, str := range [][]byte{a.Title, a.Author, a.Copyright} {
+		data = append(append(data, str...), 0x0)
+	}
+	for _, clue := range a.Clues {
+		data = append(append(data, clue...), 0x0)
+	}
+	data = append(data, a.Notes...)
+	if terminated {
+		data = append(data, 0x0)
+	}
+	extras := len(data)
+	data = append(data, tail...)
+
+	a.Data = data
+	a.parseExtras(extras)
+	a.updateChecksums(withNotes)
+	return nil
+}
+
+// notesChecksummed reports whether the notes belong in the file's checksums:
+// always from version 1.3, and in older files only if they were included
+// when the file was written.
+func (a *AcrossLite) notesChecksummed() bool {
+	return a.Version >= "1.3" || (len(a.Notes) > 0 && a.computeChecksums(false).file != a.CksumFil)
+}
+
+// updateChecksums recalculates the checksums and writes them into the file
+// data.
+func (a *AcrossLite) updateChecksums(withNotes bool) {
+	c := a.computeChecksums(withNotes)
+	a.CksumFil, a.CksumCib, a.CksumMsk = c.file, c.cib, c.masked()
+	binary.LittleEndian.PutUint16(a.Data[0x00:], a.CksumFil)
+	binary.LittleEndian.PutUint16(a.Data[0x0e:], a.CksumCib)
+	copy(a.Data[0x10:], a.CksumMsk[:])
+}
+
+// xwdSessionExtra is the name of the extra section in which xwd saves the
+// parts of a solving session that AcrossLite has nowhere to keep. Its data is
+// a single byte of xwdSession flags.
+const xwdSessionExtra = "XWDS"
+
+// xwdSessionAssisted marks a session in which autocheck has been used (see
+// Session.Assisted).
+const xwdSessionAssisted = 0x01
+
+// Session returns the solving session saved in the file: the letters from the
+// player's grid (or from the RUSR section, for rebus cells), the cell flags
+// from the GEXT section, if there is one, and whether the solve was assisted
+// from the XWDS section.
+func (a *AcrossLite) Session() *Session {
+	p := &Puzzle{}
+	a.loadGrid(p)
+	s := NewSession(p)
+	size := a.Rows * a.Cols
+	grid := a.Data[0x34+size : 0x34+size+size]
+	gext := a.extra("GEXT")
+	var rusr [][]byte
+	if x := a.extra("RUSR"); x != nil {
+		rusr = bytes.Split(x.Data, []byte{0x0})
+	}
+	for c := 0; c < size; c++ {
+		if s.black[c] {
+			continue
+		}
+		if grid[c] != '-' && grid[c] != P_BLACK[0] {
+			s.letters[c] = asString(grid[c : c+1])
+		}
+		if c < len(rusr) && len(rusr[c]) > 0 {
+			s.letters[c] = asString(rusr[c])
+		}
+		if gext != nil && c < len(gext.Data) {
+			s.flags[c] = CellFlags(gext.Data[c])
+		}
+	}
+	if x := a.extra(xwdSessionExtra); x != nil && len(x.Data) > 0 {
+		s.assisted = x.Data[0]&xwdSessionAssisted != 0
+	}
+	return s
+}
+
+// StoreSession saves a solving session in the file, writing the letters to
+// the player's grid, the cell flags to the GEXT section, and the whole of
+// any entries of more than one letter (for rebus cells) to the RUSR section,
+// and whether the solve was assisted to the XWDS section, and recalculates
+// the checksums. The extra sections are only added to a file that doesn't
+// have them if they're needed. The session must be for
+// a puzzle of the same size as the file.
+func (a *AcrossLite) StoreSession(s *Session) error {
+	if s.Rows != a.Rows || s.Cols != a.Cols {
+		return errors.New("session dimensions don't match the AcrossLite file")
+	}
+	size := a.Rows * a.Cols
+	grid := make([]byte, size)
+	rusr := make([]byte, 0, size)
+	rebus := false
+	for c := 0; c < size; c++ {
+		switch {
+		case a.Solution[c/a.Cols][c%a.Cols] == P_BLACK[0]:
+			grid[c] = P_BLACK[0]
+		case s.letters[c] == "":
+			grid[c] = '-'
+		default:
+			b, err := asBytes(s.letters[c])
+			if err != nil {
+				return fmt.Errorf("can't store %q (at row %d, column %d) in an AcrossLite file", s.letters[c], c/a.Cols+1, c%a.Cols+1)
+			}
+			grid[c] = b[0]
+			if len(b) > 1 {
+				rusr = append(rusr, b...)
+				rebus = true
+			}
+		}
+		rusr = append(rusr, 0x0)
+	}
+	flags := make([]byte, size)
+	for c, f := range s.flags {
+		flags[c] = byte(f)
+	}
+
+	withNotes := a.notesChecksummed()
+	copy(a.Data[0x34+size:], grid)
+	if a.extra("GEXT") != nil || s.hasFlags() {
+		a.setExtra("GEXT", flags)
+	}
+	if a.extra("RUSR") != nil || rebus {
+		a.setExtra("RUSR", rusr)
+	}
+	if a.extra(xwdSessionExtra) != nil || s.assisted {
+		var flags byte
+		if s.assisted {
+			flags |= xwdSessionAssisted
+		}
+		a.setExtra(xwdSessionExtra, []byte{flags})
+	}
+	a.updateChecksums(withNotes)
+	return nil
+}
+
+// extra returns the extra section with the given name, or nil if there isn't
+// one. Its Data shares the file data.
+func (a *AcrossLite) extra(name string) *Extra {
+	for i := range a.Extras {
+		if a.Extras[i].Name == name {
+			return &a.Extras[i]
+		}
+	}
+	return nil
+}
+
+// setExtra replaces the data of the extra section with the given name, or
+// adds the section after the last of the existing ones (ahead of any
+// unrecognised data at the end of the file) if there isn't one.
+func (a *AcrossLite) setExtra(name string, data []byte) {
+	start := a.stringsEnd()
+	if start > len(a.Data) {
+		// The notes weren't terminated, and the section can't follow them
+		// directly.
+		a.Data = append(a.Data, 0x0)
+	}
+	end := start
+	if x := a.extra(name); x != nil {
+		start, end = x.Offset, x.Offset+8+len(x.Data)+1
+	} else if n := len(a.Extras); n > 0 {
+		last := a.Extras[n-1]
+		start = last.Offset + 8 + len(last.Data) + 1
+		end = start
+	}
+	section := make([]byte, 8, 8+len(data)+1)
+	copy(section, name)
+	binary.LittleEndian.PutUint16(section[4:], uint16(len(data)))
+	binary.LittleEndian.PutUint16(section[6:], cksum(data, 0x0000))
+	section = append(append(section, data...), 0x0)
+
+	rest := append([]byte(nil), a.Data[end:]...)
+	a.Data = append(append(a.Data[:start], section...), rest...)
+	a.parseExtras(a.stringsEnd())
+}
+
+// stringsEnd returns the offset of the end of the strings section, including
+// the NUL after the notes, which may be past the end of the file data.
+func (a *AcrossLite) stringsEnd() int {
+	end := 0x34 + 2*a.Rows*a.Cols
+	for _, str := range [][]byte{a.Title, a.Author, a.Copyright} {
+		end += len(str) + 1
+	}
+	for _, clue := range a.Clues {
+		end += len(clue) + 1
+	}
+	return end + len(a.Notes) + 1
+}
+
+// Write writes the AcrossLite file data to w.
+func (a *AcrossLite) Write(w io.Writer) error {
+	_, err := w.Write(a.Data)
+	return err
+}
+
+// clueKey identifies a clue by direction and (zero-indexed) number.
+type clueKey struct {
+	dir Direction
+	num int
+}
+
+var enumerationRe = regexp.MustCompile(`\((\d+(?:[-,. ]+\d+)*)\)\s*$`)
+
+// enumerationLength returns the total length given by the enumeration at the
+// end of a clue, e.g. 9 for "Flower (4,5)", or 0 if there isn't one.
+func enumerationLength(clue string) int {
+	m := enumerationRe.FindStringSubmatch(clue)
+	if m == nil {
+		return 0
+	}
+	n := 0
+	for _, part := range strings.FieldsFunc(m[1], func(r rune) bool { return r < '0' || r > '9' }) {
+		v, _ := strconv.Atoi(part)
+		n += v
+	}
+	return n
+}
+
+// alignClues matches clues to entries, both in file order, given the length
+// of each entry and the enumerated length of each clue (0 if unknown). A clue
+// can only be matched to an entry of the same length, if its length is known,
+// and matches on known lengths count for more than those on unknown ones. The
+// result gives the index of the entry matched to each clue, or -1 for clues
+// that couldn't be matched. Where there's a choice, earlier clues are matched
+// to earlier entries.
+func alignClues(clueLens, entryLens []int) []int {
+	m, n := len(clueLens), len(entryLens)
+	score := func(i, k int) int {
+		switch clueLens[i] {
+		case 0:
+			return 1
+		case entryLens[k]:
+			return 2
+		}
+		return -1
+	}
+
+	// best[i][k] is the best score for matching clues i... with entries k...
+	best := make([][]int, m+1)
+	for i := range best {
+		best[i] = make([]int, n+1)
+	}
+	for i := m - 1; i >= 0; i-- {
+		for k := n - 1; k >= 0; k-- {
+			b := best[i+1][k]
+			if best[i][k+1] > b {
+				b = best[i][k+1]
+			}
+			if s := score(i, k); s > 0 && s+best[i+1][k+1] > b {
+				b = s + best[i+1][k+1]
+			}
+			best[i][k] = b
+		}
+	}
+
+	match := make([]int, m)
+	i, k := 0, 0
+	for i < m {
+		switch {
+		case k < n && score(i, k) > 0 && best[i][k] == score(i, k)+best[i+1][k+1]:
+			match[i] = k
+			i++
+			k++
+		case k < n && m < n && best[i][k] == best[i][k+1]:
+			k++
+		case best[i][k] == best[i+1][k]:
+			match[i] = -1
+			i++
+		default:
+			k++
+		}
+	}
+	return match
+}
+
+// clueRef refers to one of the clues stored in a Puzzle.
+type clueRef struct {
+	dir  Direction
+	clue *Clue
+}
+
+// clueOrder returns references to the clues of p in the order they're stored
+// in an AcrossLite file: by number, with the across clue first where a square
+// has both an across and a down clue.
+func clueOrder(p *Puzzle) []clueRef {
+	order := make([]clueRef, 0, len(p.cluesAcross)+len(p.cluesDown))
 	aIdx := 0
 	dIdx := 0
 	aMax := len(p.cluesAcross)
 	dMax := len(p.cluesDown)
 
-	for i := 0; i < len(a.Clues); i++ {
+	for aIdx < aMax || dIdx < dMax {
 		if dIdx >= dMax {
 			// We're out of down squares, so this must be an across clue
-			p.cluesAcross[aIdx].Clue = asString(a.Clues[i])
+			order = append(order, clueRef{Across, &p.cluesAcross[aIdx]})
 			aIdx++
 			continue
 		}
 		if aIdx >= aMax {
 			// We're out of across squares, so this must be a down clue
-			p.cluesDown[dIdx].Clue = asString(a.Clues[i])
+			order = append(order, clueRef{Down, &p.cluesDown[dIdx]})
 			dIdx++
 			continue
 		}
 		// Now we pick the next lowest numbered square. If a square has both an
 		// across and a down clue, the across clue comes first.
 		if p.cluesDown[dIdx].Num < p.cluesAcross[aIdx].Num {
-			p.cluesDown[dIdx].Clue = asString(a.Clues[i])
+			order = append(order, clueRef{Down, &p.cluesDown[dIdx]})
 			dIdx++
 		} else {
-			p.cluesAcross[aIdx].Clue = asString(a.Clues[i])
+			order = append(order, clueRef{Across, &p.cluesAcross[aIdx]})
 			aIdx++
 		}
 	}
+	return order
+}
+
+// Field describes a region of an AcrossLite file, as reported by Layout.
+type Field struct {
+	Name   string
+	Offset int
+	Raw    []byte
+	Value  string // A human-readable decoding of the raw bytes
+}
+
+// Layout describes every region of the parsed file, in order: each header
+// field, the solution and cell grids, each string (with its terminating NUL),
+// and the header and data of each extra section. If the file failed to
+// parse, Layout describes as much of it as Parse read before it failed: the
+// regions that start past the end of the data are left out.
+func (a *AcrossLite) Layout() []Field {
+	fields := make([]Field, 0)
+	add := func(name string, offset, n int, value string) int {
+		if offset > len(a.Data) {
+			return offset + n
+		}
+		end := offset + n
+		if end > len(a.Data) {
+			end = len(a.Data)
+		}
+		if offset > end {
+			offset = end
+		}
+		fields = append(fields, Field{Name: name, Offset: offset, Raw: a.Data[offset:end], Value: value})
+		return offset + n
+	}
+	u16 := func(v uint16) string { return fmt.Sprintf("0x%04x", v) }
+	str := func(b []byte) string { return strconv.Quote(asString(b)) }
+
+	add("Checksum", 0x00, 2, u16(a.CksumFil))
+	add("File Magic", 0x02, 12, strconv.Quote(string(a.Data[0x02:0x0d])))
+	add("CIB Checksum", 0x0e, 2, u16(a.CksumCib))
+	add("Masked Checksums", 0x10, 8, fmt.Sprintf("% x", a.CksumMsk))
+	add("Version", 0x18, 4, strconv.Quote(a.Version))
+	add("Reserved1C", 0x1c, 2, "")
+	add("Scrambled Checksum", 0x1e, 2, u16(a.CksumScr))
+	add("Reserved20", 0x20, 12, "")
+	add("Width", 0x2c, 1, strconv.Itoa(a.Cols))
+	add("Height", 0x2d, 1, strconv.Itoa(a.Rows))
+	numClues := ""
+	if len(a.Data) >= 0x30 {
+		// From the header, as a broken file may not have as many
+		numClues = strconv.Itoa(int(binary.LittleEndian.Uint16(a.Data[0x2e:])))
+	}
+	add("Number of Clues", 0x2e, 2, numClues)
+	add("Unknown Bitmask", 0x30, 2, "")
+	add("Scrambled Tag", 0x32, 2, "")
+
+	size := a.Rows * a.Cols
+	rows := make([]string, len(a.Solution))
+	for i, row := range a.Solution {
+		rows[i] = asString(row)
+	}
+	value := strings.Join(rows, " ")
+	if len(a.Solution) < a.Rows {
+		value = fmt.Sprintf("only %d of %d rows", len(a.Solution), a.Rows)
+	}
+	off := add("Solution", 0x34, size, value)
+	off = add("Grid", off, size, "")
+
+	off = add("Title", off, len(a.Title)+1, str(a.Title))
+	off = add("Author", off, len(a.Author)+1, str(a.Author))
+	off = add("Copyright", off, len(a.Copyright)+1, str(a.Copyright))
+
+	labels := make([]string, len(a.Clues))
+	p := &Puzzle{}
+	a.loadGrid(p)
+	for i, ref := range clueOrder(p) {
+		if i < len(labels) {
+			labels[i] = fmt.Sprintf(" (%d %s)", ref.clue.Num+1, ref.dir)
+		}
+	}
+	for i, clue := range a.Clues {
+		off = add(fmt.Sprintf("Clue %d%s", i+1, labels[i]), off, len(clue)+1, str(clue))
+	}
+	add("Notes", off, len(a.Notes)+1, str(a.Notes))
+
+	for _, e := range a.Extras {
+		add(e.Name+" Header", e.Offset, 8, fmt.Sprintf("length %d, checksum %s", len(e.Data), u16(e.Cksum)))
+		add(e.Name+" Data", e.Offset+8, len(e.Data)+1, "")
+	}
+	return fields
 }
 
 func cksum(data []byte, sum uint16) uint16 {
@@ -345,34 +977,64 @@ func cksum(data []byte, sum uint16) uint16 {
 	return sum
 }
 
+// readMatrix reads a grid of bytes. If the data runs out, it returns the rows
+// read in full, along with the error. The rows share the buffer's data.
 func readMatrix(buf *bytes.Buffer, rows, cols int) ([][]byte, error) {
 	matrix := make([][]byte, rows)
 	for i := 0; i < rows; i++ {
-		bytes := make([]byte, cols)
-		err := binary.Read(buf, binary.LittleEndian, &bytes)
-		if err != nil {
-			return nil, err
+		if buf.Len() < cols {
+			if buf.Len() == 0 {
+				return matrix[:i], io.EOF
+			}
+			return matrix[:i], io.ErrUnexpectedEOF
 		}
-		matrix[i] = bytes
+		matrix[i] = buf.Next(cols)[:cols:cols]
 	}
 	return matrix, nil
 }
 
+// readBytes reads a NUL-terminated string, without the NUL. If there's no
+// NUL, it returns the rest of the data, along with io.EOF. The string shares
+// the buffer's data.
 func readBytes(buf *bytes.Buffer) ([]byte, error) {
-	out, err := buf.ReadBytes(0x0)
-	if err != nil {
-		return out, err
+	n := bytes.IndexByte(buf.Bytes(), 0x0)
+	if n < 0 {
+		out := buf.Next(buf.Len())
+		return out[:len(out):len(out)], io.EOF
 	}
-	// Chop NUL off the end
-	return out[:len(out)-1], nil
+	return buf.Next(n + 1)[:n:n], nil
 }
 
 // asString converts an ISO-8859-1 encoded byte slice into a UTF-8 encoded
 // string.
 func asString(b []byte) string {
+	ascii := true
+	for _, c := range b {
+		if c >= 0x80 {
+			ascii = false
+			break
+		}
+	}
+	if ascii {
+		return string(b)
+	}
 	runes := make([]rune, len(b))
 	for i, c := range b {
 		runes[i] = rune(c)
 	}
 	return string(runes)
 }
+
+// asBytes converts a UTF-8 encoded string into an ISO-8859-1 encoded byte
+// slice, as the inverse of asString. It returns an error if the string
+// contains characters that can't be represented.
+func asBytes(s string) ([]byte, error) {
+	b := make([]byte, 0, len(s))
+	for _, r := range s {
+		if r > 0xff {
+			return nil, fmt.Errorf("can't store %q in an AcrossLite file", r)
+		}
+		b = append(b, byte(r))
+	}
+	return b, nil
+}
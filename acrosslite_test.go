@@ -1,6 +1,11 @@
 package xwd
 
-import "testing"
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"testing"
+)
 
 type CksumExample struct {
 	in    []byte
@@ -26,3 +31,391 @@ func testCksum(t *testing.T, ex CksumExample) {
 		t.Errorf("checksum was wrong (expected 0x%04x, got 0x%04x)", ex.out, res)
 	}
 }
+
+func parseFixture(t *testing.T, name string) *AcrossLite {
+	data, err := os.ReadFile("fixtures/" + name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := &AcrossLite{}
+	err = a.Parse(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return a
+}
+
+func TestParseExtras(t *testing.T) {
+	a := parseFixture(t, "version_13.puz")
+	if a.Version != "1.3" {
+		t.Errorf("expectation failure (expected: %v, got: %v)", "1.3", a.Version)
+	}
+	if len(a.Extras) != 1 {
+		t.Fatalf("expectation failure (expected: %v extras, got: %v)", 1, len(a.Extras))
+	}
+	if x := a.Extras[0]; x.Name != "LTIM" || string(x.Data) != "0,1" {
+		t.Errorf("expectation failure (expected: %v %q, got: %v %q)", "LTIM", "0,1", x.Name, x.Data)
+	}
+}
+
+func TestChecksums(t *testing.T) {
+	for _, name := range []string{"version_12.puz", "version_12c.puz", "version_13.puz"} {
+		for _, c := range parseFixture(t, name).Checksums() {
+			if c.Stored != c.Computed {
+				t.Errorf("%s: %s checksum mismatch (expected: 0x%04x, got: 0x%04x)", name, c.Name, c.Stored, c.Computed)
+			}
+		}
+	}
+}
+
+func TestLayout(t *testing.T) {
+	data, err := os.ReadFile("fixtures/version_13.puz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := &AcrossLite{}
+	err = a.Parse(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fields := a.Layout()
+	if fields[0].Name != "Checksum" {
+		t.Errorf("expectation failure (expected: %v, got: %v)", "Checksum", fields[0].Name)
+	}
+	offset := 0
+	for _, f := range fields {
+		if f.Offset != offset {
+			t.Fatalf("%s: expectation failure (expected offset: %v, got: %v)", f.Name, offset, f.Offset)
+		}
+		offset += len(f.Raw)
+	}
+	if offset != len(data) {
+		t.Errorf("expectation failure (expected: %v bytes, got: %v)", len(data), offset)
+	}
+}
+
+func TestLayoutTruncated(t *testing.T) {
+	data, err := os.ReadFile("fixtures/version_13.puz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		data []byte
+		last string // The name of the last field described
+	}{
+		{"in the title", data[:0x34+2*15*15+5], "Title"},
+		{"in the clues", data[:0x34+2*15*15+120], "Clue 2 (1 down)"},
+		{"in the grid", data[:0x34+100], "Solution"},
+	}
+	for _, tt := range tests {
+		a := &AcrossLite{}
+		if err := a.Parse(tt.data); err == nil {
+			t.Errorf("%s: expected error parsing a truncated file", tt.name)
+		}
+		fields := a.Layout()
+		if last := fields[len(fields)-1]; last.Name != tt.last || last.Offset+len(last.Raw) != len(tt.data) {
+			t.Errorf("%s: expectation failure (expected: %s to the end of the data, got: %s at 0x%04x)", tt.name, tt.last, last.Name, last.Offset)
+		}
+	}
+}
+
+func TestStoreUnchanged(t *testing.T) {
+	for _, name := range []string{"version_12.puz", "version_12c.puz", "version_13.puz"} {
+		a := parseFixture(t, name)
+		orig := append([]byte(nil), a.Data...)
+		p := &Puzzle{}
+		a.Load(p)
+		err := a.Store(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(a.Data, orig) {
+			t.Errorf("%s: storing an unchanged puzzle changed the file data", name)
+		}
+	}
+}
+
+func TestStore(t *testing.T) {
+	a := parseFixture(t, "version_13.puz")
+	p := &Puzzle{}
+	a.Load(p)
+	p.Title = "Animalia (corrected)"
+	p.Notes = ""
+	err := p.SetClue(Down, 0, "A new clue for 1 down (5)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = a.Store(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	err = a.Write(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := &AcrossLite{}
+	err = b.Parse(buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := &Puzzle{}
+	b.Load(q)
+	if q.Title != p.Title {
+		t.Errorf("expectation failure (expected: %v, got: %v)", p.Title, q.Title)
+	}
+	if q.Notes != "" {
+		t.Errorf("expectation failure (expected: %q, got: %q)", "", q.Notes)
+	}
+	if clue := q.CluesDown()[0].Clue; clue != "A new clue for 1 down (5)" {
+		t.Errorf("expectation failure (expected: %v, got: %v)", "A new clue for 1 down (5)", clue)
+	}
+	if q.Author != p.Author || q.CluesAcross()[0].Clue != p.CluesAcross()[0].Clue {
+		t.Errorf("unedited fields were changed")
+	}
+	if len(b.Extras) != 1 || string(b.Extras[0].Data) != "0,1" {
+		t.Errorf("extra sections weren't preserved: %v", b.Extras)
+	}
+}
+
+func TestStoreSession(t *testing.T) {
+	for _, name := range []string{"version_12.puz", "version_12c.puz", "version_13.puz"} {
+		a := parseFixture(t, name)
+		extras := len(a.Extras)
+		if a.extra("GEXT") == nil {
+			extras++
+		}
+		p := &Puzzle{}
+		a.Load(p)
+		cells := p.Entries()[0].Cells
+
+		s := a.Session()
+		s.Set(cells[0][0], cells[0][1], "x", true)
+		s.Set(cells[1][0], cells[1][1], "Y", false)
+		err := a.StoreSession(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var buf bytes.Buffer
+		a.Write(&buf)
+		b := &AcrossLite{}
+		err = b.Parse(buf.Bytes())
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if len(b.Extras) != extras {
+			t.Errorf("%s: expectation failure (expected: %v extras, got: %v)", name, extras, len(b.Extras))
+		}
+		got := b.Session()
+		if l := got.Letter(cells[0][0], cells[0][1]); l != "X" || !got.Pencilled(cells[0][0], cells[0][1]) {
+			t.Errorf("%s: expectation failure (expected: pencilled X, got: %q, pencilled %v)", name, l, got.Pencilled(cells[0][0], cells[0][1]))
+		}
+		if l := got.Letter(cells[1][0], cells[1][1]); l != "Y" || got.Pencilled(cells[1][0], cells[1][1]) {
+			t.Errorf("%s: expectation failure (expected: inked Y, got: %q, pencilled %v)", name, l, got.Pencilled(cells[1][0], cells[1][1]))
+		}
+		q := &Puzzle{}
+		b.Load(q)
+		if q.Fingerprint() != p.Fingerprint() {
+			t.Errorf("%s: puzzle changed by storing a session", name)
+		}
+
+		// Using autocheck, even briefly, marks the solve as assisted for
+		// good.
+		if got.Assisted() {
+			t.Errorf("%s: expected an unassisted session", name)
+		}
+		got.SetAutocheck(true)
+		got.SetAutocheck(false)
+		err = b.StoreSession(got)
+		if err != nil {
+			t.Fatal(err)
+		}
+		buf.Reset()
+		b.Write(&buf)
+		c := &AcrossLite{}
+		err = c.Parse(buf.Bytes())
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if !c.Session().Assisted() {
+			t.Errorf("%s: expected the session to stay assisted", name)
+		}
+	}
+}
+
+func TestRebus(t *testing.T) {
+	a := parseFixture(t, "version_13.puz")
+	p := &Puzzle{}
+	a.Load(p)
+	e := p.Entries()[0]
+	first := e.Cells[0]
+	letter := p.cells[first[0]*p.Cols+first[1]].Solution
+
+	grbs := make([]byte, a.Rows*a.Cols)
+	grbs[first[0]*a.Cols+first[1]] = 2
+	a.setExtra("GRBS", grbs)
+	a.setExtra("RTBL", []byte(" 1:"+letter+"EART;"))
+	a.updateChecksums(true)
+
+	var buf bytes.Buffer
+	a.Write(&buf)
+	b := &AcrossLite{}
+	err := b.Parse(buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := &Puzzle{}
+	b.Load(q)
+	if cell, _ := q.Cell(first[0], first[1]); cell.Solution != letter+"EART" {
+		t.Errorf("expectation failure (expected: %v, got: %v)", letter+"EART", cell.Solution)
+	}
+	if answer := q.Entries()[0].Answer; answer != letter+"EART"+e.Answer[1:] {
+		t.Errorf("expectation failure (expected: %v, got: %v)", letter+"EART"+e.Answer[1:], answer)
+	}
+	if err := b.Store(q); err != nil {
+		t.Errorf("storing a rebus puzzle failed: %v", err)
+	}
+
+	// A rebus entered in a session goes in the RUSR section, and only the
+	// whole rebus is right.
+	s := b.Session()
+	s.Set(first[0], first[1], letter+"eart", false)
+	second := e.Cells[1]
+	s.Set(second[0], second[1], "QQ", false)
+	err = b.StoreSession(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf.Reset()
+	b.Write(&buf)
+	c := &AcrossLite{}
+	err = c.Parse(buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.extra("RUSR") == nil {
+		t.Fatalf("expected a RUSR section")
+	}
+	got := c.Session()
+	if l := got.Letter(first[0], first[1]); l != letter+"EART" {
+		t.Errorf("expectation failure (expected: %v, got: %v)", letter+"EART", l)
+	}
+	wrong := got.Check()
+	if len(wrong) != 1 || wrong[0] != second {
+		t.Errorf("expectation failure (expected: %v, got: %v)", [][2]int{second}, wrong)
+	}
+	if !(got.Flags(second[0], second[1])&FlagIncorrect != 0) {
+		t.Errorf("expected incorrect cell to be flagged")
+	}
+}
+
+func TestStoreUnrepresentable(t *testing.T) {
+	a := parseFixture(t, "version_12.puz")
+	p := &Puzzle{}
+	a.Load(p)
+	p.Title = "Snowman ☃"
+	if err := a.Store(p); err == nil {
+		t.Errorf("expected an error storing a title that isn't ISO-8859-1")
+	}
+}
+
+func TestLoadClueCountMismatch(t *testing.T) {
+	a := parseFixture(t, "version_13.puz")
+	a.Clues = a.Clues[1:]
+	err := a.Load(&Puzzle{})
+	cerr, ok := err.(*ClueCountError)
+	if !ok {
+		t.Fatalf("expectation failure (expected: *ClueCountError, got: %v)", err)
+	}
+	if cerr.Want != 32 || cerr.Got != 31 {
+		t.Errorf("expectation failure (expected: 32/31, got: %v/%v)", cerr.Want, cerr.Got)
+	}
+}
+
+type RecoverExample struct {
+	name     string
+	edit     func(clues [][]byte) [][]byte
+	unfilled []string // "<num> <direction>"
+	unplaced []string
+}
+
+var recoverExamples = []RecoverExample{
+	{
+		"missing clue",
+		func(clues [][]byte) [][]byte { return append(clues[:2:2], clues[3:]...) },
+		[]string{"2 down"},
+		nil,
+	},
+	{
+		"surplus clue",
+		func(clues [][]byte) [][]byte {
+			return append(clues[:3:3], append([][]byte{[]byte("Bogus (12)")}, clues[3:]...)...)
+		},
+		nil,
+		[]string{"Bogus (12)"},
+	},
+	{
+		"missing clue without enumerations",
+		func(clues [][]byte) [][]byte {
+			out := make([][]byte, 0, len(clues))
+			for _, c := range clues[:len(clues)-1] {
+				out = append(out, enumerationRe.ReplaceAll(c, nil))
+			}
+			return out
+		},
+		[]string{"28 across"},
+		nil,
+	},
+}
+
+func TestRecover(t *testing.T) {
+	want := &Puzzle{}
+	parseFixture(t, "version_13.puz").Load(want)
+
+	for _, ex := range recoverExamples {
+		a := parseFixture(t, "version_13.puz")
+		a.Clues = ex.edit(a.Clues)
+		p := &Puzzle{}
+		cerr := a.Recover(p)
+		if cerr == nil {
+			t.Errorf("%s: expected a *ClueCountError", ex.name)
+			continue
+		}
+
+		unfilled := make([]string, 0)
+		for _, e := range cerr.Unfilled {
+			unfilled = append(unfilled, fmt.Sprintf("%d %s", e.Num+1, e.Direction))
+		}
+		if fmt.Sprint(unfilled) != fmt.Sprint(ex.unfilled) {
+			t.Errorf("%s: expectation failure (expected unfilled: %v, got: %v)", ex.name, ex.unfilled, unfilled)
+		}
+		if fmt.Sprint(cerr.Unplaced) != fmt.Sprint(ex.unplaced) {
+			t.Errorf("%s: expectation failure (expected unplaced: %v, got: %v)", ex.name, ex.unplaced, cerr.Unplaced)
+		}
+
+		got := p.Entries()
+		for i, e := range want.Entries() {
+			if got[i].Clue != "" && enumerationRe.ReplaceAllString(e.Clue, "") != enumerationRe.ReplaceAllString(got[i].Clue, "") {
+				t.Errorf("%s: %d %s: expectation failure (expected: %v, got: %v)", ex.name, e.Num+1, e.Direction, e.Clue, got[i].Clue)
+			}
+		}
+	}
+}
+
+func TestEnumerationLength(t *testing.T) {
+	examples := map[string]int{
+		"Small cat (7)":         7,
+		"Flower (4,5)":          9,
+		"Well-known (4-5) ":     9,
+		"No enumeration":        0,
+		"Parenthetical (aside)": 0,
+	}
+	for clue, n := range examples {
+		if got := enumerationLength(clue); got != n {
+			t.Errorf("%q: expectation failure (expected: %v, got: %v)", clue, n, got)
+		}
+	}
+}
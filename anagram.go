@@ -0,0 +1,354 @@
+package xwd
+
+import (
+	"sort"
+	"strings"
+)
+
+// letterCounts counts the occurrences of each of the letters A-Z.
+type letterCounts [26]uint8
+
+func countLetters(word string) letterCounts {
+	var c letterCounts
+	for i := 0; i < len(word); i++ {
+		c[word[i]-'A']++
+	}
+	return c
+}
+
+// contains reports whether c has at least as many of each letter as other.
+func (c *letterCounts) contains(other *letterCounts) bool {
+	for i := range c {
+		if other[i] > c[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func (c *letterCounts) sub(other *letterCounts) {
+	for i := range c {
+		c[i] -= other[i]
+	}
+}
+
+func (c *letterCounts) add(other *letterCounts) {
+	for i := range c {
+		c[i] += other[i]
+	}
+}
+
+func (c *letterCounts) empty() bool {
+	return *c == letterCounts{}
+}
+
+// sortedLetters returns the letters of a word in alphabetical order, which is
+// the same for all its anagrams.
+func sortedLetters(word string) string {
+	b := []byte(word)
+	sort.Slice(b, func(i, j int) bool { return b[i] < b[j] })
+	return string(b)
+}
+
+// Anagrammer finds anagrams and other wordplay using the words in a word
+// list.
+type Anagrammer struct {
+	words *WordList
+	byKey map[string][]string // Words indexed by their sorted letters
+}
+
+// NewAnagrammer returns an Anagrammer for the words in a word list.
+func NewAnagrammer(wl *WordList) *Anagrammer {
+	a := &Anagrammer{words: wl, byKey: make(map[string][]string)}
+	for word := range wl.scores {
+		key := sortedLetters(word)
+		a.byKey[key] = append(a.byKey[key], word)
+	}
+	for _, words := range a.byKey {
+		a.sortWords(words)
+	}
+	return a
+}
+
+// sortWords sorts words by score, best first, and then alphabetically.
+func (a *Anagrammer) sortWords(words []string) {
+	sort.Slice(words, func(i, j int) bool {
+		si, sj := a.words.scores[words[i]], a.words.scores[words[j]]
+		if si != sj {
+			return si > sj
+		}
+		return words[i] < words[j]
+	})
+}
+
+// Anagrams returns the single words that are anagrams of the fodder (which
+// may contain spaces and punctuation), best first. The fodder itself is left
+// out.
+func (a *Anagrammer) Anagrams(fodder string) []string {
+	fodder = NormaliseAnswer(fodder)
+	words := make([]string, 0)
+	for _, word := range a.byKey[sortedLetters(fodder)] {
+		if word != fodder {
+			words = append(words, word)
+		}
+	}
+	return words
+}
+
+// AnagramOptions restrict the results of Anagrammer.MultiWord.
+type AnagramOptions struct {
+	MaxWords int   // The most words in an anagram (0 for 3)
+	Lengths  []int // The lengths of the words, in order, e.g. {4, 5} for (4,5)
+	Limit    int   // The most anagrams to return (0 for 100)
+
+	// MaxSteps bounds the search, by the number of words tried in building
+	// up anagrams (0 for 10,000,000), since with a large word list and
+	// long fodder the search can otherwise run for minutes.
+	MaxSteps int
+}
+
+// MultiWord returns anagrams of the fodder made up of one or more words, with
+// the fewest words first and then the best scoring. Unless lengths are given,
+// the words of each anagram are in no particular order, and each combination
+// is only given once. The search stops after opts.MaxSteps steps, so for long
+// fodder the results may be incomplete.
+func (a *Anagrammer) MultiWord(fodder string, opts AnagramOptions) [][]string {
+	if opts.MaxWords <= 0 {
+		opts.MaxWords = 3
+	}
+	if len(opts.Lengths) > 0 {
+		opts.MaxWords = len(opts.Lengths)
+	}
+	if opts.Limit <= 0 {
+		opts.Limit = 100
+	}
+	if opts.MaxSteps <= 0 {
+		opts.MaxSteps = 10000000
+	}
+
+	fodder = NormaliseAnswer(fodder)
+	remaining := countLetters(fodder)
+	total := 0
+	for _, n := range opts.Lengths {
+		total += n
+	}
+	if len(opts.Lengths) > 0 && total != len(fodder) {
+		return nil
+	}
+
+	// Only the words that can be made from the fodder are of any use, tried
+	// longest first so that anagrams with fewer words turn up sooner.
+	type candidate struct {
+		word   string
+		counts letterCounts
+	}
+	cands := make([]candidate, 0)
+	for word := range a.words.scores {
+		if len(word) > len(fodder) || word == fodder {
+			continue
+		}
+		counts := countLetters(word)
+		if remaining.contains(&counts) {
+			cands = append(cands, candidate{word, counts})
+		}
+	}
+	sort.Slice(cands, func(i, j int) bool {
+		if len(cands[i].word) != len(cands[j].word) {
+			return len(cands[i].word) > len(cands[j].word)
+		}
+		si, sj := a.words.scores[cands[i].word], a.words.scores[cands[j].word]
+		if si != sj {
+			return si > sj
+		}
+		return cands[i].word < cands[j].word
+	})
+
+	// Collect more than the limit, so that the best can be picked from them.
+	results := make([][]string, 0)
+	gather := opts.Limit * 10
+	words := make([]string, 0, opts.MaxWords)
+	steps := 0
+	var search func(start int)
+	search = func(start int) {
+		if len(results) >= gather || steps >= opts.MaxSteps {
+			return
+		}
+		if remaining.empty() {
+			results = append(results, append([]string(nil), words...))
+			return
+		}
+		if len(words) == opts.MaxWords {
+			return
+		}
+		if len(opts.Lengths) > 0 {
+			start = 0
+		}
+		for i := start; i < len(cands) && steps < opts.MaxSteps; i++ {
+			steps++
+			c := &cands[i]
+			if len(opts.Lengths) > 0 && len(c.word) != opts.Lengths[len(words)] {
+				continue
+			}
+			if !remaining.contains(&c.counts) {
+				continue
+			}
+			remaining.sub(&c.counts)
+			words = append(words, c.word)
+			search(i)
+			words = words[:len(words)-1]
+			remaining.add(&c.counts)
+		}
+	}
+	search(0)
+
+	score := func(ws []string) int {
+		s := 0
+		for _, w := range ws {
+			s += a.words.scores[w]
+		}
+		return s / len(ws)
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		if len(results[i]) != len(results[j]) {
+			return len(results[i]) < len(results[j])
+		}
+		return score(results[i]) > score(results[j])
+	})
+	if len(results) > opts.Limit {
+		results = results[:opts.Limit]
+	}
+	return results
+}
+
+// LetterBank returns the words of at least minLength letters that can be
+// spelt using only the letters in the bank, each as many times as needed,
+// best first.
+func (a *Anagrammer) LetterBank(bank string, minLength int) []string {
+	var allowed [26]bool
+	for _, r := range NormaliseAnswer(bank) {
+		allowed[r-'A'] = true
+	}
+	words := make([]string, 0)
+	for word := range a.words.scores {
+		if len(word) < minLength {
+			continue
+		}
+		ok := true
+		for i := 0; i < len(word); i++ {
+			if !allowed[word[i]-'A'] {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			words = append(words, word)
+		}
+	}
+	a.sortWords(words)
+	return words
+}
+
+// HiddenWord is a word found hidden in a clue surface.
+type HiddenWord struct {
+	Word     string
+	Start    int  // The byte offset of the hidden word's first letter in the surface
+	End      int  // The byte offset just after its last letter
+	Reversed bool // Whether the word reads backwards in the surface
+}
+
+// Mark returns the surface with the hidden word picked out in square
+// brackets, e.g. "Some pup[pies r]unning".
+func (h HiddenWord) Mark(surface string) string {
+	return surface[:h.Start] + "[" + surface[h.Start:h.End] + "]" + surface[h.End:]
+}
+
+// surfaceLetters returns the letters of a clue surface, in upper case, with
+// the byte offset of each in the surface and the index of the word it's in.
+func surfaceLetters(surface string) (string, []int, []int) {
+	letters := make([]byte, 0, len(surface))
+	offsets := make([]int, 0, len(surface))
+	wordOf := make([]int, 0, len(surface))
+	word, inWord := 0, false
+	for i, r := range surface {
+		upper := strings.ToUpper(string(r))
+		if len(upper) != 1 || upper[0] < 'A' || upper[0] > 'Z' {
+			if inWord && r != '\'' && r != '-' {
+				word++
+				inWord = false
+			}
+			continue
+		}
+		letters = append(letters, upper[0])
+		offsets = append(offsets, i)
+		wordOf = append(wordOf, word)
+		inWord = true
+	}
+	return string(letters), offsets, wordOf
+}
+
+// hiddenAt returns the HiddenWord for the n letters of the surface starting at
+// letter i.
+func hiddenAt(surface, word string, offsets []int, i, n int, reversed bool) HiddenWord {
+	last := offsets[i+n-1]
+	end := last + 1
+	for end < len(surface) && surface[end]&0xc0 == 0x80 {
+		end++
+	}
+	return HiddenWord{Word: word, Start: offsets[i], End: end, Reversed: reversed}
+}
+
+// Hidden looks for the answer hidden in the clue surface, ignoring spaces and
+// punctuation, reading either forwards or backwards.
+func Hidden(surface, answer string) (HiddenWord, bool) {
+	answer = NormaliseAnswer(answer)
+	if answer == "" {
+		return HiddenWord{}, false
+	}
+	letters, offsets, _ := surfaceLetters(surface)
+	if i := strings.Index(letters, answer); i >= 0 {
+		return hiddenAt(surface, answer, offsets, i, len(answer), false), true
+	}
+	if i := strings.Index(letters, reverse(answer)); i >= 0 {
+		return hiddenAt(surface, answer, offsets, i, len(answer), true), true
+	}
+	return HiddenWord{}, false
+}
+
+// HiddenWords returns the words of at least minLength letters that are hidden
+// in the clue surface, forwards or backwards, across more than one of its
+// words (so that the surface words themselves don't count), best first.
+func (a *Anagrammer) HiddenWords(surface string, minLength int) []HiddenWord {
+	letters, offsets, wordOf := surfaceLetters(surface)
+	found := make([]HiddenWord, 0)
+	for i := range letters {
+		for j := i + minLength; j <= len(letters); j++ {
+			if wordOf[i] == wordOf[j-1] {
+				continue
+			}
+			s := letters[i:j]
+			if _, ok := a.words.scores[s]; ok {
+				found = append(found, hiddenAt(surface, s, offsets, i, j-i, false))
+			}
+			if r := reverse(s); r != s {
+				if _, ok := a.words.scores[r]; ok {
+					found = append(found, hiddenAt(surface, r, offsets, i, j-i, true))
+				}
+			}
+		}
+	}
+	sort.SliceStable(found, func(i, j int) bool {
+		if len(found[i].Word) != len(found[j].Word) {
+			return len(found[i].Word) > len(found[j].Word)
+		}
+		return a.words.scores[found[i].Word] > a.words.scores[found[j].Word]
+	})
+	return found
+}
+
+func reverse(s string) string {
+	b := []byte(s)
+	for i, j := 0, len(b)-1; i < j; i, j = i+1, j-1 {
+		b[i], b[j] = b[j], b[i]
+	}
+	return string(b)
+}
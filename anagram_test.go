@@ -0,0 +1,93 @@
+package xwd
+
+import (
+	"fmt"
+	"testing"
+)
+
+func testAnagrammer() *Anagrammer {
+	wl := NewWordList()
+	for word, score := range map[string]int{
+		"LISTEN": 50, "SILENT": 60, "ENLIST": 40, "TINSEL": 50, "INLETS": 30,
+		"DORMITORY": 50, "DIRTY": 50, "ROOM": 50, "DIRT": 40, "MOORY": 10,
+		"STAB": 50, "BATS": 50, "TABS": 50, "BAT": 50, "ST": 10,
+		"PIES": 50, "SEIP": 5, "SNIP": 50, "TOPS": 50, "SPOT": 40, "LEE": 30, "EEL": 50,
+	} {
+		wl.Add(word, score)
+	}
+	return NewAnagrammer(wl)
+}
+
+func TestAnagrams(t *testing.T) {
+	a := testAnagrammer()
+	got := fmt.Sprint(a.Anagrams("Listen!"))
+	expected := "[SILENT TINSEL ENLIST INLETS]"
+	if got != expected {
+		t.Errorf("expectation failure (expected: %v, got: %v)", expected, got)
+	}
+}
+
+func TestMultiWord(t *testing.T) {
+	a := testAnagrammer()
+	examples := []struct {
+		fodder   string
+		opts     AnagramOptions
+		expected string
+	}{
+		{"dormitory", AnagramOptions{}, "[[DIRTY ROOM] [MOORY DIRT]]"},
+		{"dormitory", AnagramOptions{Lengths: []int{4, 5}}, "[[ROOM DIRTY] [DIRT MOORY]]"},
+		{"dormitory", AnagramOptions{Lengths: []int{4, 4}}, "[]"},
+		{"bat stab", AnagramOptions{MaxWords: 2, Limit: 2}, "[[BATS BAT] [STAB BAT]]"},
+		{"bat stab", AnagramOptions{MaxWords: 1}, "[]"},
+		{"dormitory", AnagramOptions{MaxSteps: 1}, "[]"},
+	}
+	for _, ex := range examples {
+		if got := fmt.Sprint(a.MultiWord(ex.fodder, ex.opts)); got != ex.expected {
+			t.Errorf("%s %+v: expectation failure (expected: %v, got: %v)", ex.fodder, ex.opts, ex.expected, got)
+		}
+	}
+}
+
+func TestLetterBank(t *testing.T) {
+	a := testAnagrammer()
+	got := fmt.Sprint(a.LetterBank("stop", 3))
+	if got != "[TOPS SPOT]" {
+		t.Errorf("expectation failure (expected: %v, got: %v)", "[TOPS SPOT]", got)
+	}
+}
+
+func TestHidden(t *testing.T) {
+	examples := []struct {
+		surface, answer string
+		ok              bool
+		marked          string
+		reversed        bool
+	}{
+		{"Some puppies running the wrong way", "pies", true, "Some pup[pies] running the wrong way", false},
+		{"Some puppies running the wrong way", "snip", false, "", false},
+		{"Camel eel's back", "leele", true, "Cam[el eel]'s back", true},
+	}
+	for _, ex := range examples {
+		h, ok := Hidden(ex.surface, ex.answer)
+		if ok != ex.ok {
+			t.Errorf("%q, %q: expectation failure (expected: %v, got: %v)", ex.surface, ex.answer, ex.ok, ok)
+			continue
+		}
+		if ok && (h.Mark(ex.surface) != ex.marked || h.Reversed != ex.reversed) {
+			t.Errorf("%q, %q: expectation failure (expected: %q %v, got: %q %v)", ex.surface, ex.answer, ex.marked, ex.reversed, h.Mark(ex.surface), h.Reversed)
+		}
+	}
+}
+
+func TestHiddenWords(t *testing.T) {
+	a := testAnagrammer()
+	found := a.HiddenWords("Top stop, sir", 3)
+	got := make([]string, len(found))
+	for i, h := range found {
+		got[i] = fmt.Sprintf("%s %v", h.Word, h.Reversed)
+	}
+	expected := "[TOPS false TOPS false SPOT true SPOT true]"
+	if fmt.Sprint(got) != expected {
+		t.Errorf("expectation failure (expected: %v, got: %v)", expected, got)
+	}
+}
@@ -0,0 +1,118 @@
+package xwd
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"time"
+)
+
+// EntryStats records how an entry was filled in during a recorded solve.
+type EntryStats struct {
+	Entry Entry
+
+	// Time is how long the entry took to fill, from the first letter entered
+	// in any of its cells to the last change to its letters (so corrections
+	// count), or to the end of the recording if it was left unfinished.
+	Time time.Duration
+
+	// Complete is whether every cell of the entry was filled at the end of
+	// the recording.
+	Complete bool
+
+	// Errors counts the wrong letters entered in the entry's cells: those
+	// abandoned (replaced or cleared) while wrong, and those still wrong at
+	// the end of the recording. A letter that's only added to, as a rebus is
+	// while it's typed, isn't abandoned. It's always zero if the puzzle has
+	// no solution.
+	Errors int
+}
+
+// EntryStats works out, from the events of the recording, how long each entry
+// of the puzzle took to fill and how many wrong letters were entered in it,
+// showing where the solver got stuck. The stats are returned in the order of
+// p.Entries.
+func (rec *Recording) EntryStats(p *Puzzle) ([]EntryStats, error) {
+	if rec.Puzzle != "" && rec.Puzzle != p.Fingerprint() {
+		return nil, errors.New("the recording isn't of this puzzle")
+	}
+	entries := p.Entries()
+	stats := make([]EntryStats, len(entries))
+	first := make([]time.Duration, len(entries)) // When each entry was started
+	last := make([]time.Duration, len(entries))  // When its letters last changed
+	filled := make([]int, len(entries))          // How many of its cells are filled
+	inEntries := make(map[[2]int][]int)          // The entries through each cell
+	for k, e := range entries {
+		stats[k].Entry = e
+		first[k] = -1
+		for _, c := range e.Cells {
+			inEntries[c] = append(inEntries[c], k)
+		}
+	}
+
+	letters := make(map[[2]int]string)
+	wrong := func(c [2]int, letter string) bool {
+		cell, _ := p.Cell(c[0], c[1])
+		return letter != "" && cell.Solution != "" && letter != strings.ToUpper(cell.Solution)
+	}
+	for _, ev := range rec.Events {
+		c := [2]int{ev.Row, ev.Col}
+		if _, err := p.Cell(ev.Row, ev.Col); err != nil {
+			return nil, err
+		}
+		letter := strings.ToUpper(ev.Letter)
+		prev := letters[c]
+		if letter == prev {
+			continue // Only the flags have changed
+		}
+		letters[c] = letter
+		abandoned := wrong(c, prev) && (letter == "" || !strings.HasPrefix(letter, prev))
+		for _, k := range inEntries[c] {
+			if first[k] < 0 && letter != "" {
+				first[k] = ev.Time
+			}
+			last[k] = ev.Time
+			if abandoned {
+				stats[k].Errors++
+			}
+			switch {
+			case prev == "":
+				filled[k]++
+			case letter == "":
+				filled[k]--
+			}
+		}
+	}
+
+	for c, letter := range letters {
+		if wrong(c, letter) {
+			for _, k := range inEntries[c] {
+				stats[k].Errors++
+			}
+		}
+	}
+
+	for k := range stats {
+		stats[k].Complete = filled[k] == len(entries[k].Cells)
+		switch {
+		case first[k] < 0:
+			// Never started
+		case stats[k].Complete:
+			stats[k].Time = last[k] - first[k]
+		default:
+			stats[k].Time = rec.Duration() - first[k]
+		}
+	}
+	return stats, nil
+}
+
+// RankEntryStats sorts entry stats so that the entries that took longest to
+// fill come first, and of those that took as long, those with more errors.
+func RankEntryStats(stats []EntryStats) {
+	sort.SliceStable(stats, func(i, j int) bool {
+		if stats[i].Time != stats[j].Time {
+			return stats[i].Time > stats[j].Time
+		}
+		return stats[i].Errors > stats[j].Errors
+	})
+}
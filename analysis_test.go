@@ -0,0 +1,103 @@
+package xwd
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEntryStats(t *testing.T) {
+	p := &Puzzle{Rows: 2, Cols: 2}
+	p.SetSolution([]string{"AB", "CD"})
+	s := NewSession(p)
+	clock := s.start
+	s.now = func() time.Time { return clock }
+
+	clock = clock.Add(time.Second)
+	s.Set(0, 0, "A", false)
+	clock = clock.Add(time.Second)
+	s.Set(1, 0, "X", false)
+	s.Check()
+	clock = clock.Add(3 * time.Second)
+	s.Set(1, 0, "C", false)
+	clock = clock.Add(time.Second)
+	s.Set(1, 1, "D", false)
+
+	stats, err := s.Recording(p, "").EntryStats(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name     string
+		time     time.Duration
+		complete bool
+		errors   int
+	}{
+		{"1 across", 5 * time.Second, false, 0},
+		{"3 across", 4 * time.Second, true, 1},
+		{"1 down", 4 * time.Second, true, 1},
+		{"2 down", 0, false, 0},
+	}
+	if len(stats) != len(tests) {
+		t.Fatalf("expectation failure (expected: %d stats, got: %d)", len(tests), len(stats))
+	}
+	for k, tt := range tests {
+		st := stats[k]
+		if st.Entry.Name() != tt.name || st.Time != tt.time || st.Complete != tt.complete || st.Errors != tt.errors {
+			t.Errorf("expectation failure (expected: %v %v %v %v, got: %v %v %v %v)", tt.name, tt.time, tt.complete, tt.errors, st.Entry.Name(), st.Time, st.Complete, st.Errors)
+		}
+	}
+
+	RankEntryStats(stats)
+	ranked := []string{"1 across", "3 across", "1 down", "2 down"}
+	for k, name := range ranked {
+		if stats[k].Entry.Name() != name {
+			t.Errorf("expectation failure (expected: %v ranked %d, got: %v)", name, k+1, stats[k].Entry.Name())
+		}
+	}
+}
+
+func TestEntryStatsRebus(t *testing.T) {
+	p := &Puzzle{Rows: 1, Cols: 3}
+	p.SetSolution([]string{"TXN"})
+	p.SetRebus(0, 1, "HEART")
+
+	tests := []struct {
+		letters  []string // Entered in the middle cell, one a second
+		expected int
+	}{
+		{[]string{"H", "HE", "HEA", "HEAR", "HEART"}, 0},
+		{[]string{"H", "HE", "HEX", "HE", "HEA", "HEAR", "HEART"}, 1},
+		{[]string{"H", "HE", "HEA"}, 1},
+		{[]string{"X", "", "H", "HEART"}, 1},
+	}
+	for _, tt := range tests {
+		rec := &Recording{Events: []Event{{Time: 0, Row: 0, Col: 0, Letter: "T"}}}
+		for k, l := range tt.letters {
+			rec.Events = append(rec.Events, Event{Time: time.Duration(k+1) * time.Second, Row: 0, Col: 1, Letter: l})
+		}
+		stats, err := rec.EntryStats(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stats[0].Errors != tt.expected {
+			t.Errorf("%v: expectation failure (expected: %d errors, got: %d)", tt.letters, tt.expected, stats[0].Errors)
+		}
+	}
+}
+
+func TestEntryStatsUnfinished(t *testing.T) {
+	p := &Puzzle{Rows: 1, Cols: 3}
+	p.SetSolution([]string{"CAT"})
+	rec := &Recording{Events: []Event{
+		{Time: time.Second, Row: 0, Col: 0, Letter: "C"},
+		{Time: 3 * time.Second, Row: 0, Col: 1, Letter: "A"},
+		{Time: 4 * time.Second, Row: 0, Col: 1, Letter: ""},
+	}}
+	stats, err := rec.EntryStats(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st := stats[0]; st.Complete || st.Time != 3*time.Second {
+		t.Errorf("expectation failure (expected: unfinished after 3s, got: complete %v after %v)", st.Complete, st.Time)
+	}
+}
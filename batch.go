@@ -0,0 +1,116 @@
+package xwd
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// BatchFunc is an operation applied to each puzzle by Batch. It returns a
+// short description of the result, or an error.
+type BatchFunc func(ctx context.Context, path string, p *Puzzle) (string, error)
+
+// BatchResult is the outcome of applying a BatchFunc to a single puzzle file.
+type BatchResult struct {
+	Path   string
+	Output string
+	Err    error // Set if the puzzle failed to load or the operation failed
+}
+
+// BatchReport summarises a call to Batch.
+type BatchReport struct {
+	Results  []BatchResult // One per puzzle file, in lexical path order
+	Failed   int           // The number of results with errors
+	Duration time.Duration
+}
+
+// Batch walks the directory tree rooted at root and applies fn to every .puz
+// file within it, loading and processing them with a pool of the given number
+// of workers (or one per CPU if workers is less than one).
+//
+// Errors loading or processing individual puzzles are recorded in the report
+// rather than stopping the batch. If ctx is cancelled, or the tree can't be
+// walked, Batch stops early and returns the error along with a report of the
+// files processed so far.
+func Batch(ctx context.Context, root string, workers int, fn BatchFunc) (*BatchReport, error) {
+	if workers < 1 {
+		workers = runtime.NumCPU()
+	}
+	start := time.Now()
+
+	paths := make(chan string)
+	results := make(chan BatchResult)
+	walkErr := make(chan error, 1)
+
+	go func() {
+		defer close(paths)
+		walkErr <- filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() || !strings.HasSuffix(path, ".puz") {
+				return nil
+			}
+			select {
+			case paths <- path:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		})
+	}()
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for path := range paths {
+				r := batchOne(ctx, path, fn)
+				select {
+				case results <- r:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	report := &BatchReport{Results: make([]BatchResult, 0)}
+	for r := range results {
+		if r.Err != nil {
+			report.Failed++
+		}
+		report.Results = append(report.Results, r)
+	}
+	sort.Slice(report.Results, func(i, j int) bool {
+		return report.Results[i].Path < report.Results[j].Path
+	})
+	report.Duration = time.Since(start)
+
+	if err := <-walkErr; err != nil {
+		return report, err
+	}
+	return report, ctx.Err()
+}
+
+func batchOne(ctx context.Context, path string, fn BatchFunc) BatchResult {
+	r := BatchResult{Path: path}
+	p, err := LoadFS(os.DirFS(filepath.Dir(path)), filepath.Base(path))
+	if err != nil {
+		r.Err = err
+		return r
+	}
+	r.Output, r.Err = fn(ctx, path, p)
+	return r
+}
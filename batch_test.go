@@ -0,0 +1,89 @@
+package xwd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestBatch(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"version_12.puz", "version_13.puz"} {
+		data, err := os.ReadFile(filepath.Join("fixtures", name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = os.WriteFile(filepath.Join(dir, name), data, 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err := os.WriteFile(filepath.Join(dir, "broken.puz"), []byte("not a puzzle"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := Batch(context.Background(), dir, 2, func(ctx context.Context, path string, p *Puzzle) (string, error) {
+		return p.Title, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(report.Results) != 3 {
+		t.Fatalf("wrong number of results (expected: 3, got: %v)", len(report.Results))
+	}
+	if report.Failed != 1 {
+		t.Errorf("wrong number of failures (expected: 1, got: %v)", report.Failed)
+	}
+	if r := report.Results[0]; filepath.Base(r.Path) != "broken.puz" || r.Err == nil {
+		t.Errorf("broken puzzle expectation failure (got: %+v)", r)
+	}
+	if r := report.Results[2]; filepath.Base(r.Path) != "version_13.puz" || r.Output != "Animalia" {
+		t.Errorf("result expectation failure (got: %+v)", r)
+	}
+}
+
+func TestBatchCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := Batch(ctx, "fixtures", 2, func(ctx context.Context, path string, p *Puzzle) (string, error) {
+		return "", nil
+	})
+	if err != context.Canceled {
+		t.Errorf("cancelled batch expectation failure (expected: %v, got: %v)", context.Canceled, err)
+	}
+}
+
+func BenchmarkBatch(b *testing.B) {
+	// An archive of a few hundred puzzles, scanned for duplicates as by "xwd
+	// batch -op fingerprint".
+	dir := b.TempDir()
+	for _, name := range []string{"version_12.puz", "version_12c.puz", "version_13.puz"} {
+		data, err := os.ReadFile(filepath.Join("fixtures", name))
+		if err != nil {
+			b.Fatal(err)
+		}
+		for i := 0; i < 100; i++ {
+			err = os.WriteFile(filepath.Join(dir, fmt.Sprintf("%03d-%s", i, name)), data, 0644)
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		report, err := Batch(context.Background(), dir, 1, func(ctx context.Context, path string, p *Puzzle) (string, error) {
+			return p.Fingerprint(), nil
+		})
+		if err != nil {
+			b.Fatal(err)
+		}
+		if report.Failed != 0 {
+			b.Fatalf("%d puzzles failed to load", report.Failed)
+		}
+	}
+}
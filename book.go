@@ -0,0 +1,225 @@
+package xwd
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha1"
+	"fmt"
+	"html/template"
+	"io"
+	"time"
+)
+
+// Book is a collection of puzzles to be compiled into a single document, with
+// a table of contents, one puzzle per page and the answers at the back.
+type Book struct {
+	Title    string
+	Author   string
+	Language string    // BCP 47 language tag, "en" if empty
+	Modified time.Time // The current time if zero
+	Puzzles  []*Puzzle
+}
+
+var bookTemplates = template.Must(template.Must(htmlTemplates.Clone()).Funcs(template.FuncMap{
+	"chapter": chapterName,
+}).Parse(`
+{{define "xhtml"}}<!DOCTYPE html>
+<html xmlns="http://www.w3.org/1999/xhtml" xmlns:epub="http://www.idpf.org/2007/ops">
+<head>
+  <meta charset="UTF-8"/>
+  <title>{{.Title}}</title>
+  <link rel="stylesheet" type="text/css" href="style.css"/>
+</head>
+<body>
+{{- .Body}}
+</body>
+</html>
+{{end}}
+
+{{define "puzzle"}}
+<section class="puzzle" epub:type="chapter">
+  <h1>{{.Puzzle.Title}}</h1>
+  {{- if .Puzzle.Author}}
+  <p class="author">{{.Puzzle.Author}}</p>
+  {{- end}}
+  {{- template "grid" .}}
+  {{- template "clues" cluelists .Puzzle}}
+  {{- if .Puzzle.Notes}}
+  <p class="notes">{{.Puzzle.Notes}}</p>
+  {{- end}}
+</section>
+{{end}}
+
+{{define "answers"}}
+<section class="answers" epub:type="appendix">
+  <h1>Answers</h1>
+  {{- range .}}
+  <div class="answer">
+    <h2>{{.Puzzle.Title}}</h2>
+    {{- template "grid" .}}
+  </div>
+  {{- end}}
+</section>
+{{end}}
+
+{{define "nav"}}
+<nav epub:type="toc" id="toc">
+  <h1>Contents</h1>
+  <ol>
+    {{- range $i, $p := .}}
+    <li><a href="{{chapter $i}}">{{$p.Title}}</a></li>
+    {{- end}}
+    <li><a href="answers.xhtml">Answers</a></li>
+  </ol>
+</nav>
+{{end}}
+
+{{define "opf"}}<package xmlns="http://www.idpf.org/2007/opf" version="3.0" unique-identifier="id">
+  <metadata xmlns:dc="http://purl.org/dc/elements/1.1/">
+    <dc:identifier id="id">{{.ID}}</dc:identifier>
+    <dc:title>{{.Book.Title}}</dc:title>
+    {{- if .Book.Author}}
+    <dc:creator>{{.Book.Author}}</dc:creator>
+    {{- end}}
+    <dc:language>{{.Language}}</dc:language>
+    <meta property="dcterms:modified">{{.Modified}}</meta>
+  </metadata>
+  <manifest>
+    <item id="nav" href="nav.xhtml" media-type="application/xhtml+xml" properties="nav"/>
+    <item id="style" href="style.css" media-type="text/css"/>
+    {{- range $i, $p := .Book.Puzzles}}
+    <item id="puzzle-{{$i}}" href="{{chapter $i}}" media-type="application/xhtml+xml"/>
+    {{- end}}
+    <item id="answers" href="answers.xhtml" media-type="application/xhtml+xml"/>
+  </manifest>
+  <spine>
+    <itemref idref="nav"/>
+    {{- range $i, $p := .Book.Puzzles}}
+    <itemref idref="puzzle-{{$i}}"/>
+    {{- end}}
+    <itemref idref="answers"/>
+  </spine>
+</package>
+{{end}}
+`))
+
+func chapterName(i int) string {
+	return fmt.Sprintf("puzzle-%03d.xhtml", i+1)
+}
+
+// xmlDeclaration is written ahead of each rendered template, as html/template
+// would otherwise escape it.
+const xmlDeclaration = `<?xml version="1.0" encoding="UTF-8"?>
+`
+
+const epubContainer = xmlDeclaration + `<container version="1.0" xmlns="urn:oasis:names:tc:opendocument:xmlns:container">
+  <rootfiles>
+    <rootfile full-path="OEBPS/content.opf" media-type="application/oebps-package+xml"/>
+  </rootfiles>
+</container>
+`
+
+const bookCSS = `
+h1 { text-align: center; }
+.author { text-align: center; font-style: italic; }
+section.puzzle, section.answers, .answer { page-break-before: always; }
+.answer table.grid td { width: 1.3em; height: 1.3em; }
+`
+
+// WriteEPUB writes the book to w as an EPUB 3 publication. Each puzzle gets
+// its own content document (and so starts on a new page), and a final
+// document contains the solution grid for each puzzle.
+func (b *Book) WriteEPUB(w io.Writer) error {
+	z := zip.NewWriter(w)
+
+	// The mimetype file must come first, and be stored uncompressed.
+	f, err := z.CreateHeader(&zip.FileHeader{Name: "mimetype", Method: zip.Store})
+	if err != nil {
+		return err
+	}
+	io.WriteString(f, "application/epub+zip")
+
+	f, err = z.Create("META-INF/container.xml")
+	if err != nil {
+		return err
+	}
+	io.WriteString(f, epubContainer)
+
+	f, err = z.Create("OEBPS/style.css")
+	if err != nil {
+		return err
+	}
+	io.WriteString(f, gridCSS+bookCSS)
+
+	lang := b.Language
+	if lang == "" {
+		lang = "en"
+	}
+	modified := b.Modified
+	if modified.IsZero() {
+		modified = time.Now()
+	}
+	err = b.writeTemplate(z, "OEBPS/content.opf", "opf", map[string]interface{}{
+		"Book":     b,
+		"ID":       b.identifier(),
+		"Language": lang,
+		"Modified": modified.UTC().Format("2006-01-02T15:04:05Z"),
+	})
+	if err != nil {
+		return err
+	}
+
+	err = b.writePage(z, "OEBPS/nav.xhtml", b.Title, "nav", b.Puzzles)
+	if err != nil {
+		return err
+	}
+
+	answers := make([]gridData, len(b.Puzzles))
+	for i, p := range b.Puzzles {
+		err = b.writePage(z, "OEBPS/"+chapterName(i), p.Title, "puzzle", gridData{Puzzle: p})
+		if err != nil {
+			return err
+		}
+		answers[i] = gridData{Puzzle: p, Solution: true}
+	}
+
+	err = b.writePage(z, "OEBPS/answers.xhtml", "Answers", "answers", answers)
+	if err != nil {
+		return err
+	}
+
+	return z.Close()
+}
+
+// identifier derives a stable URN for the book from its contents, so that
+// recompiling the same puzzles gives the same identifier.
+func (b *Book) identifier() string {
+	h := sha1.New()
+	io.WriteString(h, b.Title)
+	for _, p := range b.Puzzles {
+		io.WriteString(h, p.Fingerprint())
+	}
+	sum := h.Sum(nil)
+	return fmt.Sprintf("urn:uuid:%x-%x-%x-%x-%x", sum[0:4], sum[4:6], sum[6:8], sum[8:10], sum[10:16])
+}
+
+func (b *Book) writePage(z *zip.Writer, name, title, body string, data interface{}) error {
+	var buf bytes.Buffer
+	err := bookTemplates.ExecuteTemplate(&buf, body, data)
+	if err != nil {
+		return err
+	}
+	return b.writeTemplate(z, name, "xhtml", map[string]interface{}{
+		"Title": title,
+		"Body":  template.HTML(buf.String()),
+	})
+}
+
+func (b *Book) writeTemplate(z *zip.Writer, name, tpl string, data interface{}) error {
+	f, err := z.Create(name)
+	if err != nil {
+		return err
+	}
+	io.WriteString(f, xmlDeclaration)
+	return bookTemplates.ExecuteTemplate(f, tpl, data)
+}
@@ -0,0 +1,96 @@
+package xwd
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func loadFixture(t testing.TB, name string) *Puzzle {
+	data, err := os.ReadFile("fixtures/" + name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &Puzzle{}
+	err = p.Load(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestBookWriteEPUB(t *testing.T) {
+	b := &Book{
+		Title:    "Puzzles & Co",
+		Modified: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
+		Puzzles: []*Puzzle{
+			loadFixture(t, "version_12.puz"),
+			loadFixture(t, "version_13.puz"),
+		},
+	}
+
+	var buf bytes.Buffer
+	err := b.WriteEPUB(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	z, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if z.File[0].Name != "mimetype" || z.File[0].Method != zip.Store {
+		t.Errorf("EPUB must start with an uncompressed mimetype file (got: %v)", z.File[0].Name)
+	}
+
+	expected := []string{
+		"mimetype",
+		"META-INF/container.xml",
+		"OEBPS/style.css",
+		"OEBPS/content.opf",
+		"OEBPS/nav.xhtml",
+		"OEBPS/puzzle-001.xhtml",
+		"OEBPS/puzzle-002.xhtml",
+		"OEBPS/answers.xhtml",
+	}
+	if len(z.File) != len(expected) {
+		t.Fatalf("wrong number of files in EPUB (expected: %v, got: %v)", len(expected), len(z.File))
+	}
+	for i, f := range z.File {
+		if f.Name != expected[i] {
+			t.Errorf("EPUB file expectation failure (expected: %v, got: %v)", expected[i], f.Name)
+		}
+		if !strings.HasSuffix(f.Name, ".xhtml") && !strings.HasSuffix(f.Name, ".xml") && !strings.HasSuffix(f.Name, ".opf") {
+			continue
+		}
+		r, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := io.ReadAll(r)
+		r.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.HasPrefix(data, []byte("<?xml ")) {
+			t.Errorf("%v should start with an XML declaration", f.Name)
+		}
+		d := xml.NewDecoder(bytes.NewReader(data))
+		for {
+			_, err = d.Token()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Errorf("%v is not well-formed XML: %v", f.Name, err)
+				break
+			}
+		}
+	}
+}
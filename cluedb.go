@@ -0,0 +1,149 @@
+package xwd
+
+import (
+	"encoding/csv"
+	"errors"
+	"io"
+	"strings"
+)
+
+// ClueDB is a database of clues and the answers they've been known to have,
+// for looking up candidate answers when solving a puzzle.
+type ClueDB struct {
+	answers map[string]map[string]int // Normalised clue -> answer -> count
+	counts  map[string]int            // Answer -> count
+	puzzles map[string]int            // Fingerprint -> times added
+}
+
+// NewClueDB returns an empty clue database.
+func NewClueDB() *ClueDB {
+	return &ClueDB{
+		answers: make(map[string]map[string]int),
+		counts:  make(map[string]int),
+		puzzles: make(map[string]int),
+	}
+}
+
+// ReadClueDB reads a clue database from CSV data with a header row naming (at
+// least) "clue" and "answer" columns, such as that written by WriteCSV.
+func ReadClueDB(r io.Reader) (*ClueDB, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+
+	header, err := cr.Read()
+	if err != nil {
+		return nil, err
+	}
+	clueCol, answerCol := -1, -1
+	for i, name := range header {
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case "clue":
+			clueCol = i
+		case "answer":
+			answerCol = i
+		}
+	}
+	if clueCol < 0 || answerCol < 0 {
+		return nil, errors.New("CSV header must name clue and answer columns")
+	}
+
+	db := NewClueDB()
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if clueCol < len(record) && answerCol < len(record) {
+			db.Add(record[clueCol], record[answerCol])
+		}
+	}
+	return db, nil
+}
+
+// Add records that the clue has been seen with the given answer. Answers with
+// letters that can't be written in A-Z are left out, as by WordList.Add.
+func (db *ClueDB) Add(clue, answer string) {
+	clue = normaliseClue(clue)
+	answer, ok := normaliseAnswer(answer)
+	if clue == "" || answer == "" || !ok {
+		return
+	}
+	if db.answers[clue] == nil {
+		db.answers[clue] = make(map[string]int)
+	}
+	db.answers[clue][answer]++
+	db.counts[answer]++
+}
+
+// AddPuzzle records the clue and answer of every entry in a solved puzzle.
+func (db *ClueDB) AddPuzzle(p *Puzzle) {
+	for _, e := range p.Entries() {
+		db.Add(e.Clue, e.Answer)
+	}
+	db.puzzles[p.Fingerprint()]++
+}
+
+// puzzleCounts returns the answer counts that p itself contributed to the
+// database, if it was added with AddPuzzle, or nil if it wasn't. (A puzzle
+// whose clues came from CSV can't be recognised.)
+func (db *ClueDB) puzzleCounts(p *Puzzle) map[string]int {
+	times := db.puzzles[p.Fingerprint()]
+	if times == 0 {
+		return nil
+	}
+	counts := make(map[string]int)
+	for _, e := range p.Entries() {
+		answer, ok := normaliseAnswer(e.Answer)
+		if normaliseClue(e.Clue) != "" && answer != "" && ok {
+			counts[answer] += times
+		}
+	}
+	return counts
+}
+
+// Merge adds every clue and answer in other to the database.
+func (db *ClueDB) Merge(other *ClueDB) {
+	for clue, answers := range other.answers {
+		if db.answers[clue] == nil {
+			db.answers[clue] = make(map[string]int)
+		}
+		for answer, count := range answers {
+			db.answers[clue][answer] += count
+			db.counts[answer] += count
+		}
+	}
+	for fingerprint, times := range other.puzzles {
+		db.puzzles[fingerprint] += times
+	}
+}
+
+// Lookup returns the answers seen for a clue, with the number of times each
+// has been seen.
+func (db *ClueDB) Lookup(clue string) map[string]int {
+	return db.answers[normaliseClue(clue)]
+}
+
+// AnswerCount returns the number of times an answer has been seen, with any
+// clue.
+func (db *ClueDB) AnswerCount(answer string) int {
+	return db.counts[NormaliseAnswer(answer)]
+}
+
+// Len returns the number of distinct clues in the database.
+func (db *ClueDB) Len() int {
+	return len(db.answers)
+}
+
+// normaliseClue reduces a clue to the form used to look it up: lower case,
+// without any enumeration, and with runs of punctuation and spacing reduced
+// to single spaces.
+func normaliseClue(clue string) string {
+	clue = enumerationRe.ReplaceAllString(clue, "")
+	words := strings.FieldsFunc(strings.ToLower(clue), func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r > 0x7f)
+	})
+	return strings.Join(words, " ")
+}
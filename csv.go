@@ -0,0 +1,121 @@
+package xwd
+
+import (
+	"encoding/csv"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+)
+
+var csvHeader = []string{"direction", "number", "answer", "clue", "enumeration"}
+
+// WriteCSV writes every entry in the puzzle to w as CSV, one row per entry,
+// preceded by a header row naming the columns: direction, number, answer,
+// clue and enumeration. Clue numbers are written one-indexed, as printed.
+func WriteCSV(w io.Writer, p *Puzzle) error {
+	cw := csv.NewWriter(w)
+	err := cw.Write(csvHeader)
+	if err != nil {
+		return err
+	}
+	for _, e := range p.Entries() {
+		err = cw.Write([]string{
+			e.Direction.String(),
+			strconv.Itoa(e.Num + 1),
+			e.Answer,
+			e.Clue,
+			e.Enumeration(),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// ReadCSV reads clue text from CSV data in the format written by WriteCSV and
+// uses it to update the clues of an existing puzzle. The columns are
+// identified by the header row, so they may be reordered or omitted, but
+// there must be a "clue" column and either "direction" and "number" columns
+// or an "answer" column.
+//
+// Each row is matched to an entry by direction and number if possible, and
+// otherwise by answer, provided only one entry has that answer. ReadCSV
+// returns the line numbers of any rows it couldn't match.
+func ReadCSV(r io.Reader, p *Puzzle) ([]int, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+
+	header, err := cr.Read()
+	if err != nil {
+		return nil, err
+	}
+	cols := make(map[string]int)
+	for i, name := range header {
+		cols[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	_, hasClue := cols["clue"]
+	_, hasDir := cols["direction"]
+	_, hasNum := cols["number"]
+	_, hasAnswer := cols["answer"]
+	if !hasClue || !(hasDir && hasNum || hasAnswer) {
+		return nil, errors.New("CSV header must name a clue column and either direction and number or answer columns")
+	}
+	field := func(record []string, name string) string {
+		i, ok := cols[name]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+
+	byAnswer := make(map[string][]Entry)
+	for _, e := range p.Entries() {
+		byAnswer[e.Answer] = append(byAnswer[e.Answer], e)
+	}
+
+	unmatched := make([]int, 0)
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return unmatched, err
+		}
+		line, _ := cr.FieldPos(0)
+
+		var clue *Clue
+		dir, dirOK := parseDirection(field(record, "direction"))
+		num, numErr := strconv.Atoi(field(record, "number"))
+		if dirOK && numErr == nil {
+			clue = p.clue(dir, num-1)
+		}
+		if clue == nil {
+			answer := strings.ToUpper(field(record, "answer"))
+			if matches := byAnswer[answer]; len(answer) > 0 && len(matches) == 1 {
+				clue = p.clue(matches[0].Direction, matches[0].Num)
+			}
+		}
+		if clue == nil {
+			unmatched = append(unmatched, line)
+			continue
+		}
+		clue.Clue = field(record, "clue")
+	}
+	return unmatched, nil
+}
+
+func parseDirection(s string) (Direction, bool) {
+	switch strings.ToLower(s) {
+	case "across", "a":
+		return Across, true
+	case "down", "d":
+		return Down, true
+	case "custom":
+		return Custom, true
+	}
+	return Across, false
+}
@@ -0,0 +1,91 @@
+package xwd
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func csvPuzzle(t *testing.T) *Puzzle {
+	p := &Puzzle{Rows: 3, Cols: 3}
+	err := p.SetSolution([]string{
+		"CAT",
+		"..A",
+		"..N",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.CluesAcross()[0].Clue = "Feline"
+	p.CluesDown()[0].Clue = "Sunburn, \"lightly\""
+	return p
+}
+
+func TestWriteCSV(t *testing.T) {
+	var buf bytes.Buffer
+	err := WriteCSV(&buf, csvPuzzle(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "direction,number,answer,clue,enumeration\n" +
+		"across,1,CAT,Feline,(3)\n" +
+		"down,2,TAN,\"Sunburn, \"\"lightly\"\"\",(3)\n"
+	if buf.String() != expected {
+		t.Errorf("CSV output expectation failure (expected: %q, got: %q)", expected, buf.String())
+	}
+}
+
+type ReadCSVExample struct {
+	in        string
+	across    string
+	down      string
+	unmatched []int
+}
+
+var readCSVExamples = []ReadCSVExample{
+	{
+		in:        "direction,number,clue\nacross,1,Pet\nD,2,Brown\n",
+		across:    "Pet",
+		down:      "Brown",
+		unmatched: []int{},
+	},
+	{
+		in:        "Answer,Clue\ncat,Pet\nDOG,Hound\n",
+		across:    "Pet",
+		down:      "Sunburn, \"lightly\"",
+		unmatched: []int{3},
+	},
+	{
+		in:        "direction,number,answer,clue\ndown,5,TAN,Brown\nacross,4,FOO,Bar\n",
+		across:    "Feline",
+		down:      "Brown",
+		unmatched: []int{3},
+	},
+}
+
+func TestReadCSV(t *testing.T) {
+	for _, ex := range readCSVExamples {
+		p := csvPuzzle(t)
+		unmatched, err := ReadCSV(strings.NewReader(ex.in), p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(unmatched, ex.unmatched) {
+			t.Errorf("unmatched rows expectation failure (expected: %v, got: %v)", ex.unmatched, unmatched)
+		}
+		if a := p.CluesAcross()[0].Clue; a != ex.across {
+			t.Errorf("across clue expectation failure (expected: %v, got: %v)", ex.across, a)
+		}
+		if d := p.CluesDown()[0].Clue; d != ex.down {
+			t.Errorf("down clue expectation failure (expected: %v, got: %v)", ex.down, d)
+		}
+	}
+}
+
+func TestReadCSVBadHeader(t *testing.T) {
+	_, err := ReadCSV(strings.NewReader("number,clue\n1,Pet\n"), csvPuzzle(t))
+	if err == nil {
+		t.Errorf("reading CSV without direction or answer columns incorrectly succeeded")
+	}
+}
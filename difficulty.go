@@ -0,0 +1,234 @@
+package xwd
+
+import (
+	"errors"
+	"math"
+	"strings"
+)
+
+// Days are the conventional difficulty buckets for puzzles, easiest first.
+var Days = []string{"Monday", "Tuesday", "Wednesday", "Thursday", "Friday", "Saturday"}
+
+// DifficultyFeatures are the properties of a puzzle used to estimate its
+// difficulty.
+type DifficultyFeatures struct {
+	Obscurity  float64 // How far the answers are from the top of the word list, 0-1
+	Freshness  float64 // How rarely the answers appear in the corpus, 0-1
+	ClueLength float64 // The mean number of words in a clue
+	Openness   float64 // The mean length of an entry
+	Unchecked  float64 // The fraction of white cells in only one entry
+}
+
+func (f DifficultyFeatures) vector() []float64 {
+	return []float64{f.Obscurity, f.Freshness, f.ClueLength, f.Openness, f.Unchecked}
+}
+
+// DifficultyModel turns features into a difficulty score on the scale of
+// Days, where 1 is a Monday and 6 a Saturday: the intercept plus the sum of
+// each feature multiplied by its weight.
+type DifficultyModel struct {
+	Intercept float64
+	Weights   DifficultyFeatures
+}
+
+// DefaultDifficultyModel is a rough, hand-tuned model. Calibrating a model
+// (see CalibrateDifficulty) from puzzles labelled with their days, using the
+// same word list and corpus, will give much better estimates.
+var DefaultDifficultyModel = &DifficultyModel{
+	Intercept: -1.5,
+	Weights: DifficultyFeatures{
+		Obscurity:  5,
+		Freshness:  2,
+		ClueLength: -0.25,
+		Openness:   0.6,
+		Unchecked:  2,
+	},
+}
+
+// Score returns the difficulty score for a puzzle with the given features.
+func (m *DifficultyModel) Score(f DifficultyFeatures) float64 {
+	score := m.Intercept
+	w := m.Weights.vector()
+	for i, v := range f.vector() {
+		score += w[i] * v
+	}
+	return score
+}
+
+// Day returns the name of the difficulty bucket for a score.
+func Day(score float64) string {
+	i := int(math.Round(score)) - 1
+	if i < 0 {
+		i = 0
+	}
+	if i >= len(Days) {
+		i = len(Days) - 1
+	}
+	return Days[i]
+}
+
+// DifficultySample is a puzzle's features together with its known difficulty,
+// for calibration.
+type DifficultySample struct {
+	Features DifficultyFeatures
+	Score    float64 // The day of the puzzle, from 1 (Monday) to 6 (Saturday)
+}
+
+// CalibrateDifficulty fits a model to the samples by least squares.
+func CalibrateDifficulty(samples []DifficultySample) (*DifficultyModel, error) {
+	n := len(DifficultyFeatures{}.vector()) + 1
+	if len(samples) < n {
+		return nil, errors.New("not enough samples to calibrate a difficulty model")
+	}
+
+	// Solve the normal equations (XᵀX)β = Xᵀy, with a little ridge
+	// regularisation in case some feature doesn't vary across the samples.
+	a := make([][]float64, n)
+	for i := range a {
+		a[i] = make([]float64, n+1)
+	}
+	for _, s := range samples {
+		x := append([]float64{1}, s.Features.vector()...)
+		for i := 0; i < n; i++ {
+			for j := 0; j < n; j++ {
+				a[i][j] += x[i] * x[j]
+			}
+			a[i][n] += x[i] * s.Score
+		}
+	}
+	for i := 1; i < n; i++ {
+		a[i][i] += 1e-6
+	}
+
+	beta, err := solveLinear(a)
+	if err != nil {
+		return nil, err
+	}
+	return &DifficultyModel{
+		Intercept: beta[0],
+		Weights: DifficultyFeatures{
+			Obscurity:  beta[1],
+			Freshness:  beta[2],
+			ClueLength: beta[3],
+			Openness:   beta[4],
+			Unchecked:  beta[5],
+		},
+	}, nil
+}
+
+// solveLinear solves the system of linear equations given as an augmented
+// matrix, by Gaussian elimination with partial pivoting.
+func solveLinear(a [][]float64) ([]float64, error) {
+	n := len(a)
+	for col := 0; col < n; col++ {
+		pivot := col
+		for row := col + 1; row < n; row++ {
+			if math.Abs(a[row][col]) > math.Abs(a[pivot][col]) {
+				pivot = row
+			}
+		}
+		if math.Abs(a[pivot][col]) < 1e-12 {
+			return nil, errors.New("difficulty samples are degenerate")
+		}
+		a[col], a[pivot] = a[pivot], a[col]
+		for row := col + 1; row < n; row++ {
+			f := a[row][col] / a[col][col]
+			for k := col; k <= n; k++ {
+				a[row][k] -= f * a[col][k]
+			}
+		}
+	}
+	x := make([]float64, n)
+	for row := n - 1; row >= 0; row-- {
+		sum := a[row][n]
+		for k := row + 1; k < n; k++ {
+			sum -= a[row][k] * x[k]
+		}
+		x[row] = sum / a[row][row]
+	}
+	return x, nil
+}
+
+// DifficultyEstimator measures the features of puzzles and estimates their
+// difficulty.
+type DifficultyEstimator struct {
+	Words  *WordList        // For judging obscurity (may be nil)
+	Corpus *ClueDB          // For judging freshness (may be nil)
+	Model  *DifficultyModel // nil for DefaultDifficultyModel
+
+	maxScore int // The best score in the word list
+}
+
+// Features measures the difficulty features of a puzzle. Obscurity and
+// freshness are zero without a word list and corpus respectively.
+func (d *DifficultyEstimator) Features(p *Puzzle) DifficultyFeatures {
+	var f DifficultyFeatures
+	entries := p.Entries()
+	if len(entries) == 0 {
+		return f
+	}
+
+	if d.Words != nil && d.maxScore == 0 {
+		for _, score := range d.Words.scores {
+			if score > d.maxScore {
+				d.maxScore = score
+			}
+		}
+	}
+
+	// A puzzle that's in the corpus itself mustn't count its own answers as
+	// repeats.
+	var own map[string]int
+	if d.Corpus != nil {
+		own = d.Corpus.puzzleCounts(p)
+	}
+
+	cellEntries := make([]int, len(p.cells))
+	for _, e := range entries {
+		answer := NormaliseAnswer(e.Answer)
+		if d.Words != nil {
+			obscurity := 1.0
+			if score, ok := d.Words.scores[answer]; ok && d.maxScore > 0 {
+				obscurity = 1 - float64(score)/float64(d.maxScore)
+			}
+			f.Obscurity += obscurity
+		}
+		if d.Corpus != nil {
+			f.Freshness += 1 / float64(1+d.Corpus.counts[answer]-own[answer])
+		}
+		f.ClueLength += float64(len(strings.Fields(enumerationRe.ReplaceAllString(e.Clue, ""))))
+		f.Openness += float64(len(e.Cells))
+		for _, c := range e.Cells {
+			cellEntries[c[0]*p.Cols+c[1]]++
+		}
+	}
+	n := float64(len(entries))
+	f.Obscurity /= n
+	f.Freshness /= n
+	f.ClueLength /= n
+	f.Openness /= n
+
+	white, unchecked := 0, 0
+	for i, cell := range p.cells {
+		if cell.Black {
+			continue
+		}
+		white++
+		if cellEntries[i] < 2 {
+			unchecked++
+		}
+	}
+	if white > 0 {
+		f.Unchecked = float64(unchecked) / float64(white)
+	}
+	return f
+}
+
+// Estimate returns the difficulty score of a puzzle.
+func (d *DifficultyEstimator) Estimate(p *Puzzle) float64 {
+	m := d.Model
+	if m == nil {
+		m = DefaultDifficultyModel
+	}
+	return m.Score(d.Features(p))
+}
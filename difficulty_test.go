@@ -0,0 +1,98 @@
+package xwd
+
+import (
+	"math"
+	"testing"
+)
+
+func TestDifficultyFeatures(t *testing.T) {
+	p := newTestPuzzle(t, "CAT", "O.O")
+	p.SetClue(Across, 0, "Pet (3)")
+	p.SetClue(Down, 0, "Ox's relative (2)")
+	p.SetClue(Down, 1, "As well")
+
+	words := NewWordList()
+	words.Add("CAT", 50)
+	words.Add("CO", 25)
+	corpus := NewClueDB()
+	corpus.Add("Feline", "CAT")
+	corpus.Add("Pet", "CAT")
+
+	d := &DifficultyEstimator{Words: words, Corpus: corpus}
+	f := d.Features(p)
+	expected := DifficultyFeatures{
+		Obscurity:  (0 + 0.5 + 1) / 3.0,
+		Freshness:  (1/3.0 + 1 + 1) / 3.0,
+		ClueLength: (1 + 2 + 2) / 3.0,
+		Openness:   (3 + 2 + 2) / 3.0,
+		Unchecked:  3 / 5.0,
+	}
+	ev, fv := expected.vector(), f.vector()
+	for i := range ev {
+		if math.Abs(ev[i]-fv[i]) > 1e-9 {
+			t.Errorf("expectation failure (expected: %+v, got: %+v)", expected, f)
+			break
+		}
+	}
+}
+
+func TestDifficultyFeaturesOwnAnswers(t *testing.T) {
+	p := newTestPuzzle(t, "CAT", "O.O")
+	p.SetClue(Across, 0, "Pet (3)")
+	p.SetClue(Down, 0, "Ox's relative (2)")
+	p.SetClue(Down, 1, "As well")
+
+	corpus := NewClueDB()
+	corpus.Add("Feline", "CAT")
+	without := (&DifficultyEstimator{Corpus: corpus}).Features(p)
+	corpus.AddPuzzle(p)
+	with := (&DifficultyEstimator{Corpus: corpus}).Features(p)
+
+	if math.Abs(with.Freshness-without.Freshness) > 1e-9 {
+		t.Errorf("expectation failure (expected: %v, got: %v)", without.Freshness, with.Freshness)
+	}
+}
+
+func TestCalibrateDifficulty(t *testing.T) {
+	want := &DifficultyModel{
+		Intercept: 0.5,
+		Weights:   DifficultyFeatures{Obscurity: 4, Freshness: 1, ClueLength: -0.5, Openness: 0.75, Unchecked: 3},
+	}
+	samples := make([]DifficultySample, 0)
+	for i := 0; i < 20; i++ {
+		x := float64(i)
+		f := DifficultyFeatures{
+			Obscurity:  math.Mod(x*0.37, 1),
+			Freshness:  math.Mod(x*0.61, 1),
+			ClueLength: 3 + math.Mod(x*1.3, 4),
+			Openness:   4 + math.Mod(x*0.7, 3),
+			Unchecked:  math.Mod(x*0.13, 0.5),
+		}
+		samples = append(samples, DifficultySample{f, want.Score(f)})
+	}
+
+	got, err := CalibrateDifficulty(samples)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range samples {
+		if math.Abs(got.Score(s.Features)-s.Score) > 1e-3 {
+			t.Errorf("expectation failure (expected: %+v, got: %+v)", want, got)
+			break
+		}
+	}
+
+	_, err = CalibrateDifficulty(samples[:3])
+	if err == nil {
+		t.Errorf("expected an error calibrating from too few samples")
+	}
+}
+
+func TestDay(t *testing.T) {
+	examples := map[float64]string{-2: "Monday", 1.4: "Monday", 3.6: "Thursday", 6: "Saturday", 9: "Saturday"}
+	for score, day := range examples {
+		if got := Day(score); got != day {
+			t.Errorf("%v: expectation failure (expected: %v, got: %v)", score, day, got)
+		}
+	}
+}
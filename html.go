@@ -0,0 +1,613 @@
+package xwd
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"hash/crc32"
+	"html/template"
+	"io"
+	"math"
+	"time"
+)
+
+// htmlFuncs are the helper functions available to the HTML templates.
+var htmlFuncs = template.FuncMap{
+	"cells":     htmlGrid,
+	"cluelists": htmlClueLists,
+	"clock": func(d time.Duration) string {
+		s := int(d / time.Second)
+		return fmt.Sprintf("%d:%02d", s/60, s%60)
+	},
+}
+
+// htmlTemplates holds the fragments used to render puzzles as (X)HTML. They
+// are written to be well-formed XML so that they can be used in EPUB content
+// documents as well as ordinary web pages.
+//
+// "grid" expects a gridData, and "clues" the htmlClues of a puzzle (from the
+// "cluelists" function).
+var htmlTemplates = template.Must(template.New("").Funcs(htmlFuncs).Parse(`
+{{define "grid"}}
+<table class="grid">
+  {{- range cells .Puzzle}}
+  <tr>
+    {{- range .}}
+    {{- if .Black}}
+    <td class="black"></td>
+    {{- else}}
+    <td class="white" data-i="{{.I}}" data-j="{{.J}}">
+      {{- if .Num}}<span class="num">{{.Num}}</span>{{end -}}
+      {{- if $.Solution}}<span class="letter">{{.Solution}}</span>{{end -}}
+    </td>
+    {{- end}}
+    {{- end}}
+  </tr>
+  {{- end}}
+</table>
+{{- end}}
+
+{{define "clues"}}
+<div class="clues">
+  <div class="across">
+    <h3>Across</h3>
+    <ol>
+      {{- range .Across}}
+      <li value="{{.Num}}" data-num="{{.Num}}">{{.Clue}}</li>
+      {{- end}}
+    </ol>
+  </div>
+  <div class="down">
+    <h3>Down</h3>
+    <ol>
+      {{- range .Down}}
+      <li value="{{.Num}}" data-num="{{.Num}}">{{.Clue}}</li>
+      {{- end}}
+    </ol>
+  </div>
+  {{- if .Custom}}
+  <div class="custom">
+    <h3>Other</h3>
+    <ul>
+      {{- range .Custom}}
+      <li data-num="{{.Num}}"><span class="label">{{.Label}}</span> {{.Clue}}</li>
+      {{- end}}
+    </ul>
+  </div>
+  {{- end}}
+</div>
+{{- end}}
+`))
+
+type gridData struct {
+	Puzzle   *Puzzle
+	Solution bool // Whether to show the solution letters in the grid
+}
+
+// htmlCell is a cell as the "grid" template shows it. Everything the template
+// needs is worked out beforehand, as calling template functions for each cell
+// of each grid makes rendering slow.
+type htmlCell struct {
+	Black    bool
+	I, J     int // The row and column
+	Num      int // The number shown in the cell, from 1, or 0 for none
+	Solution string
+}
+
+// htmlGrid returns the cells of p for the "grid" template, row by row.
+func htmlGrid(p *Puzzle) [][]htmlCell {
+	cells := make([]htmlCell, len(p.cells))
+	rows := make([][]htmlCell, p.Rows)
+	for i := range rows {
+		rows[i] = cells[i*p.Cols : (i+1)*p.Cols]
+	}
+	for c, cell := range p.cells {
+		cells[c] = htmlCell{Black: cell.Black, I: cell.Coords[0], J: cell.Coords[1], Num: cell.Num + 1, Solution: cell.Solution}
+	}
+	return rows
+}
+
+// htmlClues are the clues of a puzzle, as the "clues" template lists them.
+type htmlClues struct {
+	Across, Down, Custom []htmlClue
+}
+
+type htmlClue struct {
+	Num   int // From 1
+	Label string
+	Clue  string
+}
+
+// htmlClueLists sorts the clues of p into lists for the "clues" template.
+func htmlClueLists(p *Puzzle) htmlClues {
+	var lists htmlClues
+	for _, e := range p.Entries() {
+		clue := htmlClue{Num: e.Num + 1, Label: e.Label, Clue: e.Clue}
+		switch e.Direction {
+		case Across:
+			lists.Across = append(lists.Across, clue)
+		case Down:
+			lists.Down = append(lists.Down, clue)
+		default:
+			lists.Custom = append(lists.Custom, clue)
+		}
+	}
+	return lists
+}
+
+// gridCSS styles the output of the "grid" and "clues" templates.
+const gridCSS = `
+table.grid { border-collapse: collapse; margin: 1em auto; }
+table.grid td { border: 1px solid #000; width: 2em; height: 2em; padding: 0; position: relative; text-align: center; vertical-align: middle; }
+table.grid td.black { background: #000; }
+table.grid .num { position: absolute; top: 1px; left: 2px; font-size: 0.55em; line-height: 1; }
+table.grid .letter { font-size: 1.1em; text-transform: uppercase; }
+.clues { display: flex; flex-wrap: wrap; }
+.clues > div { flex: 1; min-width: 14em; }
+.clues ol { padding-left: 2.5em; }
+.clues ul { list-style: none; padding-left: 1em; }
+.clues .label { font-weight: bold; }
+`
+
+// HTMLOptions controls the output of WriteHTML.
+type HTMLOptions struct {
+	// Check embeds the (obfuscated) solution in the page, enabling the
+	// solver's answer checking buttons.
+	Check bool
+
+	// Print writes a static, printable version of the puzzle instead: the
+	// blank grid and clues, without the solver script or controls.
+	Print bool
+
+	// Share is the URL to which the solver can send a recording of the solve
+	// (in the form read by ReadRecording), so that it can be replayed. If it's
+	// empty, sharing isn't offered.
+	Share string
+
+	// Race is the URL of a race (see Race) on the puzzle that the solver can
+	// join. The page then shows the players' progress, and sends the solver's
+	// changes to the race rather than checking them itself.
+	Race string
+
+	// NewRace is the URL to which the solver can POST to create a race on the
+	// puzzle, which is expected to respond with the race's URL in the
+	// Location header. If it's empty, racing isn't offered.
+	NewRace string
+
+	// Submit is the URL to which the solver's grid is POSTed when they
+	// submit it, as in a tournament. A submission is final, so the grid is
+	// locked afterwards.
+	Submit string
+
+	// Deadline is when the grid must be submitted by. The page counts down
+	// to it, and submits the grid itself when it arrives.
+	Deadline time.Time
+}
+
+// htmlPuzzle is the data about the puzzle made available to the solver
+// script.
+type htmlPuzzle struct {
+	ID       string      `json:"id"`
+	Rows     int         `json:"rows"`
+	Cols     int         `json:"cols"`
+	Entries  []htmlEntry `json:"entries"`
+	Key      uint32      `json:"key,omitempty"`
+	Solution string      `json:"solution,omitempty"`
+	Share    string      `json:"share,omitempty"`
+	Race     string      `json:"race,omitempty"`
+	NewRace  string      `json:"newRace,omitempty"`
+	Submit   string      `json:"submit,omitempty"`
+
+	// Remaining is the time left before the deadline for submitting the grid,
+	// in milliseconds, so that the page needn't trust the solver's clock.
+	Remaining int64 `json:"remaining,omitempty"`
+}
+
+type htmlEntry struct {
+	Dir   string   `json:"dir"`
+	Num   int      `json:"num"` // One-indexed, as displayed
+	Cells [][2]int `json:"cells"`
+}
+
+var pageTemplate = template.Must(template.Must(htmlTemplates.Clone()).Parse(`
+{{define "page"}}<!DOCTYPE html>
+<html>
+<head>
+  <meta charset="UTF-8">
+  <meta name="viewport" content="width=device-width, initial-scale=1">
+  <title>{{.Puzzle.Title}}</title>
+  <style>{{.CSS}}</style>
+</head>
+<body>
+<div class="xwd">
+  <h1>{{.Puzzle.Title}}</h1>
+  {{- if .Puzzle.Author}}
+  <p class="author">{{.Puzzle.Author}}</p>
+  {{- end}}
+  {{- if .Puzzle.Copyright}}
+  <p class="copyright">{{.Puzzle.Copyright}}</p>
+  {{- end}}
+  {{- template "grid" .}}
+  {{- if not .Print}}
+  <div class="controls">
+    {{- if .Data.Solution}}
+    <button type="button" data-action="check-letter">Check letter</button>
+    <button type="button" data-action="check-word">Check word</button>
+    <button type="button" data-action="check-puzzle">Check puzzle</button>
+    <button type="button" data-action="autocheck">Autocheck</button>
+    {{- end}}
+    <button type="button" data-action="rebus">Rebus</button>
+    <button type="button" data-action="pencil">Pencil</button>
+    <button type="button" data-action="clear">Clear</button>
+    {{- if .Data.Share}}
+    <button type="button" data-action="share">Share replay</button>
+    {{- end}}
+    {{- if .Data.NewRace}}
+    <button type="button" data-action="race">Race</button>
+    {{- end}}
+    {{- if .Data.Submit}}
+    <button type="button" data-action="submit">Submit</button>
+    <span class="countdown"></span>
+    {{- end}}
+    <span class="status"></span>
+  </div>
+  {{- if .Data.Race}}
+  <div class="controls race">
+    <button type="button" data-action="join">Join race</button>
+    <button type="button" data-action="start">Start race</button>
+    <span class="race-status"></span>
+    <div class="racers"></div>
+  </div>
+  {{- end}}
+  {{- end}}
+  {{- template "clues" cluelists .Puzzle}}
+  {{- if .Puzzle.Notes}}
+  <p class="notes">{{.Puzzle.Notes}}</p>
+  {{- end}}
+</div>
+{{- if not .Print}}
+<script>
+var xwdPuzzle = {{.Data}};
+{{.Script}}
+</script>
+{{- end}}
+</body>
+</html>
+{{end}}
+`))
+
+// WriteHTML writes the puzzle to w as a single self-contained HTML page, with
+// the styles and solver script embedded, so that it can be opened and solved
+// in a browser without a server. Progress is saved in the browser's local
+// storage.
+//
+// If opts.Check is set, the solution is embedded so that the solver can check
+// answers. It is obfuscated, to prevent it being read from the page source at
+// a glance, but not in any way secure.
+func WriteHTML(w io.Writer, p *Puzzle, opts HTMLOptions) error {
+	if opts.Print {
+		return pageTemplate.ExecuteTemplate(w, "page", map[string]interface{}{
+			"Puzzle": p,
+			"Print":  true,
+			"CSS":    template.CSS(gridCSS + solverCSS + printCSS),
+		})
+	}
+
+	data := htmlPuzzle{
+		ID:      p.Fingerprint(),
+		Rows:    p.Rows,
+		Cols:    p.Cols,
+		Entries: make([]htmlEntry, 0),
+		Share:   opts.Share,
+		Race:    opts.Race,
+		NewRace: opts.NewRace,
+		Submit:  opts.Submit,
+	}
+	if !opts.Deadline.IsZero() {
+		data.Remaining = time.Until(opts.Deadline).Milliseconds()
+		if data.Remaining <= 0 {
+			data.Remaining = 1 // Submit straight away
+		}
+	}
+	for _, e := range p.Entries() {
+		data.Entries = append(data.Entries, htmlEntry{
+			Dir:   e.Direction.String(),
+			Num:   e.Num + 1,
+			Cells: e.Cells,
+		})
+	}
+
+	if opts.Check {
+		cells := make([]string, 0, p.Rows*p.Cols)
+		for _, row := range p.Solution() {
+			for _, cell := range row {
+				cells = append(cells, cell.Solution)
+			}
+		}
+		plain, err := json.Marshal(cells)
+		if err != nil {
+			return err
+		}
+		data.Key = crc32.ChecksumIEEE([]byte(data.ID)) | 1
+		data.Solution = base64.StdEncoding.EncodeToString(obfuscate(plain, data.Key))
+	}
+
+	return pageTemplate.ExecuteTemplate(w, "page", map[string]interface{}{
+		"Puzzle": p,
+		"Data":   data,
+		"CSS":    template.CSS(gridCSS + solverCSS),
+		"Script": template.JS(solverJS),
+	})
+}
+
+var replayTemplate = template.Must(template.Must(htmlTemplates.Clone()).Parse(`
+{{define "replay"}}<!DOCTYPE html>
+<html>
+<head>
+  <meta charset="UTF-8">
+  <meta name="viewport" content="width=device-width, initial-scale=1">
+  <title>Replay: {{.Puzzle.Title}}</title>
+  <style>{{.CSS}}</style>
+</head>
+<body>
+<div class="xwd">
+  <h1>{{.Puzzle.Title}}</h1>
+  <p class="author">
+    {{- if .Recording.Player}}Solved by {{.Recording.Player}}{{else}}Solve{{end}}
+    {{- if not .Recording.Start.IsZero}} on {{.Recording.Start.Format "2 January 2006"}}{{end}}
+    {{- if .Recording.Assisted}} (with autocheck){{end -}}
+  </p>
+  {{- template "grid" .}}
+  <div class="controls">
+    <button type="button" data-action="play">Play</button>
+    <select class="speed">
+      <option value="1">1×</option>
+      <option value="2">2×</option>
+      <option value="5" selected="selected">5×</option>
+      <option value="10">10×</option>
+      <option value="30">30×</option>
+    </select>
+    <input type="range" class="timeline" min="0" max="{{.Data.Duration}}" value="0">
+    <span class="time"></span>
+    <button type="button" data-action="heatmap">Heatmap</button>
+  </div>
+  <div class="stats">
+    <h3>Where the time went</h3>
+    <table>
+      <tr><th>Entry</th><th>Answer</th><th>Time</th><th>Errors</th></tr>
+      {{- range .Stats}}
+      <tr{{if not .Complete}} class="unfinished"{{end}}><td>{{.Entry.Name}}</td><td>{{.Entry.Answer}}</td><td>{{clock .Time}}</td><td>{{.Errors}}</td></tr>
+      {{- end}}
+    </table>
+  </div>
+  {{- template "clues" cluelists .Puzzle}}
+</div>
+<script>
+var xwdReplay = {{.Data}};
+{{.Script}}
+</script>
+</body>
+</html>
+{{end}}
+`))
+
+// htmlReplay is the data about a recording made available to the replay
+// script.
+type htmlReplay struct {
+	Cols     int       `json:"cols"`
+	Duration int64     `json:"duration"` // In milliseconds
+	Events   []Event   `json:"events"`
+	Heat     []float64 `json:"heat"` // For each cell, row by row, from 0 to 1
+}
+
+// WriteReplayHTML writes a page to w that replays a recording of a solve of
+// the puzzle, with controls to play and pause it, change its speed, and move
+// back and forth through it on a timeline. The page also shows where the
+// solver got stuck: a heatmap over the grid of how long the entries took to
+// fill, and a list of the entries ranked by time (see Recording.EntryStats).
+func WriteReplayHTML(w io.Writer, p *Puzzle, rec *Recording) error {
+	stats, err := rec.EntryStats(p)
+	if err != nil {
+		return err
+	}
+	RankEntryStats(stats)
+	return replayTemplate.ExecuteTemplate(w, "replay", map[string]interface{}{
+		"Puzzle":    p,
+		"Recording": rec,
+		"Stats":     stats,
+		"Data": htmlReplay{
+			Cols:     p.Cols,
+			Duration: rec.Duration().Milliseconds(),
+			Events:   rec.Events,
+			Heat:     heatmap(p, stats),
+		},
+		"CSS":    template.CSS(gridCSS + solverCSS + replayCSS),
+		"Script": template.JS(replayJS),
+	})
+}
+
+var spectatorTemplate = template.Must(template.Must(htmlTemplates.Clone()).Parse(`
+{{define "spectator"}}<!DOCTYPE html>
+<html>
+<head>
+  <meta charset="UTF-8">
+  <meta name="viewport" content="width=device-width, initial-scale=1">
+  <title>Race: {{.Puzzle.Title}}</title>
+  <style>{{.CSS}}</style>
+</head>
+<body>
+<div class="xwd">
+  <h1>{{.Puzzle.Title}}</h1>
+  {{- if .Puzzle.Author}}
+  <p class="author">{{.Puzzle.Author}}</p>
+  {{- end}}
+  <div class="controls">
+    <span class="race-status"></span>
+  </div>
+  <div class="blank">
+    {{- template "grid" .}}
+  </div>
+  <div class="boards"></div>
+  {{- template "clues" cluelists .Puzzle}}
+</div>
+<script>
+var xwdSpectator = {{.Data}};
+{{.Script}}
+</script>
+</body>
+</html>
+{{end}}
+`))
+
+// htmlSpectator is the data made available to the spectator script.
+type htmlSpectator struct {
+	View string `json:"view"` // The URL from which the RaceView is polled
+}
+
+// WriteSpectatorHTML writes a page to w from which a race on the puzzle can
+// be watched, but not played: it shows each player's grid, the cell they last
+// changed and their time, polling the given URL for the RaceView.
+func WriteSpectatorHTML(w io.Writer, p *Puzzle, view string) error {
+	return spectatorTemplate.ExecuteTemplate(w, "spectator", map[string]interface{}{
+		"Puzzle": p,
+		"Data":   htmlSpectator{View: view},
+		"CSS":    template.CSS(gridCSS + solverCSS + spectatorCSS),
+		"Script": template.JS(spectatorJS),
+	})
+}
+
+// heatmap returns the heat of each cell of the puzzle, row by row: the time
+// taken to fill the slowest entry through the cell, as a fraction of the time
+// taken to fill the slowest entry of all.
+func heatmap(p *Puzzle, stats []EntryStats) []float64 {
+	heat := make([]float64, p.Rows*p.Cols)
+	var max time.Duration
+	for _, s := range stats {
+		if s.Time > max {
+			max = s.Time
+		}
+	}
+	if max == 0 {
+		return heat
+	}
+	for _, s := range stats {
+		h := math.Round(float64(s.Time)/float64(max)*100) / 100
+		for _, c := range s.Entry.Cells {
+			if k := c[0]*p.Cols + c[1]; h > heat[k] {
+				heat[k] = h
+			}
+		}
+	}
+	return heat
+}
+
+// Index describes a page listing a directory of puzzles, as served by xwdweb
+// and generated by "xwd site". All URLs may be relative to the page.
+type Index struct {
+	Title   string
+	Parent  string // The URL of the parent index, if any
+	Search  string // The URL of a JSON search index, if searching is offered
+	Dirs    []IndexLink
+	Puzzles []IndexPuzzle
+}
+
+// IndexLink is a named link on an index page.
+type IndexLink struct {
+	Name string
+	URL  string
+}
+
+// IndexPuzzle is an entry for a single puzzle on an index page.
+type IndexPuzzle struct {
+	Name      string  // The file name of the puzzle
+	Puzzle    *Puzzle // The loaded puzzle, or nil if it failed to load
+	Solve     string  // The URL of the solver page
+	Print     string  // The URL of the printable version
+	Downloads []IndexLink
+}
+
+// SearchEntry is an entry in the JSON search index used by index pages.
+type SearchEntry struct {
+	Title     string `json:"title"`
+	Author    string `json:"author"`
+	Copyright string `json:"copyright"`
+	URL       string `json:"url"`
+}
+
+var indexTemplate = template.Must(template.New("index").Parse(`<!DOCTYPE html>
+<html>
+<head>
+  <meta charset="UTF-8">
+  <meta name="viewport" content="width=device-width, initial-scale=1">
+  <title>{{.Index.Title}}</title>
+  <style>{{.CSS}}</style>
+</head>
+<body>
+<div class="xwd-index">
+  <h1>{{.Index.Title}}</h1>
+  {{- if .Index.Parent}}
+  <p><a href="{{.Index.Parent}}">Up</a></p>
+  {{- end}}
+  {{- if .Index.Search}}
+  <p><input type="search" class="search" placeholder="Search by title or author" data-index="{{.Index.Search}}"></p>
+  <ul class="results"></ul>
+  {{- end}}
+  {{- if .Index.Dirs}}
+  <ul class="dirs">
+    {{- range .Index.Dirs}}
+    <li><a href="{{.URL}}">{{.Name}}</a></li>
+    {{- end}}
+  </ul>
+  {{- end}}
+  {{- if .Index.Puzzles}}
+  <table class="puzzles">
+    {{- range .Index.Puzzles}}
+    <tr>
+      {{- if .Puzzle}}
+      <td><a href="{{.Solve}}">{{if .Puzzle.Title}}{{.Puzzle.Title}}{{else}}{{.Name}}{{end}}</a></td>
+      <td>{{.Puzzle.Author}}</td>
+      <td>{{if .Print}}<a href="{{.Print}}">print</a>{{end}}</td>
+      <td>
+        {{- range .Downloads}}
+        <a href="{{.URL}}">{{.Name}}</a>
+        {{- end}}
+      </td>
+      {{- else}}
+      <td>{{.Name}}</td>
+      <td colspan="3" class="error">failed to load</td>
+      {{- end}}
+    </tr>
+    {{- end}}
+  </table>
+  {{- end}}
+</div>
+{{- if .Index.Search}}
+<script>{{.Script}}</script>
+{{- end}}
+</body>
+</html>
+`))
+
+// WriteIndex writes an HTML index page listing directories and puzzles.
+func WriteIndex(w io.Writer, idx *Index) error {
+	return indexTemplate.Execute(w, map[string]interface{}{
+		"Index":  idx,
+		"CSS":    template.CSS(indexCSS),
+		"Script": template.JS(searchJS),
+	})
+}
+
+// obfuscate XORs data with a keystream generated by a xorshift32 generator
+// seeded with key. Applying it twice with the same key returns the original
+// data. The solver script contains the inverse.
+func obfuscate(data []byte, key uint32) []byte {
+	out := make([]byte, len(data))
+	x := key
+	for i, b := range data {
+		x ^= x << 13
+		x ^= x >> 17
+		x ^= x << 5
+		out[i] = b ^ byte(x)
+	}
+	return out
+}
@@ -0,0 +1,159 @@
+package xwd
+
+import (
+	"bytes"
+	"html/template"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestObfuscate(t *testing.T) {
+	in := []byte(`["C","A","T",""]`)
+	out := obfuscate(in, 0xdeadbeef)
+	if bytes.Equal(in, out) {
+		t.Errorf("obfuscated data should differ from the input")
+	}
+	if back := obfuscate(out, 0xdeadbeef); !bytes.Equal(in, back) {
+		t.Errorf("obfuscating twice should give the input (expected: %s, got: %s)", in, back)
+	}
+}
+
+func TestWriteHTML(t *testing.T) {
+	p := loadFixture(t, "version_13.puz")
+
+	for _, check := range []bool{false, true} {
+		var buf bytes.Buffer
+		err := WriteHTML(&buf, p, HTMLOptions{Check: check})
+		if err != nil {
+			t.Fatal(err)
+		}
+		out := buf.String()
+
+		if !strings.Contains(out, template.HTMLEscapeString(p.CluesAcross()[0].Clue)) {
+			t.Errorf("HTML output should contain the clues")
+		}
+		if strings.Contains(out, "STINGER") {
+			t.Errorf("HTML output should not contain plain solutions (check: %v)", check)
+		}
+		if hasCheck := strings.Contains(out, `data-action="check-puzzle"`); hasCheck != check {
+			t.Errorf("check button expectation failure (expected: %v, got: %v)", check, hasCheck)
+		}
+	}
+}
+
+func TestWriteHTMLCustomEntries(t *testing.T) {
+	p := &Puzzle{Rows: 3, Cols: 3}
+	err := p.SetSolution([]string{"CAT", "O.A", "WEN"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = p.AddEntry("Diagonal", [][2]int{{0, 2}, {2, 0}}, "Tree, on the slant")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	err = WriteHTML(&buf, p, HTMLOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	if !strings.Contains(out, `<li data-num="1"><span class="label">Diagonal</span> Tree, on the slant</li>`) {
+		t.Errorf("HTML output should list the custom clue")
+	}
+	if !strings.Contains(out, `"dir":"custom","num":1,"cells":[[0,2],[2,0]]`) {
+		t.Errorf("HTML output should give the custom entry's cells to the solver script")
+	}
+}
+
+func TestWriteReplayHTML(t *testing.T) {
+	p := loadFixture(t, "version_13.puz")
+	rec := &Recording{
+		Puzzle: p.Fingerprint(),
+		Player: "Nick",
+		Events: []Event{{Time: 1500 * time.Millisecond, Row: 0, Col: 0, Letter: "S", Flags: FlagPencil}},
+	}
+	var buf bytes.Buffer
+	err := WriteReplayHTML(&buf, p, rec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	if !strings.Contains(out, "Solved by Nick") {
+		t.Errorf("replay page should name the player")
+	}
+	if !strings.Contains(out, `"duration":1500,"events":[{"t":1500,"i":0,"j":0,"l":"S","f":8}]`) {
+		t.Errorf("replay page should give the events to the replay script")
+	}
+	if !strings.Contains(out, "<td>1 across</td>") {
+		t.Errorf("replay page should list the time taken by each entry")
+	}
+}
+
+func TestWriteSpectatorHTML(t *testing.T) {
+	var buf bytes.Buffer
+	err := WriteSpectatorHTML(&buf, loadFixture(t, "version_13.puz"), "foo.puz?race=abc&view")
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	if !strings.Contains(out, `var xwdSpectator = {"view":"foo.puz?race=abc\u0026view"};`) {
+		t.Errorf("spectator page should give the view URL to the spectator script")
+	}
+	if strings.Contains(out, `data-action=`) {
+		t.Errorf("spectator page shouldn't have any solving controls")
+	}
+}
+
+func TestWriteHTMLRace(t *testing.T) {
+	var buf bytes.Buffer
+	err := WriteHTML(&buf, loadFixture(t, "version_13.puz"), HTMLOptions{Race: "foo.puz?race=abc"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	if !strings.Contains(out, `data-action="join"`) || strings.Contains(out, `data-action="race"`) {
+		t.Errorf("race page should offer to join the race, not to create another")
+	}
+	if !strings.Contains(out, `"race":"foo.puz?race=abc"`) {
+		t.Errorf("race page should give the race's URL to the solver script")
+	}
+	if strings.Contains(out, "watch") {
+		t.Errorf("race page shouldn't lead the players to the spectator view")
+	}
+}
+
+func TestWriteHTMLPrint(t *testing.T) {
+	var buf bytes.Buffer
+	err := WriteHTML(&buf, loadFixture(t, "version_13.puz"), HTMLOptions{Check: true, Print: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(buf.String(), "<script>") {
+		t.Errorf("printable HTML output should not contain the solver script")
+	}
+}
+
+func TestWriteIndex(t *testing.T) {
+	idx := &Index{
+		Title:  "Puzzles",
+		Search: "search.json",
+		Dirs:   []IndexLink{{Name: "2008", URL: "2008/index.html"}},
+		Puzzles: []IndexPuzzle{
+			{Name: "a.puz", Puzzle: loadFixture(t, "version_13.puz"), Solve: "a.html", Print: "a.print.html"},
+			{Name: "broken.puz"},
+		},
+	}
+	var buf bytes.Buffer
+	err := WriteIndex(&buf, idx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	for _, s := range []string{`href="2008/index.html"`, `href="a.html">Animalia`, `href="a.print.html"`, "broken.puz", `data-index="search.json"`} {
+		if !strings.Contains(out, s) {
+			t.Errorf("index output should contain %q", s)
+		}
+	}
+}
@@ -0,0 +1,1045 @@
+package xwd
+
+// solverCSS styles the interactive parts of the page written by WriteHTML.
+const solverCSS = `
+body { font-family: sans-serif; margin: 1em; }
+.xwd h1, .xwd .author, .xwd .copyright { text-align: center; }
+.xwd .author { font-style: italic; }
+.xwd .copyright { font-size: 0.8em; color: #666; }
+.xwd table.grid td.white { cursor: pointer; }
+.xwd table.grid td.active { background: #cfe3ff; }
+.xwd table.grid td.selected { background: #ffe066; }
+.xwd table.grid td.pencil .letter { color: #999; }
+.xwd table.grid td.rebus .letter { font-size: 0.6em; }
+.xwd table.grid td.selected.editing { outline: 2px solid #c90; outline-offset: -2px; }
+.xwd table.grid td.incorrect .letter { color: #c00; }
+.xwd table.grid td.incorrect::after { content: ""; position: absolute; top: 0; right: 0; border-style: solid; border-width: 0 0.5em 0.5em 0; border-color: transparent #c00 transparent transparent; }
+.xwd .controls { text-align: center; margin-bottom: 1em; }
+.xwd .controls button.on { background: #ffe066; }
+.xwd .status { margin-left: 1em; font-weight: bold; }
+.xwd .clues li { cursor: pointer; padding: 0.1em 0.3em; }
+.xwd .clues li.active { background: #cfe3ff; }
+.xwd.waiting .clues { visibility: hidden; }
+.xwd .controls .countdown { margin-left: 1em; font-variant-numeric: tabular-nums; }
+.xwd .controls .countdown.urgent { color: #c00; font-weight: bold; }
+.xwd .race .racers { max-width: 30em; margin: 0.5em auto 0; }
+.xwd .race .racer { display: flex; align-items: center; margin: 0.2em 0; }
+.xwd .race .racer .name { width: 8em; padding-right: 0.5em; text-align: right; overflow: hidden; text-overflow: ellipsis; white-space: nowrap; }
+.xwd .race .racer .bar { flex: 1; height: 0.8em; background: #eee; }
+.xwd .race .racer .bar span { display: block; height: 100%; background: #4a90d9; }
+.xwd .race .racer .result { width: 7em; padding-left: 0.5em; text-align: left; font-variant-numeric: tabular-nums; }
+`
+
+// printCSS adjusts the printable version of the page written by WriteHTML.
+const printCSS = `
+@media print {
+  body { margin: 0; font-size: 10pt; }
+  .xwd .clues { page-break-inside: avoid; }
+}
+`
+
+// indexCSS styles the index pages written by WriteIndex.
+const indexCSS = `
+body { font-family: sans-serif; margin: 1em; }
+.xwd-index table.puzzles { border-collapse: collapse; }
+.xwd-index table.puzzles td { padding: 0.2em 0.8em 0.2em 0; }
+.xwd-index .error { color: #c00; }
+.xwd-index input.search { width: 20em; }
+`
+
+// searchJS filters the JSON search index named by the search box on an index
+// page as the user types.
+const searchJS = `
+(function () {
+  "use strict";
+
+  var input = document.querySelector(".xwd-index input.search");
+  var results = document.querySelector(".xwd-index .results");
+  var index = null;
+
+  fetch(input.dataset.index).then(function (r) { return r.json(); }).then(function (data) {
+    index = data;
+  });
+
+  input.addEventListener("input", function () {
+    var q = input.value.trim().toLowerCase();
+    results.textContent = "";
+    if (!index || q === "") {
+      return;
+    }
+    index.filter(function (e) {
+      return (e.title + " " + e.author).toLowerCase().indexOf(q) !== -1;
+    }).slice(0, 50).forEach(function (e) {
+      var li = document.createElement("li");
+      var a = document.createElement("a");
+      a.href = e.url;
+      a.textContent = e.title + (e.author ? " (" + e.author + ")" : "");
+      li.appendChild(a);
+      results.appendChild(li);
+    });
+  });
+})();
+`
+
+// replayCSS styles the controls and stats of the page written by
+// WriteReplayHTML.
+const replayCSS = `
+.xwd .controls input.timeline { width: 20em; vertical-align: middle; }
+.xwd .controls .time { font-variant-numeric: tabular-nums; margin: 0 0.5em; }
+.xwd .stats table { border-collapse: collapse; margin: 0 auto 1em; }
+.xwd .stats th, .xwd .stats td { padding: 0.1em 0.75em; text-align: left; }
+.xwd .stats td:nth-child(n+3) { font-variant-numeric: tabular-nums; text-align: right; }
+.xwd .stats tr.unfinished { color: #999; }
+`
+
+// replayJS is the script embedded by WriteReplayHTML. It expects the variable
+// xwdReplay to hold the JSON-encoded htmlReplay data, and the page to contain
+// the output of the "grid" template.
+const replayJS = `
+(function () {
+  "use strict";
+
+  var data = xwdReplay;
+  var root = document.querySelector(".xwd");
+  var playButton = root.querySelector(".controls button[data-action='play']");
+  var speed = root.querySelector(".controls .speed");
+  var timeline = root.querySelector(".controls .timeline");
+  var clock = root.querySelector(".controls .time");
+  var heatButton = root.querySelector(".controls button[data-action='heatmap']");
+  var cells = {};
+  var now = 0;     // The time shown, in milliseconds into the recording
+  var next = 0;    // The index of the first event not yet shown
+  var playing = false;
+  var last = null; // The time of the previous animation frame
+
+  root.querySelectorAll("table.grid td.white").forEach(function (td) {
+    var letter = document.createElement("span");
+    letter.className = "letter";
+    td.appendChild(letter);
+    var heat = data.heat[td.dataset.i * data.cols + +td.dataset.j] || 0;
+    cells[td.dataset.i + "," + td.dataset.j] = {td: td, letter: letter, heat: heat};
+  });
+
+  // heatmap colours each cell by how long the entries through it took to
+  // fill, or, if on is false, removes the colouring.
+  function heatmap(on) {
+    Object.keys(cells).forEach(function (k) {
+      var c = cells[k];
+      c.td.style.backgroundColor = on && c.heat ? "rgba(220, 40, 40, " + (0.8 * c.heat).toFixed(2) + ")" : "";
+    });
+    heatButton.classList.toggle("on", on);
+  }
+
+  function show(c, letter, flags) {
+    c.letter.textContent = letter;
+    c.td.classList.toggle("pencil", (flags & 0x08) !== 0);
+    c.td.classList.toggle("incorrect", (flags & 0x20) !== 0);
+    c.td.classList.toggle("rebus", letter.length > 1);
+  }
+
+  function format(ms) {
+    var s = Math.floor(ms / 1000);
+    return Math.floor(s / 60) + ":" + ("0" + s % 60).slice(-2);
+  }
+
+  // seek shows the grid as it was at time t, replaying the events from the
+  // start if t is earlier than the time shown.
+  function seek(t) {
+    if (t < now) {
+      Object.keys(cells).forEach(function (k) {
+        show(cells[k], "", 0);
+      });
+      next = 0;
+    }
+    var changed = null;
+    while (next < data.events.length && data.events[next].t <= t) {
+      var e = data.events[next++];
+      var c = cells[e.i + "," + e.j];
+      if (c) {
+        show(c, e.l, e.f || 0);
+        changed = c;
+      }
+    }
+    if (changed || t < now) {
+      root.querySelectorAll(".selected").forEach(function (el) {
+        el.classList.remove("selected");
+      });
+      if (changed) {
+        changed.td.classList.add("selected");
+      }
+    }
+    now = t;
+    timeline.value = t;
+    clock.textContent = format(t) + " / " + format(data.duration);
+  }
+
+  function tick(ts) {
+    if (!playing) {
+      return;
+    }
+    if (last !== null) {
+      var t = now + (ts - last) * +speed.value;
+      if (t >= data.duration) {
+        t = data.duration;
+        pause();
+      }
+      seek(t);
+    }
+    last = ts;
+    requestAnimationFrame(tick);
+  }
+
+  function play() {
+    if (now >= data.duration) {
+      seek(0);
+    }
+    playing = true;
+    last = null;
+    playButton.textContent = "Pause";
+    requestAnimationFrame(tick);
+  }
+
+  function pause() {
+    playing = false;
+    playButton.textContent = "Play";
+  }
+
+  playButton.addEventListener("click", function () {
+    playing ? pause() : play();
+  });
+  timeline.addEventListener("input", function () {
+    seek(+timeline.value);
+  });
+  heatButton.addEventListener("click", function () {
+    heatmap(!heatButton.classList.contains("on"));
+  });
+
+  seek(0);
+})();
+`
+
+// spectatorCSS lays out the players' grids on the page written by
+// WriteSpectatorHTML.
+const spectatorCSS = `
+.xwd .blank { display: none; }
+.xwd .boards { display: flex; flex-wrap: wrap; justify-content: center; gap: 0 2em; }
+.xwd .board h3 { text-align: center; margin: 0; }
+.xwd .board .result { font-weight: normal; font-variant-numeric: tabular-nums; margin-left: 0.5em; }
+.xwd .board.finished h3 { color: #2a7a2a; }
+.xwd .board table.grid { margin: 0.5em auto 1em; }
+.xwd .board table.grid td { width: 1.5em; height: 1.5em; cursor: default; }
+.xwd .board table.grid .letter { font-size: 0.9em; }
+`
+
+// spectatorJS is the script embedded by WriteSpectatorHTML. It expects the
+// variable xwdSpectator to hold the JSON-encoded htmlSpectator data, and the
+// page to contain the output of the "grid" template, which it copies for each
+// player.
+const spectatorJS = `
+(function () {
+  "use strict";
+
+  var data = xwdSpectator;
+  var root = document.querySelector(".xwd");
+  var status = root.querySelector(".race-status");
+  var boards = root.querySelector(".boards");
+  var blank = root.querySelector(".blank table.grid");
+  var shown = []; // The boards, in the order of the players
+  var view = null;
+
+  function clock(ms) {
+    var s = Math.max(0, Math.floor(ms / 1000));
+    return Math.floor(s / 60) + ":" + ("0" + s % 60).slice(-2);
+  }
+
+  function ordinal(n) {
+    var suffix = ["th", "st", "nd", "rd"][n % 100 >= 11 && n % 100 <= 13 ? 0 : n % 10] || "th";
+    return n + suffix;
+  }
+
+  // board adds a copy of the grid for a player.
+  function board() {
+    var b = {div: document.createElement("div"), cells: []};
+    b.div.className = "board";
+    var h = document.createElement("h3");
+    b.name = document.createElement("span");
+    b.result = document.createElement("span");
+    b.result.className = "result";
+    h.appendChild(b.name);
+    h.appendChild(b.result);
+    b.div.appendChild(h);
+    var grid = blank.cloneNode(true);
+    grid.querySelectorAll("td.white").forEach(function (td) {
+      var letter = document.createElement("span");
+      letter.className = "letter";
+      td.appendChild(letter);
+      b.cells.push({i: +td.dataset.i, j: +td.dataset.j, td: td, letter: letter});
+    });
+    b.div.appendChild(grid);
+    boards.appendChild(b.div);
+    return b;
+  }
+
+  function show(v) {
+    view = v;
+    if (!v.started) {
+      status.textContent = v.players.length === 0 ? "Waiting for players" : "Waiting to start";
+    } else if (v.elapsed < 0) {
+      status.textContent = "Starting in " + Math.ceil(-v.elapsed / 1000) + "…";
+    } else if (v.over) {
+      status.textContent = v.winner + " won, in " + clock(v.players.filter(function (p) { return p.place === 1; })[0].finished);
+    } else {
+      status.textContent = clock(v.elapsed) + (v.winner ? " (" + v.winner + " won)" : "");
+    }
+    if (v.delay) {
+      status.textContent += ", " + Math.round(v.delay / 1000) + "s behind";
+    }
+
+    v.players.forEach(function (p, k) {
+      var b = shown[k] || (shown[k] = board());
+      var grid = v.grids[k];
+      b.name.textContent = p.name;
+      b.result.textContent = p.finished ? ordinal(p.place) + ", " + clock(p.finished) : Math.round(p.progress * 100) + "%";
+      b.div.classList.toggle("finished", !!p.finished);
+      b.cells.forEach(function (c) {
+        var letter = grid.letters[c.i][c.j];
+        c.letter.textContent = letter;
+        c.td.classList.toggle("rebus", letter.length > 1);
+        c.td.classList.toggle("selected", !!grid.cursor && grid.cursor[0] === c.i && grid.cursor[1] === c.j);
+      });
+    });
+  }
+
+  function poll() {
+    fetch(data.view).then(function (r) {
+      if (!r.ok) {
+        return r.text().then(function (msg) {
+          throw new Error(msg.trim() || r.statusText);
+        });
+      }
+      return r.json();
+    }).then(show).catch(function (err) {
+      status.textContent = err.message;
+    }).then(function () {
+      if (!view || !view.over) {
+        setTimeout(poll, 1000);
+      }
+    });
+  }
+
+  poll();
+})();
+`
+
+// solverJS is the solver script embedded by WriteHTML. It expects the
+// variable xwdPuzzle to hold the JSON-encoded htmlPuzzle data, and the page to
+// contain the output of the "grid" and "clues" templates.
+const solverJS = `
+(function () {
+  "use strict";
+
+  var data = xwdPuzzle;
+  var root = document.querySelector(".xwd");
+  var status = root.querySelector(".status");
+  var storageKey = "xwd:" + data.id + (data.race ? ":race:" + data.race : "") + (data.submit ? ":submit:" + data.submit : "");
+  var cells = [];
+  var cur = null;
+  var dir = "across";
+  var active = null;
+  var pencil = false;
+  var rebus = false;
+  var autocheck = false;
+  var assisted = false; // Whether autocheck has been used on this puzzle
+  var start = Date.now();
+  var events = []; // Every change, for replaying the solve
+  var rebusButton = root.querySelector(".controls button[data-action='rebus']");
+  var solution = null;
+  var race = null;      // The state of the race, as last polled
+  var raceKey = "xwd:race:" + data.race;
+  var racer = null;     // The solver's name and token in the race, once joined
+  var sent = 0;         // How many of the events have been sent to the race
+  var locked = false;   // Whether the grid is locked, as the race hasn't started or the grid's been submitted
+  var submitted = false;
+  var deadline = data.remaining ? Date.now() + data.remaining : null;
+
+  for (var i = 0; i < data.rows; i++) {
+    cells.push(new Array(data.cols).fill(null));
+  }
+
+  root.querySelectorAll("table.grid td.white").forEach(function (td) {
+    var i = +td.dataset.i, j = +td.dataset.j;
+    var letter = document.createElement("span");
+    letter.className = "letter";
+    td.appendChild(letter);
+    cells[i][j] = {i: i, j: j, td: td, letter: letter, entries: []};
+    td.addEventListener("click", function () {
+      if (cur && cur.i === i && cur.j === j) {
+        toggleDir();
+      } else {
+        select(i, j);
+      }
+    });
+  });
+
+  // Each cell lists the entries it's in: usually an across and a down entry,
+  // but custom entries may follow any path, and a cell may be in several.
+  data.entries.forEach(function (e) {
+    e.cells.forEach(function (c) {
+      cells[c[0]][c[1]].entries.push(e);
+    });
+    e.li = root.querySelector(".clues ." + e.dir + " li[data-num='" + e.num + "']");
+    if (e.li) {
+      e.li.addEventListener("click", function () {
+        select(e.cells[0][0], e.cells[0][1], e);
+      });
+    }
+  });
+
+  function decode(s, key) {
+    var bin = atob(s), bytes = new Uint8Array(bin.length), x = key >>> 0;
+    for (var k = 0; k < bin.length; k++) {
+      x ^= x << 13; x >>>= 0;
+      x ^= x >>> 17;
+      x ^= x << 5; x >>>= 0;
+      bytes[k] = bin.charCodeAt(k) ^ (x & 0xff);
+    }
+    return JSON.parse(new TextDecoder().decode(bytes));
+  }
+
+  if (data.solution) {
+    solution = decode(data.solution, data.key);
+  }
+
+  function cell(i, j) {
+    if (i < 0 || i >= data.rows || j < 0 || j >= data.cols) {
+      return null;
+    }
+    return cells[i][j];
+  }
+
+  function entry() {
+    return active;
+  }
+
+  function entryIn(c, d) {
+    for (var k = 0; k < c.entries.length; k++) {
+      if (c.entries[k].dir === d) {
+        return c.entries[k];
+      }
+    }
+    return null;
+  }
+
+  // select moves the cursor to a cell, keeping the current entry if the cell
+  // is in it, and otherwise choosing one in the same direction if possible.
+  function select(i, j, e) {
+    var c = cell(i, j);
+    if (!c) {
+      return;
+    }
+    if (rebus && c !== cur) {
+      stopRebus();
+    }
+    cur = c;
+    if (e) {
+      active = e;
+    } else if (c.entries.indexOf(active) < 0) {
+      active = entryIn(c, dir) || c.entries[0] || null;
+    }
+    if (active) {
+      dir = active.dir;
+    }
+    render();
+  }
+
+  // toggleDir switches to the next of the entries the current cell is in.
+  function toggleDir() {
+    if (!cur || cur.entries.length < 2) {
+      return;
+    }
+    var k = cur.entries.indexOf(active);
+    active = cur.entries[(k + 1) % cur.entries.length];
+    dir = active.dir;
+    render();
+  }
+
+  // arrow handles an arrow key, moving in the given direction if the current
+  // entry runs that way (or is a custom entry, which could run any way), and
+  // otherwise switching to the cell's entry that does.
+  function arrow(di, dj, d) {
+    var e = entryIn(cur, d);
+    if (dir === d || dir === "custom" || !e) {
+      move(di, dj);
+      return;
+    }
+    active = e;
+    dir = d;
+    render();
+  }
+
+  function render() {
+    root.querySelectorAll(".selected, .active").forEach(function (el) {
+      el.classList.remove("selected", "active");
+    });
+    if (!cur) {
+      return;
+    }
+    var e = entry();
+    if (e) {
+      e.cells.forEach(function (c) {
+        cells[c[0]][c[1]].td.classList.add("active");
+      });
+      if (e.li) {
+        e.li.classList.add("active");
+      }
+    }
+    cur.td.classList.add("selected");
+  }
+
+  // step moves the cursor by one cell within the current entry, returning
+  // false if it would leave the entry.
+  function step(delta) {
+    var e = entry();
+    if (!e) {
+      return false;
+    }
+    for (var k = 0; k < e.cells.length; k++) {
+      if (e.cells[k][0] === cur.i && e.cells[k][1] === cur.j) {
+        var next = e.cells[k + delta];
+        if (!next) {
+          return false;
+        }
+        cur = cells[next[0]][next[1]];
+        render();
+        return true;
+      }
+    }
+    return false;
+  }
+
+  function move(di, dj) {
+    if (!cur) {
+      return;
+    }
+    for (var i = cur.i + di, j = cur.j + dj; i >= 0 && i < data.rows && j >= 0 && j < data.cols; i += di, j += dj) {
+      if (cells[i][j]) {
+        select(i, j);
+        return;
+      }
+    }
+  }
+
+  function nextEntry(delta) {
+    var e = entry();
+    var k = (data.entries.indexOf(e) + delta + data.entries.length) % data.entries.length;
+    var next = data.entries[k];
+    select(next.cells[0][0], next.cells[0][1], next);
+  }
+
+  // set enters a letter in a cell, pencilled in if pencil mode is on.
+  function set(c, value) {
+    c.letter.textContent = value;
+    c.pencil = pencil && value !== "";
+    c.td.classList.toggle("pencil", c.pencil);
+    c.td.classList.toggle("rebus", value.length > 1);
+    c.td.classList.remove("incorrect");
+    if (autocheck) {
+      check([c]);
+    }
+    record(c);
+    save();
+  }
+
+  // record records the current state of a cell as an event, in the form read
+  // by xwd.ReadRecording.
+  function record(c) {
+    var flags = (c.pencil ? 0x08 : 0) | (c.td.classList.contains("incorrect") ? 0x20 : 0);
+    events.push({t: Date.now() - start, i: c.i, j: c.j, l: c.letter.textContent, f: flags});
+  }
+
+  // grid returns the letters in the grid, row by row, with "" for empty and
+  // black cells.
+  function grid() {
+    return cells.map(function (row) {
+      return row.map(function (c) { return c ? c.letter.textContent : ""; });
+    });
+  }
+
+  function save() {
+    var fill = grid();
+    var pencilled = cells.map(function (row) {
+      return row.map(function (c) { return c && c.pencil ? 1 : 0; });
+    });
+    try {
+      localStorage.setItem(storageKey, JSON.stringify({
+        fill: fill,
+        pencil: pencilled,
+        assisted: assisted,
+        start: start,
+        events: events,
+        submitted: submitted
+      }));
+    } catch (err) {}
+    if (solution && solved()) {
+      status.textContent = assisted ? "Solved! (with autocheck)" : "Solved!";
+    } else {
+      status.textContent = "";
+    }
+  }
+
+  function restore() {
+    var saved = null;
+    try {
+      saved = JSON.parse(localStorage.getItem(storageKey));
+    } catch (err) {}
+    if (!saved) {
+      return;
+    }
+    // Progress used to be saved as just the grid of letters.
+    var fill = Array.isArray(saved) ? saved : saved.fill;
+    var pencilled = saved.pencil || [];
+    assisted = !!saved.assisted;
+    start = saved.start || start;
+    events = saved.events || events;
+    submitted = !!saved.submitted;
+    locked = submitted;
+    cells.forEach(function (row, i) {
+      row.forEach(function (c, j) {
+        if (c && fill[i] && fill[i][j]) {
+          c.letter.textContent = fill[i][j];
+          c.pencil = !!(pencilled[i] && pencilled[i][j]);
+          c.td.classList.toggle("pencil", c.pencil);
+          c.td.classList.toggle("rebus", fill[i][j].length > 1);
+        }
+      });
+    });
+  }
+
+  function correct(c) {
+    return c.letter.textContent === solution[c.i * data.cols + c.j];
+  }
+
+  function solved() {
+    return cells.every(function (row) {
+      return row.every(function (c) { return !c || correct(c); });
+    });
+  }
+
+  function check(list) {
+    list.forEach(function (c) {
+      if (c.letter.textContent !== "" && !correct(c) && !c.td.classList.contains("incorrect")) {
+        c.td.classList.add("incorrect");
+        record(c);
+      }
+    });
+  }
+
+  function allCells() {
+    var list = [];
+    cells.forEach(function (row) {
+      row.forEach(function (c) {
+        if (c) {
+          list.push(c);
+        }
+      });
+    });
+    return list;
+  }
+
+  var actions = {
+    "check-letter": function () {
+      if (cur) {
+        check([cur]);
+      }
+    },
+    "check-word": function () {
+      var e = entry();
+      if (e) {
+        check(e.cells.map(function (c) { return cells[c[0]][c[1]]; }));
+      }
+    },
+    "check-puzzle": function () {
+      check(allCells());
+    },
+    "rebus": function () {
+      toggleRebus();
+    },
+    "autocheck": function (b) {
+      autocheck = !autocheck;
+      b.classList.toggle("on", autocheck);
+      if (autocheck) {
+        assisted = true;
+        check(allCells());
+        save();
+      }
+    },
+    "share": function () {
+      var player = askName("Your name, to show with the replay:");
+      if (player === null) {
+        return;
+      }
+      post(data.share, {
+        puzzle: data.id,
+        player: player,
+        start: new Date(start).toISOString(),
+        assisted: assisted,
+        events: events
+      }).then(function (r) {
+        status.textContent = r.ok ? "Replay shared" : "Sharing failed";
+      }, function () {
+        status.textContent = "Sharing failed";
+      });
+    },
+    "pencil": function (b) {
+      pencil = !pencil;
+      b.classList.toggle("on", pencil);
+    },
+    "race": function () {
+      post(data.newRace, {}).then(function (r) {
+        if (!r.ok) {
+          throw new Error(r.statusText);
+        }
+        window.location = r.headers.get("Location");
+      }).catch(function () {
+        status.textContent = "Couldn't create a race";
+      });
+    },
+    "join": function () {
+      var name = askName("Your name, to show to the other players:");
+      if (!name) {
+        return;
+      }
+      post(data.race + "&join", {name: name}).then(result).then(function (res) {
+        racer = {name: name, token: res.token};
+        try {
+          localStorage.setItem(raceKey, JSON.stringify(racer));
+        } catch (err) {}
+        return refresh();
+      }).catch(function (err) {
+        raceStatus.textContent = err.message;
+      });
+    },
+    "start": function () {
+      post(data.race + "&start", {token: racer && racer.token}).then(result).then(showRace).catch(function (err) {
+        raceStatus.textContent = err.message;
+      });
+    },
+    "submit": function () {
+      if (!submitted && confirm("Submit your grid? You can't change it afterwards.")) {
+        submit();
+      }
+    },
+    "clear": function () {
+      if (locked) {
+        return;
+      }
+      if (confirm("Clear the whole grid?")) {
+        allCells().forEach(function (c) {
+          if (c.letter.textContent === "") {
+            return;
+          }
+          c.letter.textContent = "";
+          c.pencil = false;
+          c.td.classList.remove("incorrect", "pencil", "rebus");
+          record(c);
+        });
+        save();
+      }
+    }
+  };
+
+  root.querySelectorAll(".controls button").forEach(function (b) {
+    b.addEventListener("click", function () {
+      actions[b.dataset.action](b);
+    });
+  });
+
+  // In rebus mode, letters typed are added to the current square rather than
+  // filling successive squares, so that a square can hold several. Leaving
+  // rebus mode moves on to the next square.
+  function toggleRebus() {
+    if (!cur) {
+      return;
+    }
+    if (rebus) {
+      stopRebus();
+      step(1);
+      return;
+    }
+    rebus = true;
+    if (rebusButton) {
+      rebusButton.classList.add("on");
+    }
+    cur.td.classList.add("editing");
+  }
+
+  function stopRebus() {
+    rebus = false;
+    if (rebusButton) {
+      rebusButton.classList.remove("on");
+    }
+    if (cur) {
+      cur.td.classList.remove("editing");
+    }
+  }
+
+  function rebusKey(ev) {
+    switch (ev.key) {
+    case "Enter":
+    case "Escape":
+    case "Insert":
+      toggleRebus();
+      break;
+    case "Backspace":
+      set(cur, cur.letter.textContent.slice(0, -1));
+      break;
+    default:
+      if (!/^[a-z0-9]$/i.test(ev.key)) {
+        return;
+      }
+      set(cur, cur.letter.textContent + ev.key.toUpperCase());
+    }
+    ev.preventDefault();
+  }
+
+  document.addEventListener("keydown", function (ev) {
+    if (!cur || locked || ev.ctrlKey || ev.metaKey || ev.altKey) {
+      return;
+    }
+    if (rebus) {
+      rebusKey(ev);
+      return;
+    }
+    switch (ev.key) {
+    case "Insert":
+      toggleRebus();
+      break;
+    case "ArrowLeft":
+      arrow(0, -1, "across");
+      break;
+    case "ArrowRight":
+      arrow(0, 1, "across");
+      break;
+    case "ArrowUp":
+      arrow(-1, 0, "down");
+      break;
+    case "ArrowDown":
+      arrow(1, 0, "down");
+      break;
+    case "Tab":
+      nextEntry(ev.shiftKey ? -1 : 1);
+      break;
+    case " ":
+      toggleDir();
+      break;
+    case "Backspace":
+      if (cur.letter.textContent === "") {
+        step(-1);
+      }
+      set(cur, "");
+      break;
+    case "Delete":
+      set(cur, "");
+      break;
+    default:
+      if (!/^[a-z0-9]$/i.test(ev.key)) {
+        return;
+      }
+      set(cur, ev.key.toUpperCase());
+      step(1);
+    }
+    ev.preventDefault();
+  });
+
+  function post(url, body) {
+    return fetch(url, {
+      method: "POST",
+      headers: {"Content-Type": "application/json"},
+      body: JSON.stringify(body)
+    });
+  }
+
+  // result returns the JSON body of a response, or fails with the error
+  // message the server gave.
+  function result(r) {
+    if (!r.ok) {
+      return r.text().then(function (msg) {
+        throw new Error(msg.trim() || r.statusText);
+      });
+    }
+    return r.json();
+  }
+
+  // askName asks the solver for their name, suggesting the one they gave
+  // last time. It returns null if they cancel.
+  function askName(question) {
+    var name = "";
+    try {
+      name = localStorage.getItem("xwd:player") || "";
+    } catch (err) {}
+    name = prompt(question, name);
+    if (name !== null) {
+      try {
+        localStorage.setItem("xwd:player", name);
+      } catch (err) {}
+    }
+    return name;
+  }
+
+  function clock(ms) {
+    var s = Math.floor(Math.max(ms, 0) / 1000);
+    return Math.floor(s / 60) + ":" + ("0" + s % 60).slice(-2);
+  }
+
+  function ordinal(n) {
+    var suffix = ["th", "st", "nd", "rd"][n % 100 >= 11 && n % 100 <= 13 ? 0 : n % 10] || "th";
+    return n + suffix;
+  }
+
+  // In a race, the page polls the server for the state of the race, sending
+  // the solver's changes once the race has started. Until then, the grid is
+  // locked and the clues hidden.
+  var raceStatus = root.querySelector(".race .race-status");
+  var racers = root.querySelector(".race .racers");
+  var joinButton = root.querySelector(".race button[data-action='join']");
+  var startButton = root.querySelector(".race button[data-action='start']");
+
+  function refresh() {
+    var req, batch = null;
+    if (racer && !locked) {
+      batch = events.slice(sent);
+      req = post(data.race + "&progress", {token: racer.token, events: batch});
+    } else {
+      req = fetch(data.race + "&status");
+    }
+    return req.then(result).then(function (st) {
+      if (batch) {
+        sent += batch.length;
+      }
+      showRace(st);
+    });
+  }
+
+  function poll() {
+    refresh().catch(function (err) {
+      raceStatus.textContent = err.message;
+    }).then(function () {
+      if (!race || !race.over) {
+        setTimeout(poll, 1000);
+      }
+    });
+  }
+
+  function showRace(st) {
+    race = st;
+    var me = null;
+    st.players.forEach(function (p) {
+      if (racer && p.name === racer.name) {
+        me = p;
+      }
+    });
+    var waiting = !st.started || st.elapsed < 0;
+    locked = !me || waiting || !!me.finished;
+    root.classList.toggle("waiting", waiting);
+    joinButton.style.display = racer || st.started ? "none" : "";
+    startButton.style.display = !me || st.started || st.players.length < 2 ? "none" : "";
+    if (st.started && st.elapsed < 0) {
+      // Unlock the grid as soon as the countdown ends, without waiting for
+      // the next poll.
+      setTimeout(function () {
+        if (race === st) {
+          showRace(Object.assign({}, st, {elapsed: 0}));
+        }
+      }, -st.elapsed);
+    }
+
+    if (!st.started) {
+      raceStatus.textContent = st.players.length === 0 ? "Waiting for players" : "Waiting to start";
+    } else if (waiting) {
+      raceStatus.textContent = "Starting in " + Math.ceil(-st.elapsed / 1000) + "…";
+    } else if (me && me.finished) {
+      raceStatus.textContent = "You finished " + ordinal(me.place) + ", in " + clock(me.finished);
+    } else if (st.winner) {
+      raceStatus.textContent = st.winner + " won, in " + clock(st.players.filter(function (p) { return p.place === 1; })[0].finished);
+    } else {
+      raceStatus.textContent = clock(st.elapsed);
+    }
+
+    racers.textContent = "";
+    st.players.forEach(function (p) {
+      var row = document.createElement("div");
+      row.className = "racer";
+      var name = document.createElement("span");
+      name.className = "name";
+      name.textContent = p.name;
+      var bar = document.createElement("span");
+      bar.className = "bar";
+      var fill = document.createElement("span");
+      fill.style.width = Math.round(p.progress * 100) + "%";
+      bar.appendChild(fill);
+      var res = document.createElement("span");
+      res.className = "result";
+      res.textContent = p.finished ? ordinal(p.place) + ", " + clock(p.finished) : Math.round(p.progress * 100) + "%";
+      row.appendChild(name);
+      row.appendChild(bar);
+      row.appendChild(res);
+      racers.appendChild(row);
+    });
+  }
+
+  // submit sends the grid to be scored, after which it can't be changed.
+  function submit() {
+    locked = true;
+    post(data.submit, {grid: grid()}).then(function (r) {
+      if (r.ok || r.status === 409) {
+        submitted = true;
+        save();
+      } else {
+        locked = false;
+      }
+      return r.text();
+    }).then(function (msg) {
+      status.textContent = msg.trim();
+    }, function () {
+      locked = false;
+      status.textContent = "Submitting failed";
+    });
+  }
+
+  function countdown() {
+    var left = deadline - Date.now();
+    var el = root.querySelector(".controls .countdown");
+    el.textContent = submitted ? "" : clock(left) + " left";
+    el.classList.toggle("urgent", left < 60000);
+    if (left <= 0 && !submitted) {
+      if (!locked) {
+        submit();
+      }
+      return;
+    }
+    if (!submitted) {
+      setTimeout(countdown, left % 1000 || 1000);
+    }
+  }
+
+  restore();
+  save();
+  if (submitted) {
+    status.textContent = "Submitted";
+  }
+  if (deadline) {
+    countdown();
+  }
+  if (data.race) {
+    try {
+      racer = JSON.parse(localStorage.getItem(raceKey));
+    } catch (err) {}
+    locked = true;
+    root.classList.add("waiting");
+    poll();
+  }
+  if (data.entries.length > 0) {
+    select(data.entries[0].cells[0][0], data.entries[0].cells[0][1], data.entries[0]);
+  }
+})();
+`
@@ -0,0 +1,224 @@
+package xwd
+
+import (
+	"html/template"
+	"io"
+	"strings"
+	"time"
+)
+
+// TournamentPage describes the front page of a tournament, as served by
+// xwdweb: its rounds, with links to their puzzles while they're open, and the
+// standings.
+type TournamentPage struct {
+	Tournament *Tournament
+	Now        time.Time
+	Player     string // The name of the player viewing the page, if registered
+	Register   string // The URL to which players POST their names to register
+	Rounds     []TournamentRound
+	Standings  []Standing
+	Refresh    int // How often the page reloads, in seconds, to keep the standings live
+}
+
+// TournamentRound is a round on a tournament page.
+type TournamentRound struct {
+	Round
+	Puzzles []TournamentPuzzle
+}
+
+// TournamentPuzzle is a puzzle on a tournament page.
+type TournamentPuzzle struct {
+	Title string
+	URL   string // The URL of the solver page, if the player can solve it now
+	Score *Score // The player's score for it, if they've submitted it
+}
+
+// JudgingPage describes the admin view of a tournament, in which the judges
+// review the submissions and accept alternative answers.
+type JudgingPage struct {
+	Tournament  *Tournament
+	Judge       string // The URL to which judgements are POSTed
+	CSRF        string // A token POSTed with each judgement, to show it came from the page
+	Submissions []JudgedSubmission
+	Standings   []Standing
+}
+
+// JudgedSubmission is a submission on the judging page, with its grid marked
+// against the solution.
+type JudgedSubmission struct {
+	ID         int
+	Submission *Submission
+	Title      string // The title of the puzzle
+	Grid       [][]JudgedCell
+}
+
+// JudgedCell is a cell of a submitted grid.
+type JudgedCell struct {
+	Row, Col int
+	Black    bool
+	Letter   string
+	Solution string
+	Wrong    bool // Whether the letter doesn't match the solution
+	Accepted bool // Whether the judges have accepted it anyway
+}
+
+// NewJudgedSubmission marks a submission of the puzzle for the judging page.
+func NewJudgedSubmission(id int, sub *Submission, p *Puzzle) JudgedSubmission {
+	js := JudgedSubmission{ID: id, Submission: sub, Title: p.Title}
+	accepted := make(map[[2]int]bool)
+	for _, a := range sub.Accepted {
+		accepted[a] = true
+	}
+	for i, row := range p.Solution() {
+		cells := make([]JudgedCell, len(row))
+		for j, cell := range row {
+			jc := JudgedCell{Row: i, Col: j, Black: cell.Black, Solution: strings.ToUpper(cell.Solution)}
+			if i < len(sub.Grid) && j < len(sub.Grid[i]) {
+				jc.Letter = strings.ToUpper(sub.Grid[i][j])
+			}
+			jc.Wrong = !jc.Black && jc.Letter != jc.Solution
+			jc.Accepted = accepted[[2]int{i, j}]
+			cells[j] = jc
+		}
+		js.Grid = append(js.Grid, cells)
+	}
+	return js
+}
+
+var tournamentTemplates = template.Must(template.New("").Funcs(htmlFuncs).Parse(`
+{{define "standings"}}
+<table class="standings">
+  <tr><th></th><th>Player</th><th>Points</th><th>Solved</th><th>Time</th></tr>
+  {{- range .}}
+  <tr><td>{{.Place}}</td><td>{{.Player}}</td><td>{{.Points}}</td><td>{{.Solved}}</td><td>{{clock .Time}}</td></tr>
+  {{- end}}
+</table>
+{{- end}}
+
+{{define "tournament"}}<!DOCTYPE html>
+<html>
+<head>
+  <meta charset="UTF-8">
+  <meta name="viewport" content="width=device-width, initial-scale=1">
+  {{- if .Page.Refresh}}
+  <meta http-equiv="refresh" content="{{.Page.Refresh}}">
+  {{- end}}
+  <title>{{.Page.Tournament.Title}}</title>
+  <style>{{.CSS}}</style>
+</head>
+<body>
+<div class="xwd-tournament">
+  <h1>{{.Page.Tournament.Title}}</h1>
+  {{- if .Page.Player}}
+  <p>Playing as <strong>{{.Page.Player}}</strong>.</p>
+  {{- else if .Page.Register}}
+  <form method="post" action="{{.Page.Register}}">
+    <label>Your name: <input type="text" name="name" required="required"></label>
+    <button type="submit">Register</button>
+  </form>
+  {{- end}}
+  {{- range .Page.Rounds}}
+  <div class="round">
+    <h2>{{.Name}}</h2>
+    <p class="times">
+      {{- if .Open $.Page.Now}}Open until {{.Deadline.Format "15:04"}}
+      {{- else if $.Page.Now.Before .Start}}Opens {{.Start.Format "Mon 2 Jan 15:04"}}, for {{.Limit.Minutes}} minutes
+      {{- else}}Closed{{end -}}
+    </p>
+    <ul>
+      {{- range .Puzzles}}
+      <li>
+        {{- if .URL}}<a href="{{.URL}}">{{.Title}}</a>{{else}}{{.Title}}{{end}}
+        {{- with .Score}}: {{.Points}} points ({{.Words}} words, {{.Errors}} errors, {{.Bonus}} bonus){{end -}}
+      </li>
+      {{- end}}
+    </ul>
+  </div>
+  {{- end}}
+  <h2>Standings</h2>
+  {{- template "standings" .Page.Standings}}
+</div>
+</body>
+</html>
+{{end}}
+
+{{define "judging"}}<!DOCTYPE html>
+<html>
+<head>
+  <meta charset="UTF-8">
+  <meta name="viewport" content="width=device-width, initial-scale=1">
+  <title>Judging: {{.Page.Tournament.Title}}</title>
+  <style>{{.CSS}}</style>
+</head>
+<body>
+<div class="xwd-tournament">
+  <h1>Judging: {{.Page.Tournament.Title}}</h1>
+  <h2>Standings</h2>
+  {{- template "standings" .Page.Standings}}
+  <h2>Submissions</h2>
+  <p>Wrong letters are marked in red. Click one to accept it as correct (or
+  an accepted one to withdraw the acceptance), and the submission is rescored.</p>
+  {{- range .Page.Submissions}}
+  {{- $round := index $.Page.Tournament.Rounds .Submission.Round}}
+  <div class="submission" id="submission-{{.ID}}">
+    <h3>{{.Submission.Player}}: {{$round.Name}}, {{.Title}}</h3>
+    <p>Submitted {{.Submission.Time.Format "15:04:05"}}.
+    {{with .Submission.Score}}{{.Points}} points: {{.Words}} words, {{.Errors}} errors, {{.Minutes}} minutes left, {{.Bonus}} bonus{{if .Complete}}, complete{{end}}.{{end}}</p>
+    <form method="post" action="{{$.Page.Judge}}">
+      <input type="hidden" name="csrf" value="{{$.Page.CSRF}}">
+      <input type="hidden" name="submission" value="{{.ID}}">
+      <table class="grid">
+        {{- range .Grid}}
+        <tr>
+          {{- range .}}
+          {{- if .Black}}
+          <td class="black"></td>
+          {{- else if or .Wrong .Accepted}}
+          <td class="{{if .Accepted}}accepted{{else}}wrong{{end}}"><button type="submit" name="cell" value="{{.Row}},{{.Col}}" title="Solution: {{.Solution}}">{{.Letter}}</button></td>
+          {{- else}}
+          <td>{{.Letter}}</td>
+          {{- end}}
+          {{- end}}
+        </tr>
+        {{- end}}
+      </table>
+    </form>
+  </div>
+  {{- else}}
+  <p>Nothing has been submitted yet.</p>
+  {{- end}}
+</div>
+</body>
+</html>
+{{end}}
+`))
+
+// tournamentCSS styles the pages written by WriteTournamentHTML and
+// WriteJudgingHTML.
+const tournamentCSS = `
+body { font-family: sans-serif; margin: 1em; }
+.xwd-tournament table.standings { border-collapse: collapse; }
+.xwd-tournament table.standings th, .xwd-tournament table.standings td { padding: 0.2em 0.8em 0.2em 0; text-align: left; }
+.xwd-tournament table.grid { border-collapse: collapse; }
+.xwd-tournament table.grid td { border: 1px solid #000; width: 1.6em; height: 1.6em; padding: 0; text-align: center; }
+.xwd-tournament table.grid td.black { background: #000; }
+.xwd-tournament table.grid td.wrong { background: #fcc; }
+.xwd-tournament table.grid td.accepted { background: #cfc; }
+.xwd-tournament table.grid button { border: none; background: none; width: 100%; height: 100%; cursor: pointer; font: inherit; }
+`
+
+// WriteTournamentHTML writes the front page of a tournament.
+func WriteTournamentHTML(w io.Writer, page *TournamentPage) error {
+	return tournamentTemplates.ExecuteTemplate(w, "tournament", map[string]interface{}{
+		"Page": page,
+		"CSS":  template.CSS(tournamentCSS),
+	})
+}
+
+// WriteJudgingHTML writes the judging page of a tournament.
+func WriteJudgingHTML(w io.Writer, page *JudgingPage) error {
+	return tournamentTemplates.ExecuteTemplate(w, "judging", map[string]interface{}{
+		"Page": page,
+		"CSS":  template.CSS(tournamentCSS),
+	})
+}
@@ -0,0 +1,302 @@
+package xwd
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+)
+
+// P_WHITE is the character used for an empty white cell in generated grid
+// patterns.
+const P_WHITE = "-"
+
+// ThemeEntry is an answer that a generated grid pattern must have in a given
+// place.
+type ThemeEntry struct {
+	Row       int // The row of the first letter (zero-indexed)
+	Col       int // The column of the first letter (zero-indexed)
+	Direction Direction
+	Answer    string
+}
+
+// PatternOptions describe the grid pattern wanted from GeneratePattern.
+type PatternOptions struct {
+	Rows        int
+	Cols        int
+	Blocks      int          // The number of black squares wanted
+	Words       int          // The number of entries wanted (0 for any)
+	MinLength   int          // The shortest entry allowed (0 for 3)
+	Theme       []ThemeEntry // Entries to place before generating the rest
+	Seed        int64        // The seed for the random number generator
+	MaxAttempts int          // The most patterns to try (0 for 1000)
+}
+
+// GeneratePattern generates a grid pattern with 180° rotational symmetry,
+// every white square in an across and a down entry of at least the minimum
+// length, and all the white squares connected. Black squares are added at
+// random until there are at least the wanted number. If a number of words is
+// wanted, patterns are generated until one has that many, and the closest
+// found is returned if none does.
+//
+// The pattern is returned in the form accepted by Puzzle.SetSolution, with
+// P_BLACK for black squares, the letters of any theme entries in place, and
+// P_WHITE for the other white squares.
+func GeneratePattern(opts PatternOptions) ([]string, error) {
+	if opts.Rows <= 0 || opts.Cols <= 0 {
+		return nil, errors.New("pattern dimensions must be positive")
+	}
+	if opts.MinLength <= 0 {
+		opts.MinLength = 3
+	}
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = 1000
+	}
+
+	g := &patternGen{rows: opts.Rows, cols: opts.Cols, minLength: opts.MinLength}
+	err := g.placeTheme(opts.Theme)
+	if err != nil {
+		return nil, err
+	}
+	if !g.valid(g.black) {
+		return nil, errors.New("theme entries leave entries shorter than the minimum length")
+	}
+
+	rng := rand.New(rand.NewSource(opts.Seed))
+	var best []bool
+	bestMiss := -1
+	for attempt := 0; attempt < opts.MaxAttempts; attempt++ {
+		black, ok := g.fill(rng, opts.Blocks)
+		if !ok {
+			continue
+		}
+		miss := 0
+		if opts.Words > 0 {
+			miss = g.words(black) - opts.Words
+			if miss < 0 {
+				miss = -miss
+			}
+		}
+		if bestMiss < 0 || miss < bestMiss {
+			best, bestMiss = black, miss
+		}
+		if miss == 0 {
+			break
+		}
+	}
+	if best == nil {
+		return nil, fmt.Errorf("couldn't generate a valid pattern with %d black squares", opts.Blocks)
+	}
+
+	grid := make([]string, g.rows)
+	for i := range grid {
+		row := make([]byte, g.cols)
+		for j := range row {
+			c := i*g.cols + j
+			switch {
+			case best[c]:
+				row[j] = P_BLACK[0]
+			case g.letters[c] != 0:
+				row[j] = g.letters[c]
+			default:
+				row[j] = P_WHITE[0]
+			}
+		}
+		grid[i] = string(row)
+	}
+	return grid, nil
+}
+
+// patternGen holds the state of GeneratePattern.
+type patternGen struct {
+	rows      int
+	cols      int
+	minLength int
+	black     []bool // The black squares required by the theme
+	locked    []bool // The squares that must stay white
+	letters   []byte // The letters of the theme entries
+}
+
+// mirror returns the index of the square symmetrically opposite c.
+func (g *patternGen) mirror(c int) int {
+	return g.rows*g.cols - 1 - c
+}
+
+// placeTheme places the theme entries, locking their squares (and their
+// mirror images) white, and blacking out the squares either side of them.
+func (g *patternGen) placeTheme(theme []ThemeEntry) error {
+	n := g.rows * g.cols
+	g.black = make([]bool, n)
+	g.locked = make([]bool, n)
+	g.letters = make([]byte, n)
+
+	setBlack := func(i, j int) error {
+		if i < 0 || i >= g.rows || j < 0 || j >= g.cols {
+			return nil
+		}
+		c := i*g.cols + j
+		if g.locked[c] || g.locked[g.mirror(c)] {
+			return errors.New("theme entries overlap")
+		}
+		g.black[c], g.black[g.mirror(c)] = true, true
+		return nil
+	}
+
+	for _, t := range theme {
+		answer := NormaliseAnswer(t.Answer)
+		di, dj := 0, 1
+		if t.Direction == Down {
+			di, dj = 1, 0
+		}
+		endI, endJ := t.Row+di*(len(answer)-1), t.Col+dj*(len(answer)-1)
+		if len(answer) == 0 || t.Row < 0 || t.Col < 0 || endI >= g.rows || endJ >= g.cols {
+			return fmt.Errorf("theme entry %s doesn't fit in the grid", t.Answer)
+		}
+		for k := 0; k < len(answer); k++ {
+			c := (t.Row+di*k)*g.cols + t.Col + dj*k
+			if g.black[c] || g.letters[c] != 0 && g.letters[c] != answer[k] {
+				return fmt.Errorf("theme entry %s clashes with another", t.Answer)
+			}
+			g.letters[c] = answer[k]
+			g.locked[c], g.locked[g.mirror(c)] = true, true
+		}
+	}
+	for _, t := range theme {
+		n := len(NormaliseAnswer(t.Answer))
+		di, dj := 0, 1
+		if t.Direction == Down {
+			di, dj = 1, 0
+		}
+		err := setBlack(t.Row-di, t.Col-dj)
+		if err == nil {
+			err = setBlack(t.Row+di*n, t.Col+dj*n)
+		}
+		if err != nil {
+			return fmt.Errorf("theme entry %s: %v", t.Answer, err)
+		}
+	}
+	return nil
+}
+
+// fill adds symmetric pairs of black squares at random to the theme's black
+// squares, keeping the pattern valid, until there are at least the wanted
+// number. It reports whether it managed to place them all.
+func (g *patternGen) fill(rng *rand.Rand, blocks int) ([]bool, bool) {
+	black := make([]bool, len(g.black))
+	copy(black, g.black)
+	count := 0
+	for _, b := range black {
+		if b {
+			count++
+		}
+	}
+
+	candidates := make([]int, 0, len(black)/2+1)
+	for c := 0; c <= g.mirror(c); c++ {
+		if !black[c] && !g.locked[c] {
+			candidates = append(candidates, c)
+		}
+	}
+	rng.Shuffle(len(candidates), func(i, j int) {
+		candidates[i], candidates[j] = candidates[j], candidates[i]
+	})
+
+	for _, c := range candidates {
+		if count >= blocks {
+			break
+		}
+		m := g.mirror(c)
+		black[c], black[m] = true, true
+		if !g.valid(black) {
+			black[c], black[m] = false, false
+			continue
+		}
+		count++
+		if m != c {
+			count++
+		}
+	}
+	return black, count >= blocks
+}
+
+// valid reports whether every white square is in an across and a down entry
+// of at least the minimum length, and the white squares are all connected.
+func (g *patternGen) valid(black []bool) bool {
+	for i := 0; i < g.rows; i++ {
+		run := 0
+		for j := 0; j <= g.cols; j++ {
+			if j < g.cols && !black[i*g.cols+j] {
+				run++
+				continue
+			}
+			if run > 0 && run < g.minLength {
+				return false
+			}
+			run = 0
+		}
+	}
+	for j := 0; j < g.cols; j++ {
+		run := 0
+		for i := 0; i <= g.rows; i++ {
+			if i < g.rows && !black[i*g.cols+j] {
+				run++
+				continue
+			}
+			if run > 0 && run < g.minLength {
+				return false
+			}
+			run = 0
+		}
+	}
+
+	start, white := -1, 0
+	for c, b := range black {
+		if !b {
+			white++
+			start = c
+		}
+	}
+	if white == 0 {
+		return false
+	}
+	seen := make([]bool, len(black))
+	seen[start] = true
+	stack := []int{start}
+	reached := 0
+	for len(stack) > 0 {
+		c := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		reached++
+		i, j := c/g.cols, c%g.cols
+		for _, n := range [][2]int{{i - 1, j}, {i + 1, j}, {i, j - 1}, {i, j + 1}} {
+			if n[0] < 0 || n[0] >= g.rows || n[1] < 0 || n[1] >= g.cols {
+				continue
+			}
+			nc := n[0]*g.cols + n[1]
+			if !black[nc] && !seen[nc] {
+				seen[nc] = true
+				stack = append(stack, nc)
+			}
+		}
+	}
+	return reached == white
+}
+
+// words returns the number of entries in a valid pattern.
+func (g *patternGen) words(black []bool) int {
+	n := 0
+	for i := 0; i < g.rows; i++ {
+		for j := 0; j < g.cols; j++ {
+			c := i*g.cols + j
+			if black[c] {
+				continue
+			}
+			if (j == 0 || black[c-1]) && j+1 < g.cols && !black[c+1] {
+				n++
+			}
+			if (i == 0 || black[c-g.cols]) && i+1 < g.rows && !black[c+g.cols] {
+				n++
+			}
+		}
+	}
+	return n
+}
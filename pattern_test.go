@@ -0,0 +1,96 @@
+package xwd
+
+import (
+	"strings"
+	"testing"
+)
+
+func checkPattern(t *testing.T, grid []string, minLength int) *Puzzle {
+	p := &Puzzle{Rows: len(grid), Cols: len(grid[0])}
+	err := p.SetSolution(grid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range grid {
+		for j := range grid[i] {
+			if (grid[i][j] == '.') != (grid[p.Rows-1-i][p.Cols-1-j] == '.') {
+				t.Fatalf("pattern isn't symmetric at (%d, %d):\n%s", i, j, strings.Join(grid, "\n"))
+			}
+		}
+	}
+	counts := make(map[[2]int]int)
+	for _, e := range p.Entries() {
+		if len(e.Cells) < minLength {
+			t.Errorf("entry %d %s is too short:\n%s", e.Num+1, e.Direction, strings.Join(grid, "\n"))
+		}
+		for _, c := range e.Cells {
+			counts[c]++
+		}
+	}
+	for _, cell := range p.cells {
+		if !cell.Black && counts[cell.Coords] != 2 {
+			t.Errorf("cell %v isn't checked:\n%s", cell.Coords, strings.Join(grid, "\n"))
+		}
+	}
+	return p
+}
+
+func TestGeneratePattern(t *testing.T) {
+	for seed := int64(1); seed <= 5; seed++ {
+		grid, err := GeneratePattern(PatternOptions{Rows: 15, Cols: 15, Blocks: 36, Seed: seed})
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkPattern(t, grid, 3)
+		if blocks := strings.Count(strings.Join(grid, ""), "."); blocks < 36 {
+			t.Errorf("expectation failure (expected: at least %v blocks, got: %v)", 36, blocks)
+		}
+	}
+}
+
+func TestGeneratePatternWords(t *testing.T) {
+	grid, err := GeneratePattern(PatternOptions{Rows: 15, Cols: 15, Blocks: 38, Words: 78, Seed: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := checkPattern(t, grid, 3)
+	if n := len(p.Entries()); n != 78 {
+		t.Errorf("expectation failure (expected: %v words, got: %v)", 78, n)
+	}
+}
+
+func TestGeneratePatternTheme(t *testing.T) {
+	theme := []ThemeEntry{
+		{Row: 5, Col: 4, Direction: Across, Answer: "Cross words"},
+		{Row: 4, Col: 10, Direction: Down, Answer: "WORD"},
+	}
+	grid, err := GeneratePattern(PatternOptions{Rows: 15, Cols: 15, Blocks: 36, Theme: theme, Seed: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkPattern(t, grid, 3)
+	if got := grid[5][3:15]; got != ".CROSSWORDS." {
+		t.Errorf("expectation failure (expected: %v, got: %v)", ".CROSSWORDS.", got)
+	}
+	for k, r := range "WORD" {
+		if rune(grid[4+k][10]) != r {
+			t.Errorf("expectation failure (expected: %c at (%d, 10), got: %c)", r, 4+k, grid[4+k][10])
+		}
+	}
+
+	_, err = GeneratePattern(PatternOptions{Rows: 15, Cols: 15, Theme: []ThemeEntry{
+		{Row: 3, Col: 2, Direction: Across, Answer: "CROSSWORDS"},
+		{Row: 1, Col: 3, Direction: Down, Answer: "PUZZLE"},
+	}})
+	if err == nil {
+		t.Errorf("expected an error for clashing theme entries")
+	}
+
+	// An across entry in the middle row must be centred to be symmetric.
+	_, err = GeneratePattern(PatternOptions{Rows: 15, Cols: 15, Theme: []ThemeEntry{
+		{Row: 7, Col: 2, Direction: Across, Answer: "CROSSWORDS"},
+	}})
+	if err == nil {
+		t.Errorf("expected an error for an asymmetric theme entry")
+	}
+}
@@ -1,8 +1,13 @@
 package xwd
 
 import (
+	"crypto/sha1"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"io/fs"
+	"strconv"
+	"strings"
 )
 
 // Puzzle holds the data needed to represent a crossword puzzle
@@ -13,10 +18,13 @@ type Puzzle struct {
 	Author      string
 	Copyright   string
 	Notes       string
-	solution    []string
-	cellCoords  map[[2]int]int
+	cells       []Cell  // The grid, stored densely in row-major order
+	entries     []Entry // Across then down entries, without their clue text
+	custom      []Entry // Custom entries, without their clue text
 	cluesAcross []Clue
 	cluesDown   []Clue
+	cluesCustom []Clue
+	customOnly  bool // Whether the across and down entries have been cleared
 }
 
 // Cell represents an individual cell in a crossword puzzle
@@ -24,7 +32,7 @@ type Cell struct {
 	Black    bool   // Is the cell a "black" or unfillable cell
 	Num      int    // If this is a numbered cell, the cell number, else -1
 	Coords   [2]int // The coordinates of the cell, [2]int{<row>, <col>}
-	Solution string // The provided solution for this cell
+	Solution string // The provided solution for this cell (more than one character for a rebus)
 }
 
 // Clue is a specific down or across clue for the puzzle
@@ -33,6 +41,52 @@ type Clue struct {
 	Clue string // The text of the clue
 }
 
+// Direction distinguishes across entries from down entries, and both from
+// custom entries, which follow an arbitrary path through the grid
+type Direction int
+
+const (
+	Across Direction = iota
+	Down
+	Custom
+)
+
+func (d Direction) String() string {
+	switch d {
+	case Down:
+		return "down"
+	case Custom:
+		return "custom"
+	}
+	return "across"
+}
+
+// Entry is a single answer in the puzzle grid, together with its clue
+type Entry struct {
+	Direction Direction // Across, Down or Custom
+	Num       int       // The clue number (zero-indexed, as for Clue.Num)
+	Label     string    // For custom entries, how the clue is labelled, e.g. "Band A"
+	Clue      string    // The text of the clue
+	Answer    string    // The solution to the entry
+	Cells     [][2]int  // The coordinates of each cell in the entry, in order
+}
+
+// Enumeration returns the length of the entry in the conventional form used
+// alongside clues, e.g. "(5)". The length counts cells, so a rebus cell
+// counts once.
+func (e Entry) Enumeration() string {
+	return fmt.Sprintf("(%d)", len(e.Cells))
+}
+
+// Name returns how the entry is referred to, e.g. "12 across", or its label
+// if it's a custom entry.
+func (e Entry) Name() string {
+	if e.Direction == Custom {
+		return e.Label
+	}
+	return fmt.Sprintf("%d %s", e.Num+1, e.Direction)
+}
+
 // The character representing an unfillable cell in the crossword grid
 const P_BLACK = "."
 
@@ -50,15 +104,44 @@ func (p *Puzzle) Load(data []byte) error {
 			return err
 		}
 
-		provider.Load(p)
+		return provider.Load(p)
+	}
+	return NoProviderFound
+}
+
+// Recover loads the puzzle as Load does, but tolerates files that have the
+// wrong number of clues for their grid, placing as many clues as it can (see
+// AcrossLite.Recover). In that case the puzzle is usable, and a
+// *ClueCountError describing what couldn't be placed is returned.
+func (p *Puzzle) Recover(data []byte) error {
+	provider := &AcrossLite{}
+	if provider.Sniff(data) {
+		err := provider.Parse(data)
 		if err != nil {
 			return err
 		}
+		if cerr := provider.Recover(p); cerr != nil {
+			return cerr
+		}
 		return nil
 	}
 	return NoProviderFound
 }
 
+// LoadFS reads the named puzzle file from fsys and loads it, as Load.
+func LoadFS(fsys fs.FS, name string) (*Puzzle, error) {
+	data, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return nil, err
+	}
+	p := &Puzzle{}
+	err = p.Load(data)
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
 // SetSolution provides a way of directly setting the puzzle solution (and by
 // implication, the puzzle grid). It accepts a slice of strings, of length
 // puzzle.Rows. Each string must be of length puzzle.Cols. The ASCII period
@@ -67,7 +150,8 @@ func (p *Puzzle) Load(data []byte) error {
 //
 // Setting the solution grid will also prefill the clue storage structures, so
 // that CluesAcross and CluesDown will return slices of Clues, to which the
-// free-text clue data can be attached directly.
+// free-text clue data can be attached directly. Any custom entries are
+// removed.
 func (p *Puzzle) SetSolution(grid []string) error {
 	if len(grid) != p.Rows {
 		return errors.New("grid should contain as many rows as the puzzle")
@@ -78,55 +162,109 @@ func (p *Puzzle) SetSolution(grid []string) error {
 		}
 	}
 
-	p.solution = grid
-	p.cellCoords = make(map[[2]int]int)
-	p.cluesAcross = make([]Clue, 0)
-	p.cluesDown = make([]Clue, 0)
+	p.cells = make([]Cell, p.Rows*p.Cols)
+	for i := 0; i < p.Rows; i++ {
+		for j := 0; j < p.Cols; j++ {
+			cell := &p.cells[i*p.Cols+j]
+			cell.Num = -1
+			cell.Coords = [2]int{i, j}
+			if grid[i][j] == P_BLACK[0] {
+				cell.Black = true
+			} else {
+				cell.Solution = grid[i][j : j+1]
+			}
+		}
+	}
+
+	nAcross, nDown := 0, 0
+	for i := 0; i < p.Rows; i++ {
+		for j := 0; j < p.Cols; j++ {
+			if p.isAcrossCell(i, j) {
+				nAcross++
+			}
+			if p.isDownCell(i, j) {
+				nDown++
+			}
+		}
+	}
+	p.cluesAcross = make([]Clue, 0, nAcross)
+	p.cluesDown = make([]Clue, 0, nDown)
+	p.custom, p.cluesCustom, p.customOnly = nil, nil, false
 
+	// The entries share backing arrays for their cells and answers, which
+	// keeps allocations down when loading large numbers of puzzles.
+	b := newEntryBuilder(p, nAcross, nDown)
 	c := 0
 	for i := 0; i < p.Rows; i++ {
 		for j := 0; j < p.Cols; j++ {
 			cellNumbered := false
 			if p.isAcrossCell(i, j) {
-				p.cellCoords[[2]int{i, j}] = c
 				cellNumbered = true
 				p.cluesAcross = append(p.cluesAcross, Clue{Num: c})
+				b.add(Across, c, i, j)
 			}
 			if p.isDownCell(i, j) {
-				if !cellNumbered {
-					p.cellCoords[[2]int{i, j}] = c
-					cellNumbered = true
-				}
+				cellNumbered = true
 				p.cluesDown = append(p.cluesDown, Clue{Num: c})
+				b.add(Down, c, i, j)
 			}
 			if cellNumbered {
+				p.cells[i*p.Cols+j].Num = c
 				c++
 			}
 		}
 	}
+	p.entries = b.finish()
 
 	return nil
 }
 
+// SetRebus sets the solution of the cell at row i, column j to a string of
+// more than one character (or any other string), as in a rebus puzzle, and
+// updates the answers of the entries through it. The cell must be white.
+func (p *Puzzle) SetRebus(i, j int, solution string) error {
+	cell, err := p.Cell(i, j)
+	if err != nil {
+		return err
+	}
+	if cell.Black {
+		return fmt.Errorf("can't set a rebus in the black cell at row %d, column %d", i+1, j+1)
+	}
+	if solution == "" {
+		return errors.New("a rebus solution can't be empty")
+	}
+	p.cells[i*p.Cols+j].Solution = solution
+	p.updateAnswers(p.entries)
+	p.updateAnswers(p.custom)
+	return nil
+}
+
+// updateAnswers rebuilds the answers of the entries from the solutions of
+// their cells.
+func (p *Puzzle) updateAnswers(entries []Entry) {
+	var b strings.Builder
+	for k := range entries {
+		b.Reset()
+		for _, c := range entries[k].Cells {
+			b.WriteString(p.cells[c[0]*p.Cols+c[1]].Solution)
+		}
+		entries[k].Answer = b.String()
+	}
+}
+
 // Solution returns a slice of rows (themselves slices of Cells) that can be
-// used to range over the contents of this puzzle.
+// used to range over the contents of this puzzle. The cells are shared with
+// the puzzle, and must not be modified.
 func (p *Puzzle) Solution() [][]Cell {
 	rows := make([][]Cell, p.Rows)
 	for i := 0; i < p.Rows; i++ {
-		rows[i] = make([]Cell, p.Cols)
-		for j := 0; j < p.Cols; j++ {
-			c, err := p.Cell(i, j)
-			if err != nil {
-				panic(err)
-			}
-			rows[i][j] = *c
-		}
+		rows[i] = p.cells[i*p.Cols : (i+1)*p.Cols : (i+1)*p.Cols]
 	}
 	return rows
 }
 
 func (p *Puzzle) isBlackCell(row, col int) bool {
-	return p.solution[row][col] == P_BLACK[0]
+	return p.cells[row*p.Cols+col].Black
 }
 
 func (p *Puzzle) isAcrossCell(row, col int) bool {
@@ -169,25 +307,215 @@ func (p *Puzzle) CluesDown() []Clue {
 	return p.cluesDown
 }
 
+// CluesCustom returns a slice of Clue structs for the custom entries in the
+// puzzle, in the order they were added.
+func (p *Puzzle) CluesCustom() []Clue {
+	return p.cluesCustom
+}
+
+// Entries returns every entry in the puzzle: first the across entries and
+// then the down entries, each in clue number order, and then any custom
+// entries in the order they were added. The Cells of each entry are shared
+// with the puzzle, and must not be modified.
+func (p *Puzzle) Entries() []Entry {
+	entries := make([]Entry, len(p.entries)+len(p.custom))
+	copy(entries, p.entries)
+	copy(entries[len(p.entries):], p.custom)
+	for i := range p.cluesAcross {
+		entries[i].Clue = p.cluesAcross[i].Clue
+	}
+	for i := range p.cluesDown {
+		entries[len(p.cluesAcross)+i].Clue = p.cluesDown[i].Clue
+	}
+	for i := range p.cluesCustom {
+		entries[len(p.entries)+i].Clue = p.cluesCustom[i].Clue
+	}
+	return entries
+}
+
+// AddEntry adds a custom entry to the puzzle, running through the given cells
+// in order, for variety puzzles whose answers aren't (or aren't only) the
+// across and down runs of the grid: marching bands, rows gardens, diagonals
+// and so on. The cells must all be white, and no cell may appear twice. The
+// entry's answer is read from the solution grid, and its number is its index
+// among the custom entries.
+//
+// Custom entries are kept alongside the across and down entries unless
+// ClearComputedEntries is called, and are removed by SetSolution.
+func (p *Puzzle) AddEntry(label string, cells [][2]int, clue string) error {
+	if len(cells) == 0 {
+		return errors.New("a custom entry must have at least one cell")
+	}
+	cs := make([][2]int, len(cells))
+	seen := make(map[[2]int]bool, len(cells))
+	answer := make([]byte, 0, len(cells))
+	for k, c := range cells {
+		cell, err := p.Cell(c[0], c[1])
+		if err != nil {
+			return fmt.Errorf("custom entry %s: cell %d: %v", label, k+1, err)
+		}
+		if cell.Black {
+			return fmt.Errorf("custom entry %s: cell %d (row %d, column %d) is black", label, k+1, c[0]+1, c[1]+1)
+		}
+		if seen[c] {
+			return fmt.Errorf("custom entry %s: cell %d (row %d, column %d) appears twice", label, k+1, c[0]+1, c[1]+1)
+		}
+		seen[c] = true
+		cs[k] = c
+		answer = append(answer, cell.Solution...)
+	}
+	num := len(p.custom)
+	p.custom = append(p.custom, Entry{
+		Direction: Custom,
+		Num:       num,
+		Label:     label,
+		Answer:    string(answer),
+		Cells:     cs,
+	})
+	p.cluesCustom = append(p.cluesCustom, Clue{Num: num, Clue: clue})
+	return nil
+}
+
+// ClearComputedEntries removes the across and down entries (and their clues)
+// computed from the grid, along with the cell numbers, so that the puzzle has
+// only the custom entries added with AddEntry.
+func (p *Puzzle) ClearComputedEntries() {
+	p.entries = nil
+	p.cluesAcross = make([]Clue, 0)
+	p.cluesDown = make([]Clue, 0)
+	for i := range p.cells {
+		p.cells[i].Num = -1
+	}
+	p.customOnly = true
+}
+
+// HasCustomEntries reports whether the puzzle's entries differ from those
+// computed from the grid: that is, whether custom entries have been added or
+// the computed entries cleared.
+func (p *Puzzle) HasCustomEntries() bool {
+	return len(p.custom) > 0 || p.customOnly
+}
+
+// SetClue sets the text of the clue with the given direction and number
+// (zero-indexed, as for Clue.Num). It returns an error if there is no such
+// clue in the puzzle.
+func (p *Puzzle) SetClue(dir Direction, num int, text string) error {
+	clue := p.clue(dir, num)
+	if clue == nil {
+		return fmt.Errorf("no %s clue numbered %d", dir, num+1)
+	}
+	clue.Clue = text
+	return nil
+}
+
+// clue returns a pointer to the stored clue with the given direction and
+// (zero-indexed) number, or nil if there is no such clue.
+func (p *Puzzle) clue(dir Direction, num int) *Clue {
+	clues := p.cluesAcross
+	switch dir {
+	case Down:
+		clues = p.cluesDown
+	case Custom:
+		clues = p.cluesCustom
+	}
+	for i := range clues {
+		if clues[i].Num == num {
+			return &clues[i]
+		}
+	}
+	return nil
+}
+
+// entryBuilder computes the entries of a puzzle, allocating their cells and
+// answers from shared buffers.
+type entryBuilder struct {
+	p           *Puzzle
+	cells       [][2]int
+	answers     []byte
+	across      []Entry
+	down        []Entry
+	acrossSpans [][2]int // The extent of each across answer within answers
+	downSpans   [][2]int
+}
+
+func newEntryBuilder(p *Puzzle, nAcross, nDown int) *entryBuilder {
+	return &entryBuilder{
+		p:           p,
+		cells:       make([][2]int, 0, 2*len(p.cells)),
+		answers:     make([]byte, 0, 2*len(p.cells)),
+		across:      make([]Entry, 0, nAcross+nDown),
+		down:        make([]Entry, 0, nDown),
+		acrossSpans: make([][2]int, 0, nAcross),
+		downSpans:   make([][2]int, 0, nDown),
+	}
+}
+
+// add adds the entry starting at row i, column j.
+func (b *entryBuilder) add(dir Direction, num, i, j int) {
+	p := b.p
+	di, dj := 0, 1
+	if dir == Down {
+		di, dj = 1, 0
+	}
+	start, answerStart := len(b.cells), len(b.answers)
+	for ; i < p.Rows && j < p.Cols && !p.isBlackCell(i, j); i, j = i+di, j+dj {
+		b.cells = append(b.cells, [2]int{i, j})
+		b.answers = append(b.answers, p.cells[i*p.Cols+j].Solution...)
+	}
+	e := Entry{Direction: dir, Num: num, Cells: b.cells[start:len(b.cells):len(b.cells)]}
+	span := [2]int{answerStart, len(b.answers)}
+	if dir == Across {
+		b.across = append(b.across, e)
+		b.acrossSpans = append(b.acrossSpans, span)
+	} else {
+		b.down = append(b.down, e)
+		b.downSpans = append(b.downSpans, span)
+	}
+}
+
+// finish fills in the answers and returns the across entries followed by the
+// down entries.
+func (b *entryBuilder) finish() []Entry {
+	answers := string(b.answers)
+	for i, span := range b.acrossSpans {
+		b.across[i].Answer = answers[span[0]:span[1]]
+	}
+	for i, span := range b.downSpans {
+		b.down[i].Answer = answers[span[0]:span[1]]
+	}
+	return append(b.across, b.down...)
+}
+
+// Fingerprint returns a hex-encoded SHA-1 digest of the puzzle grid and clues.
+// It identifies a puzzle independently of its metadata and of the file format
+// it was loaded from, and so can be used to find duplicates in an archive.
+func (p *Puzzle) Fingerprint() string {
+	// The digested text is built up in one buffer, rather than formatted cell
+	// by cell, as archive scans fingerprint every puzzle.
+	b := make([]byte, 0, 2*len(p.cells)+64*len(p.entries))
+	b = strconv.AppendInt(b, int64(p.Cols), 10)
+	b = append(b, 'x')
+	b = strconv.AppendInt(b, int64(p.Rows), 10)
+	b = append(b, '\n')
+	for _, cell := range p.cells {
+		b = append(append(b, cell.Solution...), '.')
+	}
+	for _, e := range p.Entries() {
+		b = append(append(b, e.Direction.String()...), ' ')
+		b = strconv.AppendInt(b, int64(e.Num), 10)
+		b = append(append(append(b, ' '), e.Clue...), '\n')
+	}
+	sum := sha1.Sum(b)
+	return hex.EncodeToString(sum[:])
+}
+
 // Cell returns a Cell struct for the cell at row i, column j in the current
 // puzzle. The coordinates are bounds-checked and the function will return
-// puzzle.OutOfBounds if incorrect coordinates are given.
+// puzzle.OutOfBounds if incorrect coordinates are given. The cell is shared
+// with the puzzle, and must not be modified.
 func (p *Puzzle) Cell(i, j int) (*Cell, error) {
 	if i < 0 || i >= p.Rows || j < 0 || j >= p.Cols {
 		return nil, OutOfBounds
 	}
-	coords := [2]int{i, j}
-	num, ok := p.cellCoords[coords]
-	if !ok {
-		num = -1
-	}
-	c := &Cell{
-		Black:  p.isBlackCell(i, j),
-		Num:    num,
-		Coords: coords,
-	}
-	if !c.Black {
-		c.Solution = string(p.solution[i][j])
-	}
-	return c, nil
+	return &p.cells[i*p.Cols+j], nil
 }
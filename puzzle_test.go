@@ -1,6 +1,14 @@
 package xwd
 
-import "testing"
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"testing"
+)
 
 func TestSetSolution(t *testing.T) {
 	p := &Puzzle{}
@@ -112,3 +120,188 @@ func testCell(ex CellExample, t *testing.T) {
 		}
 	}
 }
+
+func TestEntries(t *testing.T) {
+	p := &Puzzle{Rows: 3, Cols: 3}
+	err := p.SetSolution([]string{
+		"CAT",
+		"..A",
+		"..N",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.CluesAcross()[0].Clue = "Feline"
+	p.CluesDown()[0].Clue = "Sunburn"
+
+	entries := p.Entries()
+	if len(entries) != 2 {
+		t.Fatalf("wrong number of entries (expected: 2, got: %v)", len(entries))
+	}
+
+	a, d := entries[0], entries[1]
+	if a.Direction != Across || a.Num != 0 || a.Answer != "CAT" || a.Clue != "Feline" {
+		t.Errorf("across entry expectation failure (got: %+v)", a)
+	}
+	if d.Direction != Down || d.Num != 1 || d.Answer != "TAN" || d.Clue != "Sunburn" {
+		t.Errorf("down entry expectation failure (got: %+v)", d)
+	}
+	if d.Cells[2] != [2]int{2, 2} {
+		t.Errorf("down entry cells expectation failure (expected: [2 2], got: %v)", d.Cells[2])
+	}
+	if d.Enumeration() != "(3)" {
+		t.Errorf("enumeration expectation failure (expected: (3), got: %v)", d.Enumeration())
+	}
+}
+
+func TestFingerprint(t *testing.T) {
+	// Fingerprints are kept in recordings, so mustn't change.
+	p := loadFixture(t, "version_13.puz")
+	expected := "8e5397efcb6419b12f00a5374c03ea6b69eade1d"
+	if got := p.Fingerprint(); got != expected {
+		t.Errorf("expectation failure (expected: %v, got: %v)", expected, got)
+	}
+}
+
+func TestCustomEntries(t *testing.T) {
+	p := &Puzzle{Rows: 3, Cols: 3}
+	err := p.SetSolution([]string{
+		"CAT",
+		"O.A",
+		"WEN",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	band := [][2]int{{0, 0}, {0, 1}, {0, 2}, {1, 2}, {2, 2}, {2, 1}, {2, 0}, {1, 0}}
+	err = p.AddEntry("Band A", band, "Going round")
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries := p.Entries()
+	if len(entries) != 5 {
+		t.Fatalf("wrong number of entries (expected: 5, got: %v)", len(entries))
+	}
+	e := entries[4]
+	if e.Direction != Custom || e.Num != 0 || e.Label != "Band A" || e.Answer != "CATANEWO" || e.Clue != "Going round" {
+		t.Errorf("custom entry expectation failure (got: %+v)", e)
+	}
+	if err := p.SetClue(Custom, 0, "Round and round"); err != nil || p.Entries()[4].Clue != "Round and round" {
+		t.Errorf("setting custom clue failed (err: %v)", err)
+	}
+
+	for _, cells := range [][][2]int{
+		{},
+		{{1, 1}},
+		{{0, 0}, {3, 0}},
+		{{0, 0}, {0, 1}, {0, 0}},
+	} {
+		if err := p.AddEntry("Bad", cells, ""); err == nil {
+			t.Errorf("expected error adding custom entry with cells %v", cells)
+		}
+	}
+
+	p.ClearComputedEntries()
+	entries = p.Entries()
+	if len(entries) != 1 || entries[0].Label != "Band A" {
+		t.Errorf("entries after clearing computed expectation failure (got: %+v)", entries)
+	}
+	if cell, _ := p.Cell(0, 0); cell.Num != -1 {
+		t.Errorf("cell number after clearing computed expectation failure (expected: -1, got: %v)", cell.Num)
+	}
+	if !p.HasCustomEntries() {
+		t.Errorf("expected puzzle to have custom entries")
+	}
+
+	p.SetSolution([]string{"CAT", "O.A", "WEN"})
+	if p.HasCustomEntries() || len(p.Entries()) != 4 {
+		t.Errorf("expected SetSolution to remove custom entries")
+	}
+}
+
+func BenchmarkLoad(b *testing.B) {
+	data, err := os.ReadFile("fixtures/version_12.puz")
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	for n := 0; n < b.N; n++ {
+		p := &Puzzle{}
+		err = p.Load(data)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkSolution(b *testing.B) {
+	p := loadFixture(b, "version_12.puz")
+	b.ReportAllocs()
+	for n := 0; n < b.N; n++ {
+		p.Solution()
+	}
+}
+
+func BenchmarkCell(b *testing.B) {
+	p := loadFixture(b, "version_12.puz")
+	b.ReportAllocs()
+	for n := 0; n < b.N; n++ {
+		for i := 0; i < p.Rows; i++ {
+			for j := 0; j < p.Cols; j++ {
+				p.Cell(i, j)
+			}
+		}
+	}
+}
+
+func BenchmarkEntries(b *testing.B) {
+	p := loadFixture(b, "version_12.puz")
+	b.ReportAllocs()
+	for n := 0; n < b.N; n++ {
+		p.Entries()
+	}
+}
+
+func BenchmarkWriteHTML(b *testing.B) {
+	p := loadFixture(b, "version_12.puz")
+	b.ReportAllocs()
+	for n := 0; n < b.N; n++ {
+		err := WriteHTML(io.Discard, p, HTMLOptions{Check: true})
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestLoadFS(t *testing.T) {
+	var buf bytes.Buffer
+	z := zip.NewWriter(&buf)
+	f, err := z.Create("2007/animalia.puz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile("fixtures/version_13.puz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Write(data)
+	z.Close()
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := LoadFS(zr, "2007/animalia.puz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Title != "Animalia" {
+		t.Errorf("title expectation failure (expected: Animalia, got: %v)", p.Title)
+	}
+
+	_, err = LoadFS(zr, "2007/missing.puz")
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("loading a missing puzzle should fail with fs.ErrNotExist (got: %v)", err)
+	}
+}
@@ -0,0 +1,308 @@
+package xwd
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RaceCountdown is how long after a race is started that the players can
+// begin solving, giving every player's page time to notice.
+const RaceCountdown = 3 * time.Second
+
+// MinRacers is the fewest players with which a race can be started.
+const MinRacers = 2
+
+var RaceStarted = errors.New("the race has already started")
+var RaceNotStarted = errors.New("the race hasn't started yet")
+var UnknownRacer = errors.New("no such player in the race")
+
+// Race is a head-to-head solve of a puzzle: players join, the race is
+// started, and each then solves the puzzle in a Session of their own. The
+// others see only how much of the grid each has filled, not their letters.
+// The first to fill the grid correctly wins. A Race is safe for concurrent
+// use.
+type Race struct {
+	mu      sync.Mutex
+	puzzle  *Puzzle
+	racers  []*racer
+	created time.Time
+	start   time.Time // When solving begins, or zero if the race isn't started
+	now     func() time.Time
+}
+
+type racer struct {
+	name     string
+	token    string // The secret with which the player's updates are made
+	session  *Session
+	finished time.Duration // Since the start, or -1 if not yet finished
+}
+
+// RaceStatus is what the players of a race can see of it.
+type RaceStatus struct {
+	Started bool `json:"started"`
+
+	// Elapsed is the time since the race started, in milliseconds. It's
+	// negative during the countdown.
+	Elapsed int64 `json:"elapsed"`
+
+	Players []RacePlayer `json:"players"`
+	Winner  string       `json:"winner,omitempty"`
+	Over    bool         `json:"over"` // Whether every player has finished
+}
+
+// RacePlayer is the progress of one player in a race.
+type RacePlayer struct {
+	Name     string  `json:"name"`
+	Progress float64 `json:"progress"` // The fraction of the white cells filled
+
+	// Finished is the time the player took to finish, in milliseconds, or
+	// zero if they haven't.
+	Finished int64 `json:"finished,omitempty"`
+
+	Place int `json:"place,omitempty"` // 1 for the winner, and so on
+}
+
+// RaceView is what spectators see of a race, as it was Delay milliseconds
+// ago: the players' progress, as in RaceStatus, and their grids too.
+type RaceView struct {
+	RaceStatus
+	Delay int64      `json:"delay"`
+	Grids []RaceGrid `json:"grids"` // In the order of Players
+}
+
+// RaceGrid is a player's grid, as spectators see it.
+type RaceGrid struct {
+	Letters [][]string `json:"letters"` // Row by row, with "" for empty and black cells
+
+	// Cursor is the cell the player last changed, or nil if they haven't
+	// changed any yet.
+	Cursor *[2]int `json:"cursor,omitempty"`
+}
+
+// NewRace returns a race on the puzzle, which must have a solution so that
+// the players' grids can be checked.
+func NewRace(p *Puzzle) (*Race, error) {
+	for _, cell := range p.cells {
+		if !cell.Black && strings.TrimSpace(cell.Solution) == "" {
+			return nil, errors.New("the puzzle has no solution to race to")
+		}
+	}
+	r := &Race{puzzle: p, racers: make([]*racer, 0), now: time.Now}
+	r.created = r.now()
+	return r, nil
+}
+
+// Created returns the time the race was created.
+func (r *Race) Created() time.Time {
+	return r.created
+}
+
+// Join adds a player to the race, returning the token with which they make
+// their updates. Players can only join before the race starts, and each must
+// have a different name.
+func (r *Race) Join(name string) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", errors.New("players must have a name")
+	}
+	if !r.start.IsZero() {
+		return "", RaceStarted
+	}
+	for _, rc := range r.racers {
+		if strings.EqualFold(rc.name, name) {
+			return "", fmt.Errorf("there's already a player called %q", rc.name)
+		}
+	}
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(b)
+	r.racers = append(r.racers, &racer{name: name, token: token, finished: -1})
+	return token, nil
+}
+
+// Start starts the race, after RaceCountdown. It needs at least MinRacers
+// players.
+func (r *Race) Start() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.start.IsZero() {
+		return RaceStarted
+	}
+	if len(r.racers) < MinRacers {
+		return fmt.Errorf("a race needs at least %d players", MinRacers)
+	}
+	r.start = r.now().Add(RaceCountdown)
+	for _, rc := range r.racers {
+		rc.session = NewSession(r.puzzle)
+		rc.session.start = r.start
+		rc.session.now = r.now
+	}
+	return nil
+}
+
+// Update applies changes made by the player with the given token to their
+// grid, returning whether the changes finished the puzzle. The times of the
+// events are ignored: the race's own clock is used, so that players can't
+// claim to have been quicker than they were. Changes made after the player
+// has finished are ignored.
+func (r *Race) Update(token string, events []Event) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	rc := r.racer(token)
+	if rc == nil {
+		return false, UnknownRacer
+	}
+	if r.start.IsZero() || r.now().Before(r.start) {
+		return false, RaceNotStarted
+	}
+	if rc.finished >= 0 {
+		return false, nil
+	}
+	for _, e := range events {
+		err := rc.session.Set(e.Row, e.Col, e.Letter, e.Flags&FlagPencil != 0)
+		if err != nil {
+			return false, err
+		}
+	}
+	if rc.session.Solved() {
+		rc.finished = r.now().Sub(r.start)
+		return true, nil
+	}
+	return false, nil
+}
+
+// Player returns the name of the player with the given token, or "" if
+// there's no such player.
+func (r *Race) Player(token string) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if rc := r.racer(token); rc != nil {
+		return rc.name
+	}
+	return ""
+}
+
+// Recording returns the recording of the named player's solve, or nil if
+// there's no such player or the race hasn't started.
+func (r *Race) Recording(player string) *Recording {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, rc := range r.racers {
+		if rc.name == player && rc.session != nil {
+			return rc.session.Recording(r.puzzle, rc.name)
+		}
+	}
+	return nil
+}
+
+// racer returns the player with the given token, or nil if there's none.
+func (r *Race) racer(token string) *racer {
+	for _, rc := range r.racers {
+		if rc.token == token {
+			return rc
+		}
+	}
+	return nil
+}
+
+// Status returns the state of the race, with the players in the order they
+// joined.
+func (r *Race) Status() RaceStatus {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	sessions := make([]*Session, len(r.racers))
+	for k, rc := range r.racers {
+		sessions[k] = rc.session
+	}
+	return r.status(r.now(), sessions)
+}
+
+// Spectate returns the state of the race as it was the given time ago, with
+// every player's grid, for those watching rather than taking part. The delay
+// keeps spectators from passing answers to the players.
+func (r *Race) Spectate(delay time.Duration) RaceView {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	at := r.now().Add(-delay)
+	sessions := make([]*Session, len(r.racers))
+	view := RaceView{Delay: delay.Milliseconds(), Grids: make([]RaceGrid, len(r.racers))}
+	for k, rc := range r.racers {
+		s := NewSession(r.puzzle)
+		if rc.session != nil {
+			for _, e := range rc.session.events {
+				if e.Time > at.Sub(r.start) {
+					break
+				}
+				// The event was checked when it was made, so the cell
+				// exists.
+				c, _ := s.index(e.Row, e.Col)
+				s.letters[c], s.flags[c] = e.Letter, e.Flags
+				s.events = append(s.events, e)
+			}
+		}
+		sessions[k] = s
+
+		grid := RaceGrid{Letters: make([][]string, s.Rows)}
+		for i := range grid.Letters {
+			grid.Letters[i] = make([]string, s.Cols)
+			for j := range grid.Letters[i] {
+				grid.Letters[i][j] = s.Letter(i, j)
+			}
+		}
+		if n := len(s.events); n > 0 {
+			grid.Cursor = &[2]int{s.events[n-1].Row, s.events[n-1].Col}
+		}
+		view.Grids[k] = grid
+	}
+	view.RaceStatus = r.status(at, sessions)
+	return view
+}
+
+// status returns the state of the race at the given time, at which the
+// players' sessions were as given. The caller must hold r.mu.
+func (r *Race) status(at time.Time, sessions []*Session) RaceStatus {
+	st := RaceStatus{
+		Started: !r.start.IsZero() && !at.Before(r.start.Add(-RaceCountdown)),
+		Players: make([]RacePlayer, len(r.racers)),
+		Over:    len(r.racers) > 0,
+	}
+	if st.Started {
+		st.Elapsed = at.Sub(r.start).Milliseconds()
+	}
+	finishers := make([]int, 0, len(r.racers))
+	for k, rc := range r.racers {
+		st.Players[k].Name = rc.name
+		if s := sessions[k]; s != nil {
+			filled, total := s.Filled()
+			if total > 0 {
+				st.Players[k].Progress = float64(filled) / float64(total)
+			}
+		}
+		if rc.finished < 0 || !st.Started || rc.finished > at.Sub(r.start) {
+			st.Over = false
+			continue
+		}
+		st.Players[k].Finished = rc.finished.Milliseconds()
+		finishers = append(finishers, k)
+	}
+	sort.SliceStable(finishers, func(i, j int) bool {
+		return r.racers[finishers[i]].finished < r.racers[finishers[j]].finished
+	})
+	for place, k := range finishers {
+		st.Players[k].Place = place + 1
+	}
+	if len(finishers) > 0 {
+		st.Winner = r.racers[finishers[0]].name
+	}
+	return st
+}
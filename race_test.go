@@ -0,0 +1,145 @@
+package xwd
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestRace(t *testing.T) {
+	p := &Puzzle{Rows: 2, Cols: 2}
+	p.SetSolution([]string{"A.", "BC"})
+	r, err := NewRace(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock := r.created
+	r.now = func() time.Time { return clock }
+
+	alice, err := r.Join("Alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Start(); err == nil {
+		t.Errorf("expected error starting a race with one player")
+	}
+	if _, err := r.Join(" alice "); err == nil {
+		t.Errorf("expected error joining with a name already taken")
+	}
+	bob, err := r.Join("Bob")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Start(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Join("Carol"); err != RaceStarted {
+		t.Errorf("expectation failure (expected: %v, got: %v)", RaceStarted, err)
+	}
+	if _, err := r.Update(alice, []Event{{Row: 0, Col: 0, Letter: "A"}}); err != RaceNotStarted {
+		t.Errorf("expectation failure (expected: %v, got: %v)", RaceNotStarted, err)
+	}
+	if _, err := r.Update("nonsense", nil); err != UnknownRacer {
+		t.Errorf("expectation failure (expected: %v, got: %v)", UnknownRacer, err)
+	}
+
+	clock = clock.Add(RaceCountdown + 10*time.Second)
+	r.Update(bob, []Event{{Row: 0, Col: 0, Letter: "A"}, {Row: 1, Col: 0, Letter: "X"}})
+	done, err := r.Update(alice, []Event{{Row: 0, Col: 0, Letter: "A"}, {Row: 1, Col: 0, Letter: "B"}, {Row: 1, Col: 1, Letter: "C"}})
+	if err != nil || !done {
+		t.Fatalf("expectation failure (expected: alice to finish, got: %v, %v)", done, err)
+	}
+	clock = clock.Add(5 * time.Second)
+	done, _ = r.Update(bob, []Event{{Row: 1, Col: 0, Letter: "B"}, {Row: 1, Col: 1, Letter: "C", Flags: FlagPencil}})
+	if !done {
+		t.Errorf("expected bob to finish, pencilled or not")
+	}
+
+	st := r.Status()
+	if st.Winner != "Alice" || !st.Over || st.Elapsed != 15000 {
+		t.Errorf("expectation failure (expected: Alice to win after 15s, got: %q after %dms, over %v)", st.Winner, st.Elapsed, st.Over)
+	}
+	expected := []RacePlayer{
+		{Name: "Alice", Progress: 1, Finished: 10000, Place: 1},
+		{Name: "Bob", Progress: 1, Finished: 15000, Place: 2},
+	}
+	for k, pl := range st.Players {
+		if pl != expected[k] {
+			t.Errorf("expectation failure (expected: %+v, got: %+v)", expected[k], pl)
+		}
+	}
+
+	rec := r.Recording("Bob")
+	if rec == nil || len(rec.Events) != 4 || rec.Duration() != 15*time.Second {
+		t.Errorf("expected a recording of Bob's solve")
+	}
+}
+
+func TestRaceProgress(t *testing.T) {
+	p := &Puzzle{Rows: 2, Cols: 2}
+	p.SetSolution([]string{"A.", "BC"})
+	r, _ := NewRace(p)
+	clock := r.created
+	r.now = func() time.Time { return clock }
+	a, _ := r.Join("a")
+	r.Join("b")
+	r.Start()
+	clock = clock.Add(RaceCountdown)
+	r.Update(a, []Event{{Row: 0, Col: 0, Letter: "Z"}})
+	st := r.Status()
+	if st.Players[0].Progress != 1.0/3 || st.Players[0].Place != 0 || st.Winner != "" || st.Over {
+		t.Errorf("expectation failure (expected: a third filled, got: %+v)", st)
+	}
+
+	empty := &Puzzle{Rows: 1, Cols: 2}
+	empty.SetSolution([]string{"  "})
+	if _, err := NewRace(empty); err == nil {
+		t.Errorf("expected error racing a puzzle without a solution")
+	}
+}
+
+func TestRaceSpectate(t *testing.T) {
+	p := &Puzzle{Rows: 2, Cols: 2}
+	p.SetSolution([]string{"A.", "BC"})
+	r, _ := NewRace(p)
+	clock := r.created
+	r.now = func() time.Time { return clock }
+	a, _ := r.Join("a")
+	r.Join("b")
+	r.Start()
+
+	clock = clock.Add(RaceCountdown + 10*time.Second)
+	r.Update(a, []Event{{Row: 0, Col: 0, Letter: "A"}})
+	clock = clock.Add(10 * time.Second)
+	r.Update(a, []Event{{Row: 1, Col: 0, Letter: "B"}, {Row: 1, Col: 1, Letter: "C"}})
+
+	tests := []struct {
+		delay    time.Duration
+		started  bool
+		elapsed  int64
+		letters  [][]string
+		cursor   *[2]int
+		finished int64
+	}{
+		{0, true, 20000, [][]string{{"A", ""}, {"B", "C"}}, &[2]int{1, 1}, 20000},
+		{5 * time.Second, true, 15000, [][]string{{"A", ""}, {"", ""}}, &[2]int{0, 0}, 0},
+		{15 * time.Second, true, 5000, [][]string{{"", ""}, {"", ""}}, nil, 0},
+		{time.Minute, false, 0, [][]string{{"", ""}, {"", ""}}, nil, 0},
+	}
+	for _, tt := range tests {
+		view := r.Spectate(tt.delay)
+		if view.Started != tt.started || view.Elapsed != tt.elapsed || view.Delay != tt.delay.Milliseconds() {
+			t.Errorf("%v: expectation failure (expected: started %v after %dms, got: %v after %dms)", tt.delay, tt.started, tt.elapsed, view.Started, view.Elapsed)
+		}
+		grid := view.Grids[0]
+		if fmt.Sprint(grid.Letters) != fmt.Sprint(tt.letters) {
+			t.Errorf("%v: expectation failure (expected: %q, got: %q)", tt.delay, tt.letters, grid.Letters)
+		}
+		if (grid.Cursor == nil) != (tt.cursor == nil) || grid.Cursor != nil && *grid.Cursor != *tt.cursor {
+			t.Errorf("%v: expectation failure (expected: cursor %v, got: %v)", tt.delay, tt.cursor, grid.Cursor)
+		}
+		if view.Players[0].Finished != tt.finished || (view.Winner != "") != (tt.finished != 0) {
+			t.Errorf("%v: expectation failure (expected: finished %dms, got: %+v)", tt.delay, tt.finished, view.RaceStatus)
+		}
+	}
+}
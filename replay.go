@@ -0,0 +1,135 @@
+package xwd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// Event is a change to one cell of a solving session: the cell's letter and
+// flags after the change, and when it was made.
+type Event struct {
+	Time   time.Duration // Since the start of the session
+	Row    int
+	Col    int
+	Letter string
+	Flags  CellFlags
+}
+
+// jsonEvent is the form of an Event in JSON. Recordings can run to thousands
+// of events, so the keys are kept short.
+type jsonEvent struct {
+	T int64     `json:"t"` // Milliseconds since the start of the session
+	I int       `json:"i"`
+	J int       `json:"j"`
+	L string    `json:"l"`
+	F CellFlags `json:"f,omitempty"`
+}
+
+func (e Event) MarshalJSON() ([]byte, error) {
+	return json.Marshal(jsonEvent{
+		T: e.Time.Milliseconds(),
+		I: e.Row,
+		J: e.Col,
+		L: e.Letter,
+		F: e.Flags,
+	})
+}
+
+func (e *Event) UnmarshalJSON(data []byte) error {
+	var je jsonEvent
+	err := json.Unmarshal(data, &je)
+	if err != nil {
+		return err
+	}
+	*e = Event{
+		Time:   time.Duration(je.T) * time.Millisecond,
+		Row:    je.I,
+		Col:    je.J,
+		Letter: je.L,
+		Flags:  je.F,
+	}
+	return nil
+}
+
+// Recording is the history of a solving session, from which the solve can be
+// replayed.
+type Recording struct {
+	Puzzle   string    `json:"puzzle"` // The fingerprint of the puzzle solved
+	Player   string    `json:"player,omitempty"`
+	Start    time.Time `json:"start"`
+	Assisted bool      `json:"assisted,omitempty"` // Whether autocheck was used
+	Events   []Event   `json:"events"`
+}
+
+// Recording returns the history of the session, as a solve of the puzzle by
+// the named player.
+func (s *Session) Recording(p *Puzzle, player string) *Recording {
+	events := make([]Event, len(s.events))
+	copy(events, s.events)
+	return &Recording{
+		Puzzle:   p.Fingerprint(),
+		Player:   player,
+		Start:    s.start,
+		Assisted: s.assisted,
+		Events:   events,
+	}
+}
+
+// ReadRecording reads a recording in the JSON form written by WriteRecording.
+// The events must be in order of time.
+func ReadRecording(r io.Reader) (*Recording, error) {
+	rec := &Recording{}
+	err := json.NewDecoder(r).Decode(rec)
+	if err != nil {
+		return nil, err
+	}
+	if rec.Events == nil {
+		rec.Events = make([]Event, 0)
+	}
+	for k := 1; k < len(rec.Events); k++ {
+		if rec.Events[k].Time < rec.Events[k-1].Time {
+			return nil, fmt.Errorf("event %d is out of order", k+1)
+		}
+	}
+	return rec, nil
+}
+
+// WriteRecording writes a recording to w as JSON.
+func WriteRecording(w io.Writer, rec *Recording) error {
+	return json.NewEncoder(w).Encode(rec)
+}
+
+// Duration returns the time from the start of the recording to its last
+// event.
+func (rec *Recording) Duration() time.Duration {
+	if len(rec.Events) == 0 {
+		return 0
+	}
+	return rec.Events[len(rec.Events)-1].Time
+}
+
+// Replay returns the state of the session at the given time into the
+// recording. The puzzle must be the one the recording was made of.
+func (rec *Recording) Replay(p *Puzzle, at time.Duration) (*Session, error) {
+	if rec.Puzzle != "" && rec.Puzzle != p.Fingerprint() {
+		return nil, errors.New("the recording isn't of this puzzle")
+	}
+	s := NewSession(p)
+	s.start = rec.Start
+	s.assisted = rec.Assisted
+	for _, e := range rec.Events {
+		if e.Time > at {
+			break
+		}
+		c, err := s.index(e.Row, e.Col)
+		if err != nil {
+			return nil, fmt.Errorf("event at %v: %v", e.Time, err)
+		}
+		s.letters[c], s.flags[c] = e.Letter, e.Flags
+		s.events = append(s.events, e)
+	}
+	return s, nil
+}
@@ -0,0 +1,71 @@
+package xwd
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestRecording(t *testing.T) {
+	p := &Puzzle{Rows: 2, Cols: 2}
+	p.SetSolution([]string{"A.", "BC"})
+	s := NewSession(p)
+	clock := s.start
+	s.now = func() time.Time { return clock }
+
+	clock = clock.Add(time.Second)
+	s.Set(0, 0, "A", false)
+	clock = clock.Add(2500 * time.Millisecond)
+	s.Set(1, 0, "X", true)
+	clock = clock.Add(time.Second)
+	s.Set(1, 0, "B", false)
+
+	var buf bytes.Buffer
+	err := WriteRecording(&buf, s.Recording(p, "nick"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec, err := ReadRecording(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rec.Player != "nick" || len(rec.Events) != 3 {
+		t.Fatalf("expectation failure (expected: 3 events by nick, got: %v by %v)", len(rec.Events), rec.Player)
+	}
+	if d := rec.Duration(); d != 4500*time.Millisecond {
+		t.Errorf("expectation failure (expected: %v, got: %v)", 4500*time.Millisecond, d)
+	}
+
+	tests := []struct {
+		at     time.Duration
+		letter string
+		pencil bool
+	}{
+		{0, "", false},
+		{3 * time.Second, "", false},
+		{3500 * time.Millisecond, "X", true},
+		{time.Hour, "B", false},
+	}
+	for _, tt := range tests {
+		r, err := rec.Replay(p, tt.at)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if l := r.Letter(1, 0); l != tt.letter || r.Pencilled(1, 0) != tt.pencil {
+			t.Errorf("at %v: expectation failure (expected: %q pencilled %v, got: %q pencilled %v)", tt.at, tt.letter, tt.pencil, l, r.Pencilled(1, 0))
+		}
+	}
+
+	other := &Puzzle{Rows: 2, Cols: 2}
+	other.SetSolution([]string{"Z.", "BC"})
+	if _, err := rec.Replay(other, 0); err == nil {
+		t.Errorf("expected error replaying a recording of a different puzzle")
+	}
+}
+
+func TestReadRecordingOutOfOrder(t *testing.T) {
+	_, err := ReadRecording(bytes.NewBufferString(`{"puzzle":"","events":[{"t":10,"i":0,"j":0,"l":"A"},{"t":5,"i":0,"j":0,"l":"B"}]}`))
+	if err == nil {
+		t.Errorf("expected error reading events out of order")
+	}
+}
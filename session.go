@@ -0,0 +1,234 @@
+package xwd
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+// CellFlags record how a cell was filled in during a solving session. The
+// values are those used in the GEXT section of AcrossLite files.
+type CellFlags byte
+
+const (
+	FlagPencil       CellFlags = 0x08 // The letter is a tentative guess
+	FlagWasIncorrect CellFlags = 0x10 // The cell has been marked incorrect at some point
+	FlagIncorrect    CellFlags = 0x20 // The cell is currently marked incorrect
+	FlagRevealed     CellFlags = 0x40 // The letter was revealed
+	FlagCircled      CellFlags = 0x80 // The cell is circled
+)
+
+// Session holds a solver's progress through a puzzle: the letter entered in
+// each white cell, and flags recording how it got there. Every change is
+// recorded, with the time it was made, so that the solve can be replayed
+// (see Recording).
+type Session struct {
+	Rows      int
+	Cols      int
+	black     []bool
+	solution  []string
+	letters   []string
+	flags     []CellFlags
+	autocheck bool
+	assisted  bool // Whether autocheck has ever been turned on
+	start     time.Time
+	events    []Event
+	now       func() time.Time
+}
+
+var BlackCell = errors.New("the cell at the provided coordinates is black")
+
+// NewSession returns an empty solving session for the puzzle, starting now.
+func NewSession(p *Puzzle) *Session {
+	n := p.Rows * p.Cols
+	s := &Session{
+		Rows:     p.Rows,
+		Cols:     p.Cols,
+		black:    make([]bool, n),
+		solution: make([]string, n),
+		letters:  make([]string, n),
+		flags:    make([]CellFlags, n),
+		events:   make([]Event, 0),
+		now:      time.Now,
+	}
+	s.start = s.now()
+	for i, cell := range p.cells {
+		s.black[i] = cell.Black
+		s.solution[i] = strings.ToUpper(cell.Solution)
+	}
+	return s
+}
+
+// index returns the index of the white cell at row i, column j, or an error if
+// there is no such cell.
+func (s *Session) index(i, j int) (int, error) {
+	if i < 0 || i >= s.Rows || j < 0 || j >= s.Cols {
+		return 0, OutOfBounds
+	}
+	c := i*s.Cols + j
+	if s.black[c] {
+		return 0, BlackCell
+	}
+	return c, nil
+}
+
+// Letter returns the letter entered at row i, column j, or "" if the cell is
+// empty (or doesn't exist).
+func (s *Session) Letter(i, j int) string {
+	c, err := s.index(i, j)
+	if err != nil {
+		return ""
+	}
+	return s.letters[c]
+}
+
+// Flags returns the flags of the cell at row i, column j.
+func (s *Session) Flags(i, j int) CellFlags {
+	c, err := s.index(i, j)
+	if err != nil {
+		return 0
+	}
+	return s.flags[c]
+}
+
+// Pencilled reports whether the letter at row i, column j was pencilled in.
+func (s *Session) Pencilled(i, j int) bool {
+	return s.Flags(i, j)&FlagPencil != 0
+}
+
+// Set enters a letter (or clears the cell, if letter is "") at row i, column
+// j, pencilled in if pencil is set. Any mark that the cell is incorrect is
+// removed, as the letter has changed, unless autocheck is on and the new
+// letter is wrong too.
+func (s *Session) Set(i, j int, letter string, pencil bool) error {
+	c, err := s.index(i, j)
+	if err != nil {
+		return err
+	}
+	s.letters[c] = strings.ToUpper(letter)
+	s.flags[c] &^= FlagIncorrect | FlagPencil
+	if pencil && letter != "" {
+		s.flags[c] |= FlagPencil
+	}
+	if s.autocheck {
+		s.check(c)
+	}
+	s.record(c)
+	return nil
+}
+
+// SetAutocheck turns autocheck on or off. While it's on, every letter is
+// checked against the solution as soon as it's entered, and marked incorrect
+// if it's wrong. Turning it on checks the letters already entered, and marks
+// the session as assisted.
+func (s *Session) SetAutocheck(on bool) {
+	s.autocheck = on
+	if on {
+		s.assisted = true
+		s.Check()
+	}
+}
+
+// Autocheck reports whether autocheck is on.
+func (s *Session) Autocheck() bool {
+	return s.autocheck
+}
+
+// Assisted reports whether autocheck has been used at any point in the
+// session, so that assisted solves can be told apart from the rest.
+func (s *Session) Assisted() bool {
+	return s.assisted
+}
+
+// SetFlags replaces the flags of the cell at row i, column j.
+func (s *Session) SetFlags(i, j int, flags CellFlags) error {
+	c, err := s.index(i, j)
+	if err != nil {
+		return err
+	}
+	s.flags[c] = flags
+	s.record(c)
+	return nil
+}
+
+// Check compares the filled cells with the puzzle's solution, marking those
+// that are wrong as incorrect (setting FlagIncorrect and FlagWasIncorrect),
+// and returns their coordinates. A rebus cell must have the whole of its
+// solution to be right.
+func (s *Session) Check() [][2]int {
+	wrong := make([][2]int, 0)
+	for c := range s.letters {
+		if s.check(c) {
+			s.record(c)
+			wrong = append(wrong, [2]int{c / s.Cols, c % s.Cols})
+		}
+	}
+	return wrong
+}
+
+// check checks the letter in cell c, as Check, and reports whether it's
+// wrong.
+func (s *Session) check(c int) bool {
+	if s.black[c] || s.letters[c] == "" || s.solution[c] == "" || s.letters[c] == s.solution[c] {
+		return false
+	}
+	s.flags[c] |= FlagIncorrect | FlagWasIncorrect
+	return true
+}
+
+// Filled returns how many of the white cells have a letter entered, and how
+// many white cells there are.
+func (s *Session) Filled() (filled, total int) {
+	for c, black := range s.black {
+		if black {
+			continue
+		}
+		total++
+		if s.letters[c] != "" {
+			filled++
+		}
+	}
+	return filled, total
+}
+
+// Solved reports whether every white cell holds its solution. A puzzle
+// without a solution is never solved.
+func (s *Session) Solved() bool {
+	for c, black := range s.black {
+		if !black && (s.solution[c] == "" || s.letters[c] != s.solution[c]) {
+			return false
+		}
+	}
+	return true
+}
+
+// Start returns the time the session started.
+func (s *Session) Start() time.Time {
+	return s.start
+}
+
+// Events returns every change made to the session, in order.
+func (s *Session) Events() []Event {
+	return s.events
+}
+
+// record records the current state of cell c as an event.
+func (s *Session) record(c int) {
+	s.events = append(s.events, Event{
+		Time:   s.now().Sub(s.start),
+		Row:    c / s.Cols,
+		Col:    c % s.Cols,
+		Letter: s.letters[c],
+		Flags:  s.flags[c],
+	})
+}
+
+// hasFlags reports whether any cell has any flags set.
+func (s *Session) hasFlags() bool {
+	for _, f := range s.flags {
+		if f != 0 {
+			return true
+		}
+	}
+	return false
+}
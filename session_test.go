@@ -0,0 +1,60 @@
+package xwd
+
+import "testing"
+
+func TestSessionSet(t *testing.T) {
+	p := &Puzzle{Rows: 2, Cols: 2}
+	p.SetSolution([]string{"A.", "BC"})
+	s := NewSession(p)
+	if err := s.Set(0, 1, "Z", false); err != BlackCell {
+		t.Errorf("expectation failure (expected: %v, got: %v)", BlackCell, err)
+	}
+	if err := s.Set(2, 0, "Z", false); err != OutOfBounds {
+		t.Errorf("expectation failure (expected: %v, got: %v)", OutOfBounds, err)
+	}
+	s.SetFlags(1, 0, FlagIncorrect|FlagCircled)
+	s.Set(1, 0, "b", true)
+	if f := s.Flags(1, 0); f != FlagPencil|FlagCircled {
+		t.Errorf("expectation failure (expected: %v, got: %v)", FlagPencil|FlagCircled, f)
+	}
+	s.Set(1, 0, "", true)
+	if s.Pencilled(1, 0) || s.Letter(1, 0) != "" {
+		t.Errorf("clearing a cell should remove its pencil mark")
+	}
+}
+
+func TestSessionAutocheck(t *testing.T) {
+	p := &Puzzle{Rows: 2, Cols: 2}
+	p.SetSolution([]string{"A.", "BC"})
+	s := NewSession(p)
+	s.Set(0, 0, "X", false)
+	if s.Flags(0, 0)&FlagIncorrect != 0 || s.Assisted() {
+		t.Errorf("letters shouldn't be checked until autocheck is on")
+	}
+
+	s.SetAutocheck(true)
+	if !s.Assisted() {
+		t.Errorf("expected session to be marked as assisted")
+	}
+	if s.Flags(0, 0)&FlagIncorrect == 0 {
+		t.Errorf("expected letters already entered to be checked")
+	}
+	s.Set(1, 0, "b", false)
+	s.Set(1, 1, "D", true)
+	if f := s.Flags(1, 0); f != 0 {
+		t.Errorf("expectation failure (expected: %v, got: %v)", CellFlags(0), f)
+	}
+	if f := s.Flags(1, 1); f != FlagPencil|FlagIncorrect|FlagWasIncorrect {
+		t.Errorf("expectation failure (expected: %v, got: %v)", FlagPencil|FlagIncorrect|FlagWasIncorrect, f)
+	}
+	s.Set(1, 1, "C", false)
+	if f := s.Flags(1, 1); f != FlagWasIncorrect {
+		t.Errorf("expectation failure (expected: %v, got: %v)", FlagWasIncorrect, f)
+	}
+
+	s.SetAutocheck(false)
+	s.Set(1, 1, "E", false)
+	if s.Flags(1, 1)&FlagIncorrect != 0 || !s.Assisted() {
+		t.Errorf("turning autocheck off should stop checking, but leave the session assisted")
+	}
+}
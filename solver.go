@@ -0,0 +1,411 @@
+package xwd
+
+import (
+	"sort"
+)
+
+// DefaultSolverSteps is the number of search steps a Solver takes, unless
+// told otherwise, before giving up on finding a complete fill.
+const DefaultSolverSteps = 10000
+
+// clueMatchScore is the score a candidate gets for each time its answer has
+// been seen with the entry's clue, so that clue database matches outrank
+// anything that's only in the word list.
+const clueMatchScore = 1000
+
+// allLetters is the domain of a cell about which nothing is known.
+const allLetters = 1<<26 - 1
+
+// Candidate is a possible answer for an entry.
+type Candidate struct {
+	Answer string
+	Score  int
+}
+
+// Solver attempts to solve puzzles from their clues and the shape of their
+// grid alone, as a test of how fair a puzzle is. Candidate answers for each
+// entry come from a clue database and a word list, and the grid is solved by
+// propagating the constraints between crossing entries, searching among the
+// remaining candidates where that isn't enough.
+type Solver struct {
+	Clues    *ClueDB   // Answers previously seen for each clue (may be nil)
+	Words    *WordList // Candidate answers for any entry (may be nil)
+	MaxSteps int       // The search budget (0 for DefaultSolverSteps)
+
+	byLen map[int][]string // The word list, indexed by word length
+}
+
+// Candidates returns the candidate answers for an entry, best first.
+func (s *Solver) Candidates(e Entry) []Candidate {
+	n := len(e.Cells)
+	scores := make(map[string]int)
+	if s.Clues != nil {
+		for answer, count := range s.Clues.Lookup(e.Clue) {
+			if len(answer) == n {
+				scores[answer] += clueMatchScore * count
+			}
+		}
+	}
+	if s.Words != nil {
+		if s.byLen == nil {
+			s.byLen = make(map[int][]string)
+			for word := range s.Words.scores {
+				s.byLen[len(word)] = append(s.byLen[len(word)], word)
+			}
+		}
+		for _, word := range s.byLen[n] {
+			scores[word] += s.Words.scores[word]
+		}
+	}
+
+	cands := make([]Candidate, 0, len(scores))
+	for answer, score := range scores {
+		cands = append(cands, Candidate{answer, score})
+	}
+	sort.Slice(cands, func(i, j int) bool {
+		if cands[i].Score != cands[j].Score {
+			return cands[i].Score > cands[j].Score
+		}
+		return cands[i].Answer < cands[j].Answer
+	})
+	return cands
+}
+
+// SolveResult is the outcome of solving a puzzle.
+type SolveResult struct {
+	Rows       int
+	Cols       int
+	Solved     bool // Whether a complete, consistent fill was found
+	Steps      int  // The number of search steps taken
+	letters    []string
+	confidence []float64
+}
+
+// Letter returns the letter the solver put in the cell at row i, column j, or
+// "" if it has no idea.
+func (r *SolveResult) Letter(i, j int) string {
+	return r.letters[i*r.Cols+j]
+}
+
+// Confidence returns how sure the solver is of the letter in the cell at row
+// i, column j, from 0 to 1. This is the share (by score) of the candidates for
+// the cell's entries that agree with the letter, once the constraints from
+// crossing entries have been applied, but before any searching.
+func (r *SolveResult) Confidence(i, j int) float64 {
+	return r.confidence[i*r.Cols+j]
+}
+
+// Accuracy returns the fraction of p's white cells for which the solver found
+// the right letter.
+func (r *SolveResult) Accuracy(p *Puzzle) float64 {
+	right, total := 0, 0
+	for i, cell := range p.cells {
+		if cell.Black {
+			continue
+		}
+		total++
+		if r.letters[i] != "" && r.letters[i] == cell.Solution {
+			right++
+		}
+	}
+	if total == 0 {
+		return 0
+	}
+	return float64(right) / float64(total)
+}
+
+// Solve solves the puzzle. Only the shape of the grid and the clues are used:
+// the puzzle's own solution, if it has one, is ignored.
+func (s *Solver) Solve(p *Puzzle) *SolveResult {
+	sv := &solve{steps: s.maxSteps()}
+	sv.byCell = make([][]int, len(p.cells))
+	for k, e := range p.Entries() {
+		se := solveEntry{cells: make([]int, len(e.Cells))}
+		for pos, c := range e.Cells {
+			se.cells[pos] = c[0]*p.Cols + c[1]
+			sv.byCell[se.cells[pos]] = append(sv.byCell[se.cells[pos]], k)
+		}
+		for _, cand := range s.Candidates(e) {
+			se.answers = append(se.answers, cand.Answer)
+			se.weights = append(se.weights, float64(cand.Score+1))
+		}
+		sv.entries = append(sv.entries, se)
+	}
+
+	// Entries that can't be filled consistently with the rest, even before
+	// searching, are most likely missing from the clue database and word
+	// list, so they're left out rather than sinking the whole solve. The
+	// entries conflicting with the most crossings go first, and then any
+	// that propagation finds it can't fill.
+	sv.dropConflicts()
+	var st *solveState
+	for {
+		st = sv.initial(p)
+		failed := sv.propagate(st, nil)
+		if failed < 0 {
+			break
+		}
+		sv.entries[failed].answers = nil
+	}
+
+	res := &SolveResult{
+		Rows:       p.Rows,
+		Cols:       p.Cols,
+		letters:    make([]string, len(p.cells)),
+		confidence: make([]float64, len(p.cells)),
+	}
+	votes := sv.votes(st)
+
+	final, ok := sv.search(st.clone())
+	res.Solved = ok
+	res.Steps = s.maxSteps() - sv.steps
+	if !ok {
+		final = st
+	}
+	finalVotes := sv.votes(final)
+	for c, cell := range p.cells {
+		best, bestWeight, total := -1, 0.0, 0.0
+		for l, w := range finalVotes[c] {
+			if w > bestWeight {
+				best, bestWeight = l, w
+			}
+		}
+		if best < 0 {
+			// Only cells covered by entries with candidates get filled.
+			res.Solved = res.Solved && cell.Black
+			continue
+		}
+		res.letters[c] = string(rune('A' + best))
+		for _, w := range votes[c] {
+			total += w
+		}
+		if total > 0 {
+			res.confidence[c] = votes[c][best] / total
+		}
+	}
+	return res
+}
+
+func (s *Solver) maxSteps() int {
+	if s.MaxSteps <= 0 {
+		return DefaultSolverSteps
+	}
+	return s.MaxSteps
+}
+
+// solve holds the working state of Solver.Solve.
+type solve struct {
+	entries []solveEntry
+	byCell  [][]int // The entries crossing each cell
+	steps   int     // The remaining search budget
+}
+
+type solveEntry struct {
+	cells   []int // Indices into the puzzle's cells
+	answers []string
+	weights []float64
+}
+
+// solveState is a point in the search: the letters still possible in each
+// cell, and the candidates still possible for each entry.
+type solveState struct {
+	domains []uint32
+	alive   [][]int // Indices into each entry's answers, best first
+}
+
+func (st *solveState) clone() *solveState {
+	c := &solveState{
+		domains: make([]uint32, len(st.domains)),
+		alive:   make([][]int, len(st.alive)),
+	}
+	copy(c.domains, st.domains)
+	copy(c.alive, st.alive)
+	return c
+}
+
+func (sv *solve) initial(p *Puzzle) *solveState {
+	st := &solveState{
+		domains: make([]uint32, len(p.cells)),
+		alive:   make([][]int, len(sv.entries)),
+	}
+	for c, cell := range p.cells {
+		if !cell.Black {
+			st.domains[c] = allLetters
+		}
+	}
+	for k, e := range sv.entries {
+		st.alive[k] = make([]int, len(e.answers))
+		for i := range e.answers {
+			st.alive[k][i] = i
+		}
+	}
+	return st
+}
+
+// propagate removes the candidates that don't fit the cell domains, and the
+// letters that no remaining candidate allows, until nothing changes. It starts
+// from the given entries, or every entry if nil, and returns the index of an
+// entry left with no candidates, or -1 if there's no contradiction. Entries
+// with no candidates to begin with don't constrain anything.
+func (sv *solve) propagate(st *solveState, queue []int) int {
+	queued := make([]bool, len(sv.entries))
+	if queue == nil {
+		for k := range sv.entries {
+			queue = append(queue, k)
+		}
+	}
+	for _, k := range queue {
+		queued[k] = true
+	}
+
+	for len(queue) > 0 {
+		k := queue[0]
+		queue = queue[1:]
+		queued[k] = false
+		e := sv.entries[k]
+		if len(e.answers) == 0 {
+			continue
+		}
+
+		alive := st.alive[k]
+		kept := make([]int, 0, len(alive))
+		union := make([]uint32, len(e.cells))
+		for _, i := range alive {
+			answer := e.answers[i]
+			fits := true
+			for pos, c := range e.cells {
+				if st.domains[c]&(1<<(answer[pos]-'A')) == 0 {
+					fits = false
+					break
+				}
+			}
+			if !fits {
+				continue
+			}
+			kept = append(kept, i)
+			for pos := range e.cells {
+				union[pos] |= 1 << (answer[pos] - 'A')
+			}
+		}
+		if len(kept) == 0 {
+			return k
+		}
+		if len(kept) < len(alive) {
+			st.alive[k] = kept
+		}
+
+		for pos, c := range e.cells {
+			d := st.domains[c] & union[pos]
+			if d == st.domains[c] {
+				continue
+			}
+			st.domains[c] = d
+			for _, other := range sv.byCell[c] {
+				if other != k && !queued[other] {
+					queued[other] = true
+					queue = append(queue, other)
+				}
+			}
+		}
+	}
+	return -1
+}
+
+// dropConflicts repeatedly removes the candidates of the entry with the most
+// crossings at which none of its candidates agree with any of the crossing
+// entry's, until there are no such crossings.
+func (sv *solve) dropConflicts() {
+	for len(sv.entries) > 0 {
+		letters := make([]map[int]uint32, len(sv.byCell))
+		for k, e := range sv.entries {
+			if len(e.answers) == 0 {
+				continue
+			}
+			for pos, c := range e.cells {
+				var mask uint32
+				for _, answer := range e.answers {
+					mask |= 1 << (answer[pos] - 'A')
+				}
+				if letters[c] == nil {
+					letters[c] = make(map[int]uint32)
+				}
+				letters[c][k] = mask
+			}
+		}
+
+		conflicts := make([]int, len(sv.entries))
+		for _, masks := range letters {
+			if len(masks) != 2 {
+				continue
+			}
+			var both uint32 = allLetters
+			for _, mask := range masks {
+				both &= mask
+			}
+			if both != 0 {
+				continue
+			}
+			for k := range masks {
+				conflicts[k]++
+			}
+		}
+		worst := 0
+		for k, n := range conflicts {
+			if n > conflicts[worst] {
+				worst = k
+			}
+		}
+		if conflicts[worst] == 0 {
+			return
+		}
+		sv.entries[worst].answers = nil
+	}
+}
+
+// search looks for a complete fill consistent with st, which must already
+// have been propagated, trying the entry with the fewest candidates left
+// first, and its candidates best first.
+func (sv *solve) search(st *solveState) (*solveState, bool) {
+	next := -1
+	for k, e := range sv.entries {
+		if len(e.answers) > 0 && len(st.alive[k]) > 1 && (next < 0 || len(st.alive[k]) < len(st.alive[next])) {
+			next = k
+		}
+	}
+	if next < 0 {
+		return st, true
+	}
+
+	for _, i := range st.alive[next] {
+		if sv.steps <= 0 {
+			return nil, false
+		}
+		sv.steps--
+		try := st.clone()
+		try.alive[next] = []int{i}
+		if sv.propagate(try, []int{next}) >= 0 {
+			continue
+		}
+		if done, ok := sv.search(try); ok {
+			return done, true
+		}
+	}
+	return nil, false
+}
+
+// votes returns, for each cell, the total weight of the candidates in st
+// putting each letter there.
+func (sv *solve) votes(st *solveState) [][26]float64 {
+	votes := make([][26]float64, len(st.domains))
+	for k, e := range sv.entries {
+		if len(e.answers) == 0 {
+			continue
+		}
+		for _, i := range st.alive[k] {
+			for pos, c := range e.cells {
+				votes[c][e.answers[i][pos]-'A'] += e.weights[i]
+			}
+		}
+	}
+	return votes
+}
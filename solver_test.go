@@ -0,0 +1,71 @@
+package xwd
+
+import (
+	"strings"
+	"testing"
+)
+
+func newTestPuzzle(t *testing.T, grid ...string) *Puzzle {
+	p := &Puzzle{Rows: len(grid), Cols: len(grid[0])}
+	err := p.SetSolution(grid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestClueDB(t *testing.T) {
+	db, err := ReadClueDB(strings.NewReader("direction,number,answer,clue\nacross,1,CAT,\"Pet, often (3)\"\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := db.Lookup("pet -- often"); got["CAT"] != 1 {
+		t.Errorf("expectation failure (expected: %v, got: %v)", map[string]int{"CAT": 1}, got)
+	}
+}
+
+func TestSolve(t *testing.T) {
+	p := newTestPuzzle(t, "CAT", "ORE")
+	words := NewWordList()
+	for _, w := range []string{"CAT", "ORE", "BAT", "OAR", "CO", "AR", "TE", "OX"} {
+		words.Add(w, 50)
+	}
+	res := (&Solver{Words: words}).Solve(p)
+	if !res.Solved {
+		t.Errorf("expected the puzzle to be solved")
+	}
+	if acc := res.Accuracy(p); acc != 1 {
+		t.Errorf("expectation failure (expected accuracy: %v, got: %v)", 1, acc)
+	}
+	if c := res.Confidence(1, 1); c != 1 {
+		t.Errorf("expectation failure (expected confidence: %v, got: %v)", 1, c)
+	}
+}
+
+func TestSolveFromClues(t *testing.T) {
+	p := loadFixture(t, "version_13.puz")
+	db := NewClueDB()
+	db.AddPuzzle(p)
+	res := (&Solver{Clues: db}).Solve(p)
+	if !res.Solved {
+		t.Errorf("expected the puzzle to be solved")
+	}
+	if acc := res.Accuracy(p); acc != 1 {
+		t.Errorf("expectation failure (expected accuracy: %v, got: %v)", 1, acc)
+	}
+}
+
+func TestSolveUnknownEntries(t *testing.T) {
+	p := newTestPuzzle(t, "CAT", "ORE")
+	words := NewWordList()
+	for _, w := range []string{"CAT", "CO", "AR", "TE"} {
+		words.Add(w, 50)
+	}
+	res := (&Solver{Words: words}).Solve(p)
+	if got := res.Letter(1, 1); got != "R" {
+		t.Errorf("expectation failure (expected: %v, got: %v)", "R", got)
+	}
+	if acc := res.Accuracy(p); acc != 1 {
+		t.Errorf("expectation failure (expected accuracy: %v, got: %v)", 1, acc)
+	}
+}
@@ -0,0 +1,82 @@
+package xwd
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+var texEscaper = strings.NewReplacer(
+	`\`, `\textbackslash{}`,
+	`{`, `\{`,
+	`}`, `\}`,
+	`&`, `\&`,
+	`%`, `\%`,
+	`$`, `\$`,
+	`#`, `\#`,
+	`_`, `\_`,
+	`~`, `\textasciitilde{}`,
+	`^`, `\textasciicircum{}`,
+)
+
+// WriteTeX writes the puzzle to w as LaTeX for the cwpuzzle package: a Puzzle
+// environment containing the grid (numbers, black cells and solution letters)
+// followed by PuzzleClues environments for the across and down clues, and for
+// any custom entries, which are labelled with their Label. The
+// output is a fragment, intended to be included in a document that loads
+// cwpuzzle.
+func WriteTeX(w io.Writer, p *Puzzle) error {
+	bw := bufio.NewWriter(w)
+
+	fmt.Fprintf(bw, "\\begin{Puzzle}{%d}{%d}\n", p.Cols, p.Rows)
+	for _, row := range p.Solution() {
+		for _, cell := range row {
+			switch {
+			case cell.Black:
+				bw.WriteString("|*")
+			case cell.Num != -1:
+				fmt.Fprintf(bw, "|[%d]%s", cell.Num+1, texEscaper.Replace(cell.Solution))
+			default:
+				fmt.Fprintf(bw, "|%s", texEscaper.Replace(cell.Solution))
+			}
+		}
+		bw.WriteString("|.\n")
+	}
+	bw.WriteString("\\end{Puzzle}\n")
+
+	entries := p.Entries()
+	if len(p.CluesAcross()) > 0 {
+		writeTeXClues(bw, entries, Across, "Across")
+	}
+	if len(p.CluesDown()) > 0 {
+		writeTeXClues(bw, entries, Down, "Down")
+	}
+	if len(p.CluesCustom()) > 0 {
+		writeTeXClues(bw, entries, Custom, "Other")
+	}
+
+	return bw.Flush()
+}
+
+func writeTeXClues(bw *bufio.Writer, entries []Entry, dir Direction, heading string) {
+	fmt.Fprintf(bw, "\n\\begin{PuzzleClues}{\\textbf{%s}}\n", heading)
+	for _, e := range entries {
+		if e.Direction != dir {
+			continue
+		}
+		// Many setters already include the enumeration in the clue text.
+		clue := e.Clue
+		if !strings.HasSuffix(clue, e.Enumeration()) {
+			clue = clue + " " + e.Enumeration()
+		}
+		label := strconv.Itoa(e.Num + 1)
+		if e.Direction == Custom {
+			label = texEscaper.Replace(e.Label)
+		}
+		fmt.Fprintf(bw, "\\Clue{%s}{%s}{%s}\n",
+			label, texEscaper.Replace(e.Answer), texEscaper.Replace(clue))
+	}
+	bw.WriteString("\\end{PuzzleClues}\n")
+}
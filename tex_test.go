@@ -0,0 +1,44 @@
+package xwd
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestWriteTeX(t *testing.T) {
+	p := &Puzzle{Rows: 3, Cols: 3}
+	err := p.SetSolution([]string{
+		"CAT",
+		"..A",
+		"..N",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.CluesAcross()[0].Clue = "Feline & friends"
+	p.CluesDown()[0].Clue = "Brown, 100% (3)"
+
+	var buf bytes.Buffer
+	err = WriteTeX(&buf, p)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `\begin{Puzzle}{3}{3}
+|[1]C|A|[2]T|.
+|*|*|A|.
+|*|*|N|.
+\end{Puzzle}
+
+\begin{PuzzleClues}{\textbf{Across}}
+\Clue{1}{CAT}{Feline \& friends (3)}
+\end{PuzzleClues}
+
+\begin{PuzzleClues}{\textbf{Down}}
+\Clue{2}{TAN}{Brown, 100\% (3)}
+\end{PuzzleClues}
+`
+	if buf.String() != expected {
+		t.Errorf("TeX output expectation failure (expected: %q, got: %q)", expected, buf.String())
+	}
+}
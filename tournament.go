@@ -0,0 +1,304 @@
+package xwd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ScoringRules says how a tournament submission is scored.
+type ScoringRules struct {
+	WordPoints    int `json:"word"`     // For each entry filled in correctly
+	CompleteBonus int `json:"complete"` // For a grid without mistakes
+	MinuteBonus   int `json:"minute"`   // For each full minute left before the time limit
+
+	// ErrorPenalty is taken from the time bonus, but not below zero, for each
+	// wrong or missing letter.
+	ErrorPenalty int `json:"error"`
+}
+
+// ACPTScoring are the scoring rules of the American Crossword Puzzle
+// Tournament.
+var ACPTScoring = ScoringRules{WordPoints: 10, CompleteBonus: 150, MinuteBonus: 25, ErrorPenalty: 25}
+
+// Score is the breakdown of the points scored by a submission.
+type Score struct {
+	Words    int  `json:"words"`    // How many entries are correct
+	Errors   int  `json:"errors"`   // How many letters are wrong or missing
+	Complete bool `json:"complete"` // Whether the grid is free of mistakes
+	Minutes  int  `json:"minutes"`  // How many full minutes were left
+	Bonus    int  `json:"bonus"`    // The time bonus, after penalties for errors
+	Points   int  `json:"points"`
+}
+
+// Score scores a solving session of the puzzle, submitted with the given time
+// left before the limit. The accepted cells are counted as correct whatever
+// they hold, so that judges can allow alternative answers.
+func (rules ScoringRules) Score(p *Puzzle, s *Session, left time.Duration, accepted [][2]int) Score {
+	ok := make([]bool, len(s.letters))
+	for c := range s.letters {
+		ok[c] = s.black[c] || s.letters[c] == s.solution[c]
+	}
+	for _, a := range accepted {
+		if c, err := s.index(a[0], a[1]); err == nil {
+			ok[c] = true
+		}
+	}
+
+	var score Score
+	for c := range ok {
+		if !ok[c] {
+			score.Errors++
+		}
+	}
+	for _, e := range p.Entries() {
+		correct := true
+		for _, c := range e.Cells {
+			correct = correct && ok[c[0]*s.Cols+c[1]]
+		}
+		if correct {
+			score.Words++
+		}
+	}
+	score.Complete = score.Errors == 0
+	if left > 0 {
+		score.Minutes = int(left / time.Minute)
+	}
+	score.Bonus = rules.MinuteBonus*score.Minutes - rules.ErrorPenalty*score.Errors
+	if score.Bonus < 0 {
+		score.Bonus = 0
+	}
+	score.Points = rules.WordPoints*score.Words + score.Bonus
+	if score.Complete {
+		score.Points += rules.CompleteBonus
+	}
+	return score
+}
+
+// Tournament describes a crossword tournament: a series of timed rounds, in
+// each of which the players solve one or more puzzles.
+type Tournament struct {
+	Title   string        `json:"title"`
+	Rounds  []Round       `json:"rounds"`
+	Scoring *ScoringRules `json:"scoring,omitempty"` // If nil, ACPTScoring
+
+	// Admin is the password for the judging view. If it's empty, there's no
+	// judging view.
+	Admin string `json:"admin,omitempty"`
+}
+
+// Round is one round of a tournament. Its puzzles can be solved from the
+// start of the round until its time limit runs out.
+type Round struct {
+	Name    string
+	Start   time.Time
+	Limit   time.Duration
+	Puzzles []string // The file names of the puzzles
+}
+
+// jsonRound is the form of a Round in JSON, with the limit written as a
+// duration such as "15m".
+type jsonRound struct {
+	Name    string    `json:"name"`
+	Start   time.Time `json:"start"`
+	Limit   string    `json:"limit"`
+	Puzzles []string  `json:"puzzles"`
+}
+
+func (r Round) MarshalJSON() ([]byte, error) {
+	return json.Marshal(jsonRound{
+		Name:    r.Name,
+		Start:   r.Start,
+		Limit:   r.Limit.String(),
+		Puzzles: r.Puzzles,
+	})
+}
+
+func (r *Round) UnmarshalJSON(data []byte) error {
+	var jr jsonRound
+	err := json.Unmarshal(data, &jr)
+	if err != nil {
+		return err
+	}
+	limit, err := time.ParseDuration(jr.Limit)
+	if err != nil {
+		return fmt.Errorf("round %q: bad limit: %v", jr.Name, err)
+	}
+	*r = Round{Name: jr.Name, Start: jr.Start, Limit: limit, Puzzles: jr.Puzzles}
+	return nil
+}
+
+// Deadline returns the time by which the round's puzzles must be submitted.
+func (r Round) Deadline() time.Time {
+	return r.Start.Add(r.Limit)
+}
+
+// Open reports whether the round's puzzles can be solved at the given time.
+func (r Round) Open(at time.Time) bool {
+	return !at.Before(r.Start) && at.Before(r.Deadline())
+}
+
+// ReadTournament reads the description of a tournament from JSON, e.g.
+//
+//	{
+//	  "title": "Spring tournament",
+//	  "admin": "secret",
+//	  "rounds": [
+//	    {"name": "Round 1", "start": "2026-04-01T10:00:00Z", "limit": "20m", "puzzles": ["r1.puz"]}
+//	  ]
+//	}
+func ReadTournament(r io.Reader) (*Tournament, error) {
+	t := &Tournament{}
+	err := json.NewDecoder(r).Decode(t)
+	if err != nil {
+		return nil, err
+	}
+	if len(t.Rounds) == 0 {
+		return nil, errors.New("a tournament needs at least one round")
+	}
+	for _, round := range t.Rounds {
+		if round.Limit <= 0 || len(round.Puzzles) == 0 {
+			return nil, fmt.Errorf("round %q needs a time limit and at least one puzzle", round.Name)
+		}
+	}
+	return t, nil
+}
+
+// Rules returns the scoring rules of the tournament.
+func (t *Tournament) Rules() ScoringRules {
+	if t.Scoring == nil {
+		return ACPTScoring
+	}
+	return *t.Scoring
+}
+
+// Submission is a player's solution to one of the puzzles of a tournament.
+type Submission struct {
+	Player string     `json:"player"`
+	Round  int        `json:"round"`  // The index of the round
+	Puzzle int        `json:"puzzle"` // The index of the puzzle in the round
+	Time   time.Time  `json:"time"`   // When it was submitted
+	Grid   [][]string `json:"grid"`   // The letters entered, row by row
+
+	// Accepted are the cells that the judges have accepted as correct,
+	// whatever they hold.
+	Accepted [][2]int `json:"accepted,omitempty"`
+
+	Score Score `json:"score"`
+}
+
+// Score scores the submission, which must be of the puzzle p, under the rules
+// of the tournament, setting its Score.
+func (t *Tournament) Score(sub *Submission, p *Puzzle) error {
+	if sub.Round < 0 || sub.Round >= len(t.Rounds) {
+		return errors.New("no such round")
+	}
+	if len(sub.Grid) != p.Rows {
+		return errors.New("the grid doesn't fit the puzzle")
+	}
+	s := NewSession(p)
+	for i, row := range sub.Grid {
+		if len(row) != p.Cols {
+			return errors.New("the grid doesn't fit the puzzle")
+		}
+		for j, letter := range row {
+			err := s.Set(i, j, strings.TrimSpace(letter), false)
+			if err == BlackCell && letter == "" {
+				continue
+			}
+			if err != nil {
+				return fmt.Errorf("row %d, column %d: %v", i+1, j+1, err)
+			}
+		}
+	}
+	left := t.Rounds[sub.Round].Deadline().Sub(sub.Time)
+	sub.Score = t.Rules().Score(p, s, left, sub.Accepted)
+	return nil
+}
+
+// Accept toggles whether the judges accept the cell at row i, column j of a
+// submission as correct. The submission must be rescored afterwards.
+func (sub *Submission) Accept(i, j int) {
+	for k, a := range sub.Accepted {
+		if a == [2]int{i, j} {
+			sub.Accepted = append(sub.Accepted[:k], sub.Accepted[k+1:]...)
+			return
+		}
+	}
+	sub.Accepted = append(sub.Accepted, [2]int{i, j})
+}
+
+// Standing is a player's position in a tournament.
+type Standing struct {
+	Place  int           `json:"place"`
+	Player string        `json:"player"`
+	Points int           `json:"points"`
+	Solved int           `json:"solved"` // How many puzzles were submitted without mistakes
+	Time   time.Duration `json:"time"`   // The total time taken over the submissions (in milliseconds, in JSON)
+}
+
+func (s Standing) MarshalJSON() ([]byte, error) {
+	type standing Standing
+	return json.Marshal(struct {
+		standing
+		Time int64 `json:"time"`
+	}{standing(s), s.Time.Milliseconds()})
+}
+
+// Standings ranks the players by the points they've scored, with ties broken
+// by the time they took. Players with the same points and time share a place.
+func (t *Tournament) Standings(players []string, subs []*Submission) []Standing {
+	byPlayer := make(map[string]*Standing)
+	standings := make([]*Standing, 0, len(players))
+	add := func(player string) *Standing {
+		st, ok := byPlayer[player]
+		if !ok {
+			st = &Standing{Player: player}
+			byPlayer[player] = st
+			standings = append(standings, st)
+		}
+		return st
+	}
+	for _, player := range players {
+		add(player)
+	}
+	for _, sub := range subs {
+		st := add(sub.Player)
+		st.Points += sub.Score.Points
+		if sub.Score.Complete {
+			st.Solved++
+		}
+		if sub.Round >= 0 && sub.Round < len(t.Rounds) {
+			round := t.Rounds[sub.Round]
+			taken := sub.Time.Sub(round.Start)
+			if taken > round.Limit {
+				taken = round.Limit
+			}
+			st.Time += taken
+		}
+	}
+
+	sort.SliceStable(standings, func(i, j int) bool {
+		a, b := standings[i], standings[j]
+		if a.Points != b.Points {
+			return a.Points > b.Points
+		}
+		if a.Time != b.Time {
+			return a.Time < b.Time
+		}
+		return a.Player < b.Player
+	})
+	result := make([]Standing, len(standings))
+	for k, st := range standings {
+		st.Place = k + 1
+		if k > 0 && st.Points == standings[k-1].Points && st.Time == standings[k-1].Time {
+			st.Place = result[k-1].Place
+		}
+		result[k] = *st
+	}
+	return result
+}
@@ -0,0 +1,134 @@
+package xwd
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestScoringRules(t *testing.T) {
+	p := &Puzzle{Rows: 2, Cols: 2}
+	p.SetSolution([]string{"AB", "CD"})
+
+	tests := []struct {
+		grid     []string
+		left     time.Duration
+		accepted [][2]int
+		expected Score
+	}{
+		{[]string{"AB", "CD"}, 5*time.Minute + 59*time.Second, nil, Score{Words: 4, Complete: true, Minutes: 5, Bonus: 125, Points: 40 + 125 + 150}},
+		{[]string{"AB", "CX"}, 5 * time.Minute, nil, Score{Words: 2, Errors: 1, Minutes: 5, Bonus: 100, Points: 20 + 100}},
+		{[]string{"AB", "C "}, 30 * time.Second, nil, Score{Words: 2, Errors: 1, Points: 20}},
+		{[]string{"AB", "CX"}, -time.Minute, nil, Score{Words: 2, Errors: 1, Points: 20}},
+		{[]string{"AB", "CX"}, time.Minute, [][2]int{{1, 1}}, Score{Words: 4, Complete: true, Minutes: 1, Bonus: 25, Points: 40 + 25 + 150}},
+	}
+	for _, tt := range tests {
+		s := NewSession(p)
+		for i, row := range tt.grid {
+			for j, c := range row {
+				if c != ' ' {
+					s.Set(i, j, string(c), false)
+				}
+			}
+		}
+		score := ACPTScoring.Score(p, s, tt.left, tt.accepted)
+		if score != tt.expected {
+			t.Errorf("%v: expectation failure (expected: %+v, got: %+v)", tt.grid, tt.expected, score)
+		}
+	}
+}
+
+func TestReadTournament(t *testing.T) {
+	tour, err := ReadTournament(bytes.NewBufferString(`{
+		"title": "Test",
+		"rounds": [{"name": "One", "start": "2026-04-01T10:00:00Z", "limit": "15m", "puzzles": ["a.puz", "b.puz"]}]
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	round := tour.Rounds[0]
+	if round.Limit != 15*time.Minute || len(round.Puzzles) != 2 {
+		t.Errorf("expectation failure (expected: 15m and 2 puzzles, got: %v and %d)", round.Limit, len(round.Puzzles))
+	}
+	if d := round.Deadline(); !d.Equal(time.Date(2026, 4, 1, 10, 15, 0, 0, time.UTC)) {
+		t.Errorf("expectation failure (expected: 10:15, got: %v)", d)
+	}
+	if round.Open(round.Start.Add(-time.Second)) || !round.Open(round.Start) || round.Open(round.Deadline()) {
+		t.Errorf("round should be open from its start until its deadline")
+	}
+	if tour.Rules() != ACPTScoring {
+		t.Errorf("tournaments should use ACPT scoring by default")
+	}
+
+	_, err = ReadTournament(bytes.NewBufferString(`{"rounds": [{"name": "One", "limit": "15m"}]}`))
+	if err == nil {
+		t.Errorf("expected error reading a round without puzzles")
+	}
+}
+
+func TestTournamentStandings(t *testing.T) {
+	p := &Puzzle{Rows: 2, Cols: 2}
+	p.SetSolution([]string{"AB", "CD"})
+	start := time.Date(2026, 4, 1, 10, 0, 0, 0, time.UTC)
+	tour := &Tournament{Rounds: []Round{{Name: "One", Start: start, Limit: 10 * time.Minute, Puzzles: []string{"a.puz"}}}}
+
+	subs := []*Submission{
+		{Player: "ann", Time: start.Add(4 * time.Minute), Grid: [][]string{{"A", "B"}, {"C", "D"}}},
+		{Player: "bob", Time: start.Add(2 * time.Minute), Grid: [][]string{{"A", "B"}, {"C", "X"}}},
+		{Player: "cat", Time: start.Add(4 * time.Minute), Grid: [][]string{{"a", "b"}, {"c", "d"}}},
+	}
+	for _, sub := range subs {
+		if err := tour.Score(sub, p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	st := tour.Standings([]string{"dan", "ann"}, subs)
+	expected := []Standing{
+		{Place: 1, Player: "ann", Points: 340, Solved: 1, Time: 4 * time.Minute},
+		{Place: 1, Player: "cat", Points: 340, Solved: 1, Time: 4 * time.Minute},
+		{Place: 3, Player: "bob", Points: 20 + 175, Time: 2 * time.Minute},
+		{Place: 4, Player: "dan"},
+	}
+	if len(st) != len(expected) {
+		t.Fatalf("expectation failure (expected: %d standings, got: %d)", len(expected), len(st))
+	}
+	for k := range expected {
+		if st[k] != expected[k] {
+			t.Errorf("expectation failure (expected: %+v, got: %+v)", expected[k], st[k])
+		}
+	}
+
+	subs[1].Accept(1, 1)
+	tour.Score(subs[1], p)
+	if !subs[1].Score.Complete || subs[1].Score.Points != 40+200+150 {
+		t.Errorf("expected accepted cell to count as correct, got: %+v", subs[1].Score)
+	}
+	subs[1].Accept(1, 1)
+	if len(subs[1].Accepted) != 0 {
+		t.Errorf("accepting a cell twice should withdraw the acceptance")
+	}
+}
+
+func TestWriteTournamentHTML(t *testing.T) {
+	start := time.Date(2026, 4, 1, 10, 0, 0, 0, time.UTC)
+	tour := &Tournament{Title: "Spring", Rounds: []Round{{Name: "One", Start: start, Limit: 15 * time.Minute, Puzzles: []string{"a.puz"}}}}
+	page := &TournamentPage{
+		Tournament: tour,
+		Now:        start.Add(-time.Hour),
+		Register:   "register",
+		Rounds:     []TournamentRound{{Round: tour.Rounds[0], Puzzles: []TournamentPuzzle{{Title: "Animalia"}}}},
+		Standings:  []Standing{{Place: 1, Player: "ann", Points: 340, Time: 4 * time.Minute}},
+	}
+	var buf bytes.Buffer
+	err := WriteTournamentHTML(&buf, page)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	for _, s := range []string{`action="register"`, "Opens Wed 1 Apr 10:00, for 15 minutes", "<td>ann</td><td>340</td><td>0</td><td>4:00</td>"} {
+		if !strings.Contains(out, s) {
+			t.Errorf("tournament page should contain %q", s)
+		}
+	}
+}
@@ -0,0 +1,269 @@
+package xwd
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// DefaultWordScore is the score given to words listed without one.
+const DefaultWordScore = 50
+
+// WordList is a list of candidate answers, each with a score indicating how
+// good a crossword entry it makes (higher is better). Words are stored
+// normalised, as by NormaliseAnswer.
+type WordList struct {
+	scores map[string]int
+}
+
+// NewWordList returns an empty word list.
+func NewWordList() *WordList {
+	return &WordList{scores: make(map[string]int)}
+}
+
+// ReadWordList reads a word list in the common "WORD;SCORE" format, one word
+// per line. A tab may be used instead of the semicolon, the score is optional,
+// and blank lines and lines starting with "#" are ignored. Words are
+// normalised, and where a word appears more than once the highest score is
+// kept. A word with letters that can't be written in A-Z is an error, rather
+// than being silently mangled.
+func ReadWordList(r io.Reader) (*WordList, error) {
+	wl := NewWordList()
+	s := bufio.NewScanner(r)
+	line := 0
+	for s.Scan() {
+		line++
+		text := strings.TrimSpace(s.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		word, score := text, DefaultWordScore
+		if i := strings.LastIndexAny(text, ";\t"); i >= 0 {
+			n, err := strconv.Atoi(strings.TrimSpace(text[i+1:]))
+			if err != nil {
+				return nil, fmt.Errorf("line %d: bad score: %v", line, err)
+			}
+			word, score = text[:i], n
+		}
+		if _, ok := normaliseAnswer(word); !ok {
+			return nil, fmt.Errorf("line %d: %q has letters outside A-Z", line, strings.TrimSpace(word))
+		}
+		wl.Add(word, score)
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	return wl, nil
+}
+
+// Add adds a word to the list. If the word is already in the list, the higher
+// of the two scores is kept. Words that are empty once normalised, or that
+// have letters that can't be written in A-Z, are ignored.
+func (wl *WordList) Add(word string, score int) {
+	word, ok := normaliseAnswer(word)
+	if word == "" || !ok {
+		return
+	}
+	if old, ok := wl.scores[word]; ok && old >= score {
+		return
+	}
+	wl.scores[word] = score
+}
+
+// Merge adds every word in other to the list, as Add.
+func (wl *WordList) Merge(other *WordList) {
+	for word, score := range other.scores {
+		if old, ok := wl.scores[word]; !ok || score > old {
+			wl.scores[word] = score
+		}
+	}
+}
+
+// Remove removes a word from the list.
+func (wl *WordList) Remove(word string) {
+	delete(wl.scores, NormaliseAnswer(word))
+}
+
+// Blocked returns the words in the list that are also in the blocklist,
+// sorted.
+func (wl *WordList) Blocked(blocklist *WordList) []string {
+	blocked := make([]string, 0)
+	for word := range wl.scores {
+		if _, ok := blocklist.scores[word]; ok {
+			blocked = append(blocked, word)
+		}
+	}
+	sort.Strings(blocked)
+	return blocked
+}
+
+// Score returns the score of a word, and whether it's in the list.
+func (wl *WordList) Score(word string) (int, bool) {
+	score, ok := wl.scores[NormaliseAnswer(word)]
+	return score, ok
+}
+
+// Len returns the number of words in the list.
+func (wl *WordList) Len() int {
+	return len(wl.scores)
+}
+
+// Words returns every word in the list, sorted.
+func (wl *WordList) Words() []string {
+	words := make([]string, 0, len(wl.scores))
+	for word := range wl.scores {
+		words = append(words, word)
+	}
+	sort.Strings(words)
+	return words
+}
+
+// WriteTo writes the word list to w in "WORD;SCORE" format, sorted by word.
+func (wl *WordList) WriteTo(w io.Writer) (int64, error) {
+	bw := bufio.NewWriter(w)
+	var n int64
+	for _, word := range wl.Words() {
+		m, err := fmt.Fprintf(bw, "%s;%d\n", word, wl.scores[word])
+		n += int64(m)
+		if err != nil {
+			return n, err
+		}
+	}
+	return n, bw.Flush()
+}
+
+// NormaliseAnswer returns an answer in the form used in grids and word lists:
+// upper case, with accented letters folded to the ones they're based on (so
+// "Éclair" becomes "ECLAIR"), and with spaces, punctuation and anything else
+// that isn't one of the letters A-Z removed.
+func NormaliseAnswer(s string) string {
+	answer, _ := normaliseAnswer(s)
+	return answer
+}
+
+// normaliseAnswer is NormaliseAnswer, also reporting whether every letter in
+// s was kept: it's false if s has letters, such as Greek or Cyrillic ones,
+// that can't be folded to A-Z.
+func normaliseAnswer(s string) (string, bool) {
+	b := make([]byte, 0, len(s))
+	ok := true
+	for _, r := range strings.ToUpper(s) {
+		switch {
+		case r >= 'A' && r <= 'Z':
+			b = append(b, byte(r))
+		case r < utf8.RuneSelf || unicode.Is(unicode.Mn, r):
+			// Punctuation, spacing, or a combining accent on the last letter.
+		case foldLetter(r) != "":
+			b = append(b, foldLetter(r)...)
+		case unicode.IsLetter(r):
+			ok = false
+		}
+	}
+	return string(b), ok
+}
+
+// letterFolds lists the (upper case) accented letters and ligatures of the
+// Latin alphabets, with the letters A-Z they're folded to.
+var letterFolds = []struct{ folded, letters string }{
+	{"A", "ÀÁÂÃÄÅĀĂĄǍ"},
+	{"C", "ÇĆĈĊČ"},
+	{"D", "ÐĎĐ"},
+	{"E", "ÈÉÊËĒĔĖĘĚ"},
+	{"G", "ĜĞĠĢ"},
+	{"H", "ĤĦ"},
+	{"I", "ÌÍÎÏĨĪĬĮİ"},
+	{"J", "Ĵ"},
+	{"K", "Ķ"},
+	{"L", "ĹĻĽĿŁ"},
+	{"N", "ÑŃŅŇ"},
+	{"O", "ÒÓÔÕÖØŌŎŐ"},
+	{"R", "ŔŖŘ"},
+	{"S", "ŚŜŞŠȘ"},
+	{"T", "ŢŤŦȚ"},
+	{"U", "ÙÚÛÜŨŪŬŮŰŲ"},
+	{"W", "Ŵ"},
+	{"Y", "ÝŶŸ"},
+	{"Z", "ŹŻŽ"},
+	{"AE", "Æ"},
+	{"IJ", "Ĳ"},
+	{"OE", "Œ"},
+	{"SS", "ß"},
+	{"TH", "Þ"},
+}
+
+// foldLetter returns the letters A-Z that an accented letter is folded to, or
+// "" if it isn't one.
+func foldLetter(r rune) string {
+	for _, f := range letterFolds {
+		if strings.ContainsRune(f.letters, r) {
+			return f.folded
+		}
+	}
+	return ""
+}
+
+// WordListBuilder builds a word list from the answers in an archive of
+// puzzles, scoring each word by how often it's been used, with recent uses
+// counting for more than old ones.
+type WordListBuilder struct {
+	Now      time.Time     // The time from which the age of a use is measured
+	HalfLife time.Duration // The age at which a use counts half (0 for no decay)
+	weights  map[string]float64
+}
+
+// NewWordListBuilder returns a WordListBuilder measuring ages from now.
+func NewWordListBuilder(now time.Time, halfLife time.Duration) *WordListBuilder {
+	return &WordListBuilder{Now: now, HalfLife: halfLife, weights: make(map[string]float64)}
+}
+
+// Add records a use of an answer at the given time. Answers with letters that
+// can't be written in A-Z are left out, as by WordList.Add.
+func (b *WordListBuilder) Add(answer string, when time.Time) {
+	answer, ok := normaliseAnswer(answer)
+	if answer == "" || !ok {
+		return
+	}
+	w := 1.0
+	if age := b.Now.Sub(when); b.HalfLife > 0 && age > 0 {
+		w = math.Pow(0.5, float64(age)/float64(b.HalfLife))
+	}
+	b.weights[answer] += w
+}
+
+// AddPuzzle records a use of every answer in a puzzle at the given time.
+func (b *WordListBuilder) AddPuzzle(p *Puzzle, when time.Time) {
+	for _, e := range p.Entries() {
+		b.Add(e.Answer, when)
+	}
+}
+
+// WordList returns the word list built so far. Scores run from 1 to 100, on a
+// logarithmic scale so that a handful of very common answers don't squash
+// everything else to the bottom.
+func (b *WordListBuilder) WordList() *WordList {
+	max := 0.0
+	for _, w := range b.weights {
+		if w > max {
+			max = w
+		}
+	}
+	wl := NewWordList()
+	for word, w := range b.weights {
+		score := 1
+		if max > 0 {
+			score = int(math.Round(100 * math.Log1p(w) / math.Log1p(max)))
+		}
+		if score < 1 {
+			score = 1
+		}
+		wl.scores[word] = score
+	}
+	return wl
+}
@@ -0,0 +1,110 @@
+package xwd
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReadWordList(t *testing.T) {
+	wl, err := ReadWordList(strings.NewReader("# comment\ncat;60\nore\n\nWED ; 25\nt-shirt\t40\nCat;20\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	examples := map[string]int{"CAT": 60, "ORE": DefaultWordScore, "WED": 25, "TSHIRT": 40}
+	for word, score := range examples {
+		if got, ok := wl.Score(word); !ok || got != score {
+			t.Errorf("%s: expectation failure (expected: %v, got: %v)", word, score, got)
+		}
+	}
+	if wl.Len() != len(examples) {
+		t.Errorf("expectation failure (expected: %v words, got: %v)", len(examples), wl.Len())
+	}
+}
+
+func TestReadWordListForeignLetters(t *testing.T) {
+	_, err := ReadWordList(strings.NewReader("cat;60\nπίτα;40\n"))
+	if err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("expected an error on line 2, got: %v", err)
+	}
+}
+
+func TestNormaliseAnswer(t *testing.T) {
+	examples := map[string]string{
+		"t-shirt":       "TSHIRT",
+		"ÉCLAIR":        "ECLAIR",
+		"Piñata":        "PINATA",
+		"E\u0301clair":  "ECLAIR",
+		"Smørrebrød":    "SMORREBROD",
+		"Straße":        "STRASSE",
+		"Œuvre":         "OEUVRE",
+		"CAFÉ AU LAIT!": "CAFEAULAIT",
+	}
+	for in, expected := range examples {
+		if got := NormaliseAnswer(in); got != expected {
+			t.Errorf("%q: expectation failure (expected: %q, got: %q)", in, expected, got)
+		}
+	}
+
+	wl := NewWordList()
+	wl.Add("πίτα", 50)
+	if wl.Len() != 0 {
+		t.Errorf("expected a word with letters outside A-Z to be ignored, got: %v", wl.Words())
+	}
+}
+
+func TestWordListMerge(t *testing.T) {
+	a, b := NewWordList(), NewWordList()
+	a.Add("CAT", 60)
+	a.Add("DOG", 20)
+	b.Add("dog", 40)
+	b.Add("cat", 30)
+	b.Add("EMU", 10)
+	a.Merge(b)
+
+	var buf bytes.Buffer
+	_, err := a.WriteTo(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "CAT;60\nDOG;40\nEMU;10\n"
+	if buf.String() != expected {
+		t.Errorf("expectation failure (expected: %q, got: %q)", expected, buf.String())
+	}
+}
+
+func TestWordListBlocked(t *testing.T) {
+	wl, block := NewWordList(), NewWordList()
+	for _, w := range []string{"CAT", "DOG", "EMU"} {
+		wl.Add(w, 50)
+	}
+	block.Add("emu", 0)
+	block.Add("yak", 0)
+	if got := wl.Blocked(block); len(got) != 1 || got[0] != "EMU" {
+		t.Errorf("expectation failure (expected: %v, got: %v)", []string{"EMU"}, got)
+	}
+}
+
+func TestWordListBuilder(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	year := 365 * 24 * time.Hour
+	b := NewWordListBuilder(now, 5*year)
+	for i := 0; i < 4; i++ {
+		b.Add("ERA", now.Add(-10*year))
+	}
+	b.Add("OREO", now)
+	b.Add("OREO", now.Add(-year))
+	b.Add("ETUI", now.Add(-20*year))
+	wl := b.WordList()
+
+	oreo, _ := wl.Score("OREO")
+	era, _ := wl.Score("ERA")
+	etui, _ := wl.Score("ETUI")
+	if oreo != 100 {
+		t.Errorf("expectation failure (expected: %v, got: %v)", 100, oreo)
+	}
+	if !(oreo > era && era > etui && etui >= 1) {
+		t.Errorf("expected recent and frequent answers to score higher (OREO %v, ERA %v, ETUI %v)", oreo, era, etui)
+	}
+}
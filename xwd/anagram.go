@@ -0,0 +1,99 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/nickstenning/xwd"
+)
+
+func anagram(args []string) {
+	fs := flag.NewFlagSet("anagram", flag.ExitOnError)
+	words := fs.String("words", "", "word list, in WORD;SCORE format")
+	maxWords := fs.Int("max", 3, "most words in an anagram")
+	enum := fs.String("enum", "", "enumeration the anagrams must fit, e.g. \"4,5\"")
+	limit := fs.Int("limit", 50, "most results to show")
+	bank := fs.Bool("bank", false, "find words spelt using only the letters given (each any number of times)")
+	hidden := fs.Bool("hidden", false, "check whether the answer is hidden in the clue surface, or list the words hidden there")
+	minLength := fs.Int("min", 4, "bank, hidden: shortest word to list")
+	fs.Usage = func() {
+		name := path.Base(os.Args[0])
+		fmt.Fprintf(
+			os.Stderr,
+			"Usage: %s anagram [options] -words <wordlist> <fodder>\n"+
+				"       %s anagram [options] -words <wordlist> -bank <letters>\n"+
+				"       %s anagram -hidden <surface> <answer>\n"+
+				"       %s anagram [options] -words <wordlist> -hidden <surface>\n\n",
+			name, name, name, name,
+		)
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if *hidden && fs.NArg() == 2 {
+		h, ok := xwd.Hidden(fs.Arg(0), fs.Arg(1))
+		if !ok {
+			fmt.Printf("%s isn't hidden in %q\n", xwd.NormaliseAnswer(fs.Arg(1)), fs.Arg(0))
+			os.Exit(1)
+		}
+		printHidden(fs.Arg(0), h)
+		return
+	}
+
+	if fs.NArg() != 1 || *words == "" {
+		fs.Usage()
+		os.Exit(2)
+	}
+	wl, err := loadWordList(*words)
+	if err != nil {
+		logger.Fatal(err)
+	}
+	a := xwd.NewAnagrammer(wl)
+	input := fs.Arg(0)
+
+	switch {
+	case *hidden:
+		found := a.HiddenWords(input, *minLength)
+		if len(found) > *limit {
+			found = found[:*limit]
+		}
+		for _, h := range found {
+			printHidden(input, h)
+		}
+	case *bank:
+		found := a.LetterBank(input, *minLength)
+		if len(found) > *limit {
+			found = found[:*limit]
+		}
+		for _, word := range found {
+			fmt.Println(word)
+		}
+	default:
+		opts := xwd.AnagramOptions{MaxWords: *maxWords, Limit: *limit}
+		if *enum != "" {
+			for _, part := range strings.FieldsFunc(*enum, func(r rune) bool { return r == ',' || r == '-' || r == ' ' }) {
+				n, err := strconv.Atoi(part)
+				if err != nil {
+					logger.Fatalf("bad enumeration %q", *enum)
+				}
+				opts.Lengths = append(opts.Lengths, n)
+			}
+		}
+		for _, words := range a.MultiWord(input, opts) {
+			fmt.Println(strings.Join(words, " "))
+		}
+	}
+}
+
+func printHidden(surface string, h xwd.HiddenWord) {
+	reversed := ""
+	if h.Reversed {
+		reversed = " (reversed)"
+	}
+	fmt.Printf("%s: %s%s\n", h.Word, h.Mark(surface), reversed)
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/nickstenning/xwd"
+)
+
+func batch(args []string) {
+	fs := flag.NewFlagSet("batch", flag.ExitOnError)
+	op := fs.String("op", "validate", "operation to apply: validate, convert, stats or fingerprint")
+	workers := fs.Int("j", 0, "number of puzzles to process in parallel (default one per CPU)")
+	format := fs.String("format", "csv", fmt.Sprintf("convert: output format (one of %v)", formatNames()))
+	outdir := fs.String("o", "", "convert: directory to write converted puzzles to")
+	verbose := fs.Bool("v", false, "report every file, not only failures")
+	fs.Usage = func() {
+		fmt.Fprintf(
+			os.Stderr,
+			"Usage: %s batch [options] <puzzledir>\n\n",
+			path.Base(os.Args[0]),
+		)
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	root := fs.Arg(0)
+
+	var fn xwd.BatchFunc
+	quiet := !*verbose
+	switch *op {
+	case "validate":
+		fn = func(ctx context.Context, filename string, p *xwd.Puzzle) (string, error) {
+			return "ok", nil
+		}
+	case "convert":
+		write, ok := writers[*format]
+		if !ok {
+			logger.Fatalf("unknown format %q (expected one of %v)", *format, formatNames())
+		}
+		if *outdir == "" {
+			logger.Fatal("convert requires an output directory (-o)")
+		}
+		fn = func(ctx context.Context, filename string, p *xwd.Puzzle) (string, error) {
+			return convertTo(root, *outdir, filename, *format, p, write)
+		}
+	case "stats":
+		fn = func(ctx context.Context, filename string, p *xwd.Puzzle) (string, error) {
+			return puzzleStats(p), nil
+		}
+		quiet = false
+	case "fingerprint":
+		fn = func(ctx context.Context, filename string, p *xwd.Puzzle) (string, error) {
+			return p.Fingerprint(), nil
+		}
+		quiet = false
+	default:
+		logger.Fatalf("unknown operation %q", *op)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	report, err := xwd.Batch(ctx, root, *workers, fn)
+	for _, r := range report.Results {
+		if r.Err != nil {
+			fmt.Printf("%s: error: %v\n", r.Path, r.Err)
+		} else if !quiet {
+			fmt.Printf("%s: %s\n", r.Path, r.Output)
+		}
+	}
+	if *op == "fingerprint" {
+		printDuplicates(report)
+	}
+
+	fmt.Fprintf(os.Stderr, "%d files in %v: %d ok, %d failed\n",
+		len(report.Results), report.Duration.Round(1e6), len(report.Results)-report.Failed, report.Failed)
+	if err != nil {
+		logger.Fatal(err)
+	}
+	if report.Failed > 0 {
+		os.Exit(1)
+	}
+}
+
+// convertTo writes the puzzle loaded from filename (somewhere beneath root) to
+// the corresponding path beneath outdir, with the extension changed to match
+// the format.
+func convertTo(root, outdir, filename, format string, p *xwd.Puzzle, write func(io.Writer, *xwd.Puzzle) error) (string, error) {
+	rel, err := filepath.Rel(root, filename)
+	if err != nil {
+		return "", err
+	}
+	out := filepath.Join(outdir, strings.TrimSuffix(rel, ".puz")+"."+format)
+	err = os.MkdirAll(filepath.Dir(out), 0755)
+	if err != nil {
+		return "", err
+	}
+	f, err := os.Create(out)
+	if err != nil {
+		return "", err
+	}
+	err = write(f, p)
+	if err != nil {
+		f.Close()
+		return "", err
+	}
+	return out, f.Close()
+}
+
+func puzzleStats(p *xwd.Puzzle) string {
+	blacks := 0
+	for _, row := range p.Solution() {
+		for _, cell := range row {
+			if cell.Black {
+				blacks++
+			}
+		}
+	}
+	entries := p.Entries()
+	letters := 0
+	for _, e := range entries {
+		letters += len(e.Cells)
+	}
+	avg := 0.0
+	if len(entries) > 0 {
+		avg = float64(letters) / float64(len(entries))
+	}
+	return fmt.Sprintf("%dx%d, %d words, %d blocks, average word length %.2f",
+		p.Cols, p.Rows, len(entries), blacks, avg)
+}
+
+func printDuplicates(report *xwd.BatchReport) {
+	paths := make(map[string][]string)
+	for _, r := range report.Results {
+		if r.Err == nil {
+			paths[r.Output] = append(paths[r.Output], r.Path)
+		}
+	}
+	dups := make([]string, 0)
+	for fp, ps := range paths {
+		if len(ps) > 1 {
+			dups = append(dups, fp)
+		}
+	}
+	sort.Strings(dups)
+	for _, fp := range dups {
+		fmt.Printf("duplicate %s: %s\n", fp, strings.Join(paths[fp], ", "))
+	}
+}
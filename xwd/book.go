@@ -0,0 +1,83 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/nickstenning/xwd"
+)
+
+func book(args []string) {
+	fs := flag.NewFlagSet("book", flag.ExitOnError)
+	title := fs.String("title", "Crosswords", "title of the book")
+	author := fs.String("author", "", "author or editor of the book")
+	output := fs.String("o", "puzzles.epub", "write the EPUB to this file")
+	fs.Usage = func() {
+		fmt.Fprintf(
+			os.Stderr,
+			"Usage: %s book [options] <puzzlefile|puzzledir>...\n\n",
+			path.Base(os.Args[0]),
+		)
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() == 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	b := &xwd.Book{Title: *title, Author: *author}
+	for _, arg := range fs.Args() {
+		files, err := puzzleFiles(arg)
+		if err != nil {
+			logger.Fatal(err)
+		}
+		for _, filename := range files {
+			puz, err := loadPuzzle(filename)
+			if err != nil {
+				logger.Fatalf("%s: %v", filename, err)
+			}
+			b.Puzzles = append(b.Puzzles, puz)
+		}
+	}
+
+	f, err := os.Create(*output)
+	if err != nil {
+		logger.Fatal(err)
+	}
+	defer f.Close()
+
+	err = b.WriteEPUB(f)
+	if err != nil {
+		logger.Fatal(err)
+	}
+}
+
+// puzzleFiles returns the name itself if it names a file, or all the .puz
+// files beneath it, in lexical order, if it names a directory.
+func puzzleFiles(name string) ([]string, error) {
+	info, err := os.Stat(name)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return []string{name}, nil
+	}
+	files := make([]string, 0)
+	err = filepath.Walk(name, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() && strings.HasSuffix(p, ".puz") {
+			files = append(files, p)
+		}
+		return nil
+	})
+	return files, err
+}
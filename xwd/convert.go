@@ -0,0 +1,82 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+
+	"github.com/nickstenning/xwd"
+)
+
+// htmlOptions are the options passed to xwd.WriteHTML by the "html" writer.
+var htmlOptions xwd.HTMLOptions
+
+// writers maps the formats accepted by "xwd convert" to the library functions
+// that produce them.
+var writers = map[string]func(io.Writer, *xwd.Puzzle) error{
+	"csv": xwd.WriteCSV,
+	"tex": xwd.WriteTeX,
+	"html": func(w io.Writer, p *xwd.Puzzle) error {
+		return xwd.WriteHTML(w, p, htmlOptions)
+	},
+}
+
+func formatNames() []string {
+	names := make([]string, 0, len(writers))
+	for name := range writers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func convert(args []string) {
+	fs := flag.NewFlagSet("convert", flag.ExitOnError)
+	format := fs.String("format", "csv", fmt.Sprintf("output format (one of %v)", formatNames()))
+	output := fs.String("o", "", "write output to this file rather than stdout")
+	fs.BoolVar(&htmlOptions.Check, "check", false, "html: embed the obfuscated solution to allow answer checking")
+	fs.BoolVar(&recoverClues, "recover", false, "convert puzzles with the wrong number of clues as well as possible")
+	fs.Usage = func() {
+		fmt.Fprintf(
+			os.Stderr,
+			"Usage: %s convert [options] <puzzlefile>\n\n",
+			path.Base(os.Args[0]),
+		)
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	write, ok := writers[*format]
+	if !ok {
+		logger.Fatalf("unknown format %q (expected one of %v)", *format, formatNames())
+	}
+
+	puz, err := loadPuzzle(fs.Arg(0))
+	if err != nil {
+		logger.Fatal(err)
+	}
+
+	var w io.Writer = os.Stdout
+	if *output != "" {
+		f, err := os.Create(*output)
+		if err != nil {
+			logger.Fatal(err)
+		}
+		defer f.Close()
+		w = f
+	}
+
+	err = write(w, puz)
+	if err != nil {
+		logger.Fatal(err)
+	}
+}
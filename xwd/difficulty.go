@@ -0,0 +1,187 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/nickstenning/xwd"
+)
+
+func difficulty(args []string) {
+	fs := flag.NewFlagSet("difficulty", flag.ExitOnError)
+	words := fs.String("words", "", "word list, in WORD;SCORE format, for judging obscurity")
+	var corpus sources
+	fs.Var(&corpus, "corpus", "CSV file, puzzle file or directory of puzzles for judging freshness (may be repeated)")
+	model := fs.String("model", "", "difficulty model to use, as written by -calibrate")
+	calibrate := fs.String("calibrate", "", "fit a model to the puzzles listed in this CSV file (with file and day columns)")
+	output := fs.String("o", "", "calibrate: write the model to this file rather than stdout")
+	fs.Usage = func() {
+		fmt.Fprintf(
+			os.Stderr,
+			"Usage: %s difficulty [options] <puzzlefile or dir>...\n"+
+				"       %s difficulty [options] -calibrate <labels.csv>\n\n",
+			path.Base(os.Args[0]),
+			path.Base(os.Args[0]),
+		)
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() == 0 && *calibrate == "" {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	d := &xwd.DifficultyEstimator{}
+	var err error
+	if *words != "" {
+		d.Words, err = loadWordList(*words)
+		if err != nil {
+			logger.Fatal(err)
+		}
+	}
+	if len(corpus) > 0 {
+		d.Corpus, err = loadClueDB(corpus, nil)
+		if err != nil {
+			logger.Fatal(err)
+		}
+	}
+
+	if *calibrate != "" {
+		m, err := calibrateDifficulty(d, *calibrate)
+		if err != nil {
+			logger.Fatal(err)
+		}
+		var w io.Writer = os.Stdout
+		if *output != "" {
+			f, err := os.Create(*output)
+			if err != nil {
+				logger.Fatal(err)
+			}
+			defer f.Close()
+			w = f
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(m)
+		if err != nil {
+			logger.Fatal(err)
+		}
+		return
+	}
+
+	if *model != "" {
+		data, err := ioutil.ReadFile(*model)
+		if err != nil {
+			logger.Fatal(err)
+		}
+		d.Model = &xwd.DifficultyModel{}
+		err = json.Unmarshal(data, d.Model)
+		if err != nil {
+			logger.Fatalf("%s: %v", *model, err)
+		}
+	}
+
+	for _, name := range fs.Args() {
+		files, err := puzzleFiles(name)
+		if err != nil {
+			logger.Fatal(err)
+		}
+		for _, file := range files {
+			puz, err := loadPuzzle(file)
+			if err != nil {
+				logger.Printf("%s: %v", file, err)
+				continue
+			}
+			f := d.Features(puz)
+			score := d.Estimate(puz)
+			fmt.Printf("%s: %.2f (%s): obscurity %.2f, freshness %.2f, clue length %.1f, openness %.1f, unchecked %.2f\n",
+				file, score, xwd.Day(score), f.Obscurity, f.Freshness, f.ClueLength, f.Openness, f.Unchecked)
+		}
+	}
+}
+
+// calibrateDifficulty fits a difficulty model to the puzzles listed in a CSV
+// file, with "file" and "day" columns. Days can be given by name or number
+// (1 for Monday to 6 for Saturday), and files relative to the CSV file.
+func calibrateDifficulty(d *xwd.DifficultyEstimator, labels string) (*xwd.DifficultyModel, error) {
+	f, err := os.Open(labels)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	records, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", labels, err)
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("%s: no header row", labels)
+	}
+	fileCol, dayCol := -1, -1
+	for i, name := range records[0] {
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case "file":
+			fileCol = i
+		case "day":
+			dayCol = i
+		}
+	}
+	if fileCol < 0 || dayCol < 0 {
+		return nil, fmt.Errorf("%s: header must name file and day columns", labels)
+	}
+
+	samples := make([]xwd.DifficultySample, 0, len(records)-1)
+	for _, record := range records[1:] {
+		day, err := parseDay(record[dayCol])
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", labels, err)
+		}
+		file := record[fileCol]
+		if !filepath.IsAbs(file) {
+			file = filepath.Join(filepath.Dir(labels), file)
+		}
+		puz, err := loadPuzzle(file)
+		if err != nil {
+			logger.Printf("%s: %v", file, err)
+			continue
+		}
+		samples = append(samples, xwd.DifficultySample{Features: d.Features(puz), Score: float64(day)})
+	}
+
+	m, err := xwd.CalibrateDifficulty(samples)
+	if err != nil {
+		return nil, err
+	}
+	sq := 0.0
+	for _, s := range samples {
+		e := m.Score(s.Features) - s.Score
+		sq += e * e
+	}
+	logger.Printf("calibrated from %d puzzles, RMS error %.2f days", len(samples), math.Sqrt(sq/float64(len(samples))))
+	return m, nil
+}
+
+// parseDay parses a day name (or its first three letters) or number.
+func parseDay(s string) (int, error) {
+	s = strings.TrimSpace(s)
+	if n, err := strconv.Atoi(s); err == nil && n >= 1 && n <= len(xwd.Days) {
+		return n, nil
+	}
+	for i, day := range xwd.Days {
+		if len(s) >= 3 && strings.HasPrefix(strings.ToLower(day), strings.ToLower(s)) {
+			return i + 1, nil
+		}
+	}
+	return 0, fmt.Errorf("bad day %q", s)
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/nickstenning/xwd"
+)
+
+// parseFill splits an answer given to "xwd fill" into the text for each cell.
+// Each letter fills one cell, except that several letters in braces fill one
+// cell as a rebus, e.g. "T{HEART}N". A "." leaves its cell as it is, which is
+// returned as "."; and a "_" clears its cell, returned as "".
+func parseFill(answer string) ([]string, error) {
+	runes := []rune(answer)
+	cells := make([]string, 0, len(runes))
+	for k := 0; k < len(runes); k++ {
+		switch c := runes[k]; c {
+		case '{':
+			end := k + 1
+			for end < len(runes) && runes[end] != '}' {
+				end++
+			}
+			if end == len(runes) || end == k+1 {
+				return nil, fmt.Errorf("bad rebus in %q", answer)
+			}
+			cells = append(cells, strings.ToUpper(string(runes[k+1:end])))
+			k = end
+		case '_':
+			cells = append(cells, "")
+		default:
+			cells = append(cells, strings.ToUpper(string(c)))
+		}
+	}
+	return cells, nil
+}
+
+func fill(args []string) {
+	fs := flag.NewFlagSet("fill", flag.ExitOnError)
+	pencil := fs.Bool("pencil", false, "pencil the letters in, as guesses")
+	check := fs.Bool("check", false, "check the whole grid, marking the wrong letters")
+	autocheck := fs.Bool("autocheck", false, "check each letter as it's entered, marking the wrong ones")
+	clear := fs.Bool("clear", false, "clear any saved progress first")
+	output := fs.String("o", "", "write the puzzle to this file rather than back to the original")
+	fs.Usage = func() {
+		fmt.Fprintf(
+			os.Stderr,
+			"Usage: %s fill [options] <puzzlefile> [<entry>=<answer>...]\n\n"+
+				"Enters answers in the progress saved in a puzzle file, e.g. \"12a=CAT\".\n"+
+				"Use braces for a rebus, e.g. \"3d=T{HEART}N\", \".\" to leave a square as\n"+
+				"it is and \"_\" to clear it.\n\n",
+			path.Base(os.Args[0]),
+		)
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() < 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	filename := fs.Arg(0)
+
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		logger.Fatal(err)
+	}
+	a := &xwd.AcrossLite{}
+	if !a.Sniff(data) {
+		logger.Fatalf("%s: not an AcrossLite (.puz) file", filename)
+	}
+	err = a.Parse(data)
+	if err != nil {
+		logger.Fatalf("%s: %v", filename, err)
+	}
+	puz := &xwd.Puzzle{}
+	err = a.Load(puz)
+	if err != nil {
+		logger.Fatalf("%s: %v", filename, err)
+	}
+
+	s := a.Session()
+	if *clear {
+		s = xwd.NewSession(puz)
+	}
+	if *autocheck {
+		s.SetAutocheck(true)
+	}
+	filled := make([][2]int, 0) // The cells filled, each once
+	seen := make(map[[2]int]bool)
+	entries := make(map[string]xwd.Entry)
+	for _, e := range puz.Entries() {
+		entries[fmt.Sprintf("%d%s", e.Num, e.Direction)] = e
+	}
+	for _, arg := range fs.Args()[1:] {
+		dir, num, answer, err := parseClueEdit(arg)
+		if err != nil {
+			logger.Fatalf("bad entry %q (expected e.g. \"12a=ANSWER\" or \"3d=T{HEART}N\")", arg)
+		}
+		e, ok := entries[fmt.Sprintf("%d%s", num, dir)]
+		if !ok {
+			logger.Fatalf("no %s entry numbered %d", dir, num+1)
+		}
+		cells, err := parseFill(strings.TrimSpace(answer))
+		if err != nil {
+			logger.Fatal(err)
+		}
+		if len(cells) != len(e.Cells) {
+			logger.Fatalf("%d %s has %d squares, but %q fills %d", num+1, dir, len(e.Cells), answer, len(cells))
+		}
+		for k, text := range cells {
+			if text == "." {
+				continue
+			}
+			i, j := e.Cells[k][0], e.Cells[k][1]
+			s.Set(i, j, text, *pencil)
+			if !seen[e.Cells[k]] {
+				seen[e.Cells[k]] = true
+				filled = append(filled, e.Cells[k])
+			}
+		}
+	}
+
+	wrong := make([][2]int, 0)
+	if *check {
+		wrong = s.Check()
+	} else if *autocheck {
+		for _, c := range filled {
+			if s.Flags(c[0], c[1])&xwd.FlagIncorrect != 0 {
+				wrong = append(wrong, c)
+			}
+		}
+	}
+
+	err = a.StoreSession(s)
+	if err != nil {
+		logger.Fatal(err)
+	}
+	if *output == "" {
+		*output = filename
+	}
+	err = writeFileAtomic(*output, a.Data)
+	if err != nil {
+		logger.Fatal(err)
+	}
+
+	printGrid(puz, sessionText(s))
+	for _, c := range wrong {
+		fmt.Printf("row %d, column %d: %s is wrong\n", c[0]+1, c[1]+1, s.Letter(c[0], c[1]))
+	}
+	if len(wrong) > 0 {
+		os.Exit(1)
+	}
+}
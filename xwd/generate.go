@@ -0,0 +1,102 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nickstenning/xwd"
+)
+
+// parseTheme parses a theme entry of the form "row,col,direction,ANSWER",
+// with one-indexed row and column, e.g. "8,2,across,CROSSWORDS".
+func parseTheme(s string) (xwd.ThemeEntry, error) {
+	var t xwd.ThemeEntry
+	parts := strings.SplitN(s, ",", 4)
+	if len(parts) != 4 {
+		return t, fmt.Errorf("bad theme entry %q (expected e.g. \"8,2,across,CROSSWORDS\")", s)
+	}
+	row, err := strconv.Atoi(strings.TrimSpace(parts[0]))
+	if err != nil {
+		return t, fmt.Errorf("bad theme entry %q: %v", s, err)
+	}
+	col, err := strconv.Atoi(strings.TrimSpace(parts[1]))
+	if err != nil {
+		return t, fmt.Errorf("bad theme entry %q: %v", s, err)
+	}
+	t.Row, t.Col, t.Answer = row-1, col-1, parts[3]
+	switch strings.ToLower(strings.TrimSpace(parts[2])) {
+	case "a", "across":
+		t.Direction = xwd.Across
+	case "d", "down":
+		t.Direction = xwd.Down
+	default:
+		return t, fmt.Errorf("bad theme entry %q: unknown direction %q", s, parts[2])
+	}
+	return t, nil
+}
+
+func generate(args []string) {
+	fs := flag.NewFlagSet("generate", flag.ExitOnError)
+	var opts xwd.PatternOptions
+	fs.IntVar(&opts.Rows, "rows", 15, "number of rows")
+	fs.IntVar(&opts.Cols, "cols", 15, "number of columns")
+	fs.IntVar(&opts.Blocks, "blocks", 36, "number of black squares wanted")
+	fs.IntVar(&opts.Words, "words", 0, "number of words wanted (0 for any)")
+	fs.IntVar(&opts.MinLength, "min", 3, "shortest word allowed")
+	fs.IntVar(&opts.MaxAttempts, "attempts", 1000, "most patterns to try for the wanted number of words")
+	fs.Int64Var(&opts.Seed, "seed", time.Now().UnixNano(), "random seed")
+	var theme sources
+	fs.Var(&theme, "theme", "theme entry as \"row,col,direction,ANSWER\", e.g. \"8,2,across,CROSSWORDS\" (may be repeated)")
+	plain := fs.Bool("plain", false, "print the pattern as plain text, one row per line")
+	fs.Usage = func() {
+		fmt.Fprintf(
+			os.Stderr,
+			"Usage: %s generate [options]\n\n",
+			path.Base(os.Args[0]),
+		)
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	for _, s := range theme {
+		t, err := parseTheme(s)
+		if err != nil {
+			logger.Fatal(err)
+		}
+		opts.Theme = append(opts.Theme, t)
+	}
+
+	grid, err := xwd.GeneratePattern(opts)
+	if err != nil {
+		logger.Fatal(err)
+	}
+	if *plain {
+		fmt.Println(strings.Join(grid, "\n"))
+		return
+	}
+
+	puz := &xwd.Puzzle{Rows: opts.Rows, Cols: opts.Cols}
+	err = puz.SetSolution(grid)
+	if err != nil {
+		logger.Fatal(err)
+	}
+	printGrid(puz, func(cell *xwd.Cell) string {
+		if cell.Solution != xwd.P_WHITE {
+			return fmt.Sprintf(" %s ", cell.Solution)
+		}
+		return puzzleText(false)(cell)
+	})
+	blocks := strings.Count(strings.Join(grid, ""), xwd.P_BLACK)
+	fmt.Printf("\n%dx%d, %d words, %d blocks (seed %d)\n", opts.Cols, opts.Rows, len(puz.Entries()), blocks, opts.Seed)
+}
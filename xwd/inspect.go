@@ -0,0 +1,92 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+
+	"github.com/nickstenning/xwd"
+)
+
+func inspect(args []string) {
+	fs := flag.NewFlagSet("inspect", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(
+			os.Stderr,
+			"Usage: %s inspect <puzzlefile>\n",
+			path.Base(os.Args[0]),
+		)
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	data, err := ioutil.ReadFile(fs.Arg(0))
+	if err != nil {
+		logger.Fatal(err)
+	}
+
+	a := &xwd.AcrossLite{}
+	if !a.Sniff(data) {
+		logger.Fatal("not an AcrossLite (.puz) file")
+	}
+	// A broken file is exactly the kind of thing we want to inspect, so carry
+	// on regardless, showing as much of it as could be read.
+	parseErr := a.Parse(data)
+
+	fmt.Printf("%-6s  %-5s  %-28s  %-26s  %s\n", "Offset", "Len", "Field", "Raw", "Value")
+	for _, f := range a.Layout() {
+		fmt.Printf("0x%04x  %5d  %-28s  %-26s  %s\n", f.Offset, len(f.Raw), f.Name, rawBytes(f.Raw), f.Value)
+	}
+
+	if parseErr != nil && parseErr != xwd.BadChecksums {
+		// The checksums can't be computed over a file that's incomplete.
+		if parseErr == io.EOF || parseErr == io.ErrUnexpectedEOF {
+			fmt.Printf("\nThe file is truncated: it ends after %d bytes, before the end of the last field shown\n", len(data))
+		} else {
+			fmt.Printf("\n%v\n", parseErr)
+		}
+		os.Exit(1)
+	}
+
+	fmt.Printf("\n%-20s  %-6s  %-8s\n", "Checksum", "Stored", "Computed")
+	for _, c := range a.Checksums() {
+		status := "ok"
+		if c.Stored != c.Computed {
+			status = "MISMATCH"
+		}
+		fmt.Printf("%-20s  0x%04x  0x%04x    %s\n", c.Name, c.Stored, c.Computed, status)
+	}
+
+	if trailing := len(data) - layoutEnd(a); trailing > 0 {
+		fmt.Printf("\n%d unrecognised bytes at the end of the file\n", trailing)
+	}
+	// Only bad checksums get this far, and they're marked above.
+	if parseErr != nil {
+		os.Exit(1)
+	}
+}
+
+// rawBytes formats up to the first eight bytes of b as hex.
+func rawBytes(b []byte) string {
+	if len(b) > 8 {
+		return fmt.Sprintf("% x ...", b[:8])
+	}
+	return fmt.Sprintf("% x", b)
+}
+
+func layoutEnd(a *xwd.AcrossLite) int {
+	end := 0
+	for _, f := range a.Layout() {
+		if e := f.Offset + len(f.Raw); e > end {
+			end = e
+		}
+	}
+	return end
+}
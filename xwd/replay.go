@@ -0,0 +1,140 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/nickstenning/xwd"
+)
+
+func replay(args []string) {
+	fs := flag.NewFlagSet("replay", flag.ExitOnError)
+	speed := fs.Float64("speed", 1, "how many times faster than real time to play the solve")
+	at := fs.Duration("at", -1, "show the grid as it was at this time into the solve (e.g. \"2m30s\"), rather than playing it")
+	from := fs.Duration("from", 0, "start playing from this time into the solve")
+	maxPause := fs.Duration("maxpause", 5*time.Second, "cut pauses in the solve longer than this short")
+	stats := fs.Bool("stats", false, "list the entries by how long they took to fill, with the number of wrong letters entered in each")
+	fs.Usage = func() {
+		fmt.Fprintf(
+			os.Stderr,
+			"Usage: %s replay [options] <recording> <puzzlefile>\n\n"+
+				"Plays back a solve recorded by the HTML solver.\n\n",
+			path.Base(os.Args[0]),
+		)
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 2 || *speed <= 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	f, err := os.Open(fs.Arg(0))
+	if err != nil {
+		logger.Fatal(err)
+	}
+	rec, err := xwd.ReadRecording(f)
+	f.Close()
+	if err != nil {
+		logger.Fatalf("%s: %v", fs.Arg(0), err)
+	}
+	puz, err := loadPuzzle(fs.Arg(1))
+	if err != nil {
+		logger.Fatalf("%s: %v", fs.Arg(1), err)
+	}
+
+	if *stats {
+		printEntryStats(puz, rec)
+		return
+	}
+
+	if *at >= 0 {
+		s, err := rec.Replay(puz, *at)
+		if err != nil {
+			logger.Fatal(err)
+		}
+		printReplay(puz, rec, s, *at)
+		return
+	}
+
+	// Play the solve by redrawing the grid after each event, waiting between
+	// them for as long as the solver did (at the chosen speed).
+	elapsed := *from
+	s, err := rec.Replay(puz, elapsed)
+	if err != nil {
+		logger.Fatal(err)
+	}
+	fmt.Print("\033[H\033[2J")
+	printReplay(puz, rec, s, elapsed)
+	for _, e := range rec.Events {
+		if e.Time <= elapsed {
+			continue
+		}
+		pause := e.Time - elapsed
+		if pause > *maxPause {
+			pause = *maxPause
+		}
+		time.Sleep(time.Duration(float64(pause) / *speed))
+		elapsed = e.Time
+		s, err = rec.Replay(puz, elapsed)
+		if err != nil {
+			logger.Fatal(err)
+		}
+		fmt.Print("\033[H\033[2J")
+		printReplay(puz, rec, s, elapsed)
+	}
+}
+
+// printReplay prints the grid of a replayed solve at the given time, with a
+// line saying who was solving and how far through they were.
+func printReplay(p *xwd.Puzzle, rec *xwd.Recording, s *xwd.Session, at time.Duration) {
+	printGrid(p, sessionText(s))
+	player := rec.Player
+	if player == "" {
+		player = "Anonymous"
+	}
+	if at > rec.Duration() {
+		at = rec.Duration()
+	}
+	assisted := ""
+	if rec.Assisted {
+		assisted = " (with autocheck)"
+	}
+	fmt.Printf("%s%s: %s / %s\n", player, assisted, at.Round(time.Second), rec.Duration().Round(time.Second))
+}
+
+// printEntryStats prints the entries of the puzzle ranked by how long they
+// took to fill in the recorded solve, slowest first.
+func printEntryStats(p *xwd.Puzzle, rec *xwd.Recording) {
+	stats, err := rec.EntryStats(p)
+	if err != nil {
+		logger.Fatal(err)
+	}
+	xwd.RankEntryStats(stats)
+	width := 0
+	for _, s := range stats {
+		if n := len(s.Entry.Name()); n > width {
+			width = n
+		}
+	}
+	for k, s := range stats {
+		note := ""
+		switch {
+		case s.Errors == 1:
+			note = "1 error"
+		case s.Errors > 1:
+			note = fmt.Sprintf("%d errors", s.Errors)
+		}
+		if !s.Complete {
+			note = strings.TrimPrefix(note+", unfinished", ", ")
+		}
+		line := fmt.Sprintf("%3d. %-*s %8s  %s", k+1, width, s.Entry.Name(), s.Time.Round(time.Second), note)
+		fmt.Println(strings.TrimRight(line, " "))
+	}
+}
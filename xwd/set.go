@@ -0,0 +1,159 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/nickstenning/xwd"
+)
+
+// clueEdits collects the repeatable -clue flag of "xwd set".
+type clueEdits []string
+
+func (c *clueEdits) String() string { return strings.Join(*c, ", ") }
+
+func (c *clueEdits) Set(s string) error {
+	*c = append(*c, s)
+	return nil
+}
+
+var clueEditRe = regexp.MustCompile(`^(?i)(\d+)\s*(a|d|across|down)\s*=(.*)$`)
+
+// parseClueEdit parses a clue edit of the form "12a=New clue text (5)" into a
+// direction, a (zero-indexed) clue number and the clue text.
+func parseClueEdit(s string) (xwd.Direction, int, string, error) {
+	m := clueEditRe.FindStringSubmatch(s)
+	if m == nil {
+		return 0, 0, "", fmt.Errorf("bad clue %q (expected e.g. \"12a=Clue text\" or \"3d=Clue text\")", s)
+	}
+	num, err := strconv.Atoi(m[1])
+	if err != nil {
+		return 0, 0, "", err
+	}
+	dir := xwd.Across
+	if strings.HasPrefix(strings.ToLower(m[2]), "d") {
+		dir = xwd.Down
+	}
+	return dir, num - 1, m[3], nil
+}
+
+func set(args []string) {
+	fs := flag.NewFlagSet("set", flag.ExitOnError)
+	fs.String("title", "", "set the title")
+	fs.String("author", "", "set the author")
+	fs.String("copyright", "", "set the copyright notice")
+	fs.String("notes", "", "set the notes")
+	var clues clueEdits
+	fs.Var(&clues, "clue", "set a clue, e.g. \"12a=Clue text (5)\" (may be repeated)")
+	output := fs.String("o", "", "write the puzzle to this file rather than back to the original")
+	fs.Usage = func() {
+		fmt.Fprintf(
+			os.Stderr,
+			"Usage: %s set [options] <puzzlefile>\n\n",
+			path.Base(os.Args[0]),
+		)
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	filename := fs.Arg(0)
+
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		logger.Fatal(err)
+	}
+	a := &xwd.AcrossLite{}
+	if !a.Sniff(data) {
+		logger.Fatalf("%s: not an AcrossLite (.puz) file", filename)
+	}
+	err = a.Parse(data)
+	if err != nil {
+		logger.Fatalf("%s: %v", filename, err)
+	}
+	puz := &xwd.Puzzle{}
+	err = a.Load(puz)
+	if err != nil {
+		logger.Fatalf("%s: %v", filename, err)
+	}
+
+	// Only the flags actually given are applied, so that e.g. -notes "" can
+	// be used to clear the notes.
+	fs.Visit(func(f *flag.Flag) {
+		value := f.Value.String()
+		switch f.Name {
+		case "title":
+			puz.Title = value
+		case "author":
+			puz.Author = value
+		case "copyright":
+			puz.Copyright = value
+		case "notes":
+			puz.Notes = value
+		}
+	})
+	for _, edit := range clues {
+		dir, num, text, err := parseClueEdit(edit)
+		if err != nil {
+			logger.Fatal(err)
+		}
+		err = puz.SetClue(dir, num, text)
+		if err != nil {
+			logger.Fatal(err)
+		}
+	}
+
+	err = a.Store(puz)
+	if err != nil {
+		logger.Fatal(err)
+	}
+
+	if *output == "" {
+		*output = filename
+	}
+	err = writeFileAtomic(*output, a.Data)
+	if err != nil {
+		logger.Fatal(err)
+	}
+}
+
+// writeFileAtomic writes data to a temporary file alongside name and renames
+// it into place, so that a failure part way through can't leave a truncated
+// puzzle behind. The permissions of any existing file are kept.
+func writeFileAtomic(name string, data []byte) error {
+	mode := os.FileMode(0644)
+	if info, err := os.Stat(name); err == nil {
+		mode = info.Mode().Perm()
+	}
+	f, err := ioutil.TempFile(filepath.Dir(name), "."+filepath.Base(name)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	_, err = f.Write(data)
+	if err != nil {
+		f.Close()
+		return err
+	}
+	err = f.Chmod(mode)
+	if err != nil {
+		f.Close()
+		return err
+	}
+	err = f.Close()
+	if err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), name)
+}
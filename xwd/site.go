@@ -0,0 +1,202 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/nickstenning/xwd"
+)
+
+func site(args []string) {
+	fs := flag.NewFlagSet("site", flag.ExitOnError)
+	title := fs.String("title", "Puzzles", "title of the top-level index page")
+	check := fs.Bool("check", false, "allow solvers to check their answers")
+	fs.Usage = func() {
+		fmt.Fprintf(
+			os.Stderr,
+			"Usage: %s site [options] <puzzledir> <outdir>\n\n",
+			path.Base(os.Args[0]),
+		)
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 2 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	g := &siteGenerator{
+		src:    fs.Arg(0),
+		dst:    fs.Arg(1),
+		title:  *title,
+		opts:   xwd.HTMLOptions{Check: *check},
+		search: make([]xwd.SearchEntry, 0),
+	}
+	err := g.generate("")
+	if err != nil {
+		logger.Fatal(err)
+	}
+
+	err = g.writeFile("search.json", func(w io.Writer) error {
+		return json.NewEncoder(w).Encode(g.search)
+	})
+	if err != nil {
+		logger.Fatal(err)
+	}
+}
+
+// siteGenerator generates a static website for a directory tree of puzzles,
+// using the same templates as xwdweb. Each directory gets an index page, and
+// each puzzle a solver page, a printable page and a copy in every format known
+// to "xwd convert".
+type siteGenerator struct {
+	src    string
+	dst    string
+	title  string
+	opts   xwd.HTMLOptions
+	search []xwd.SearchEntry
+}
+
+// generate writes the site for the directory rel (relative to the source
+// root), recursing into its subdirectories.
+func (g *siteGenerator) generate(rel string) error {
+	entries, err := ioutil.ReadDir(filepath.Join(g.src, rel))
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(filepath.Join(g.dst, rel), 0755)
+	if err != nil {
+		return err
+	}
+
+	idx := &xwd.Index{Title: g.title}
+	if rel != "" {
+		idx.Title = rel
+		idx.Parent = "../index.html"
+	} else {
+		idx.Search = "search.json"
+	}
+
+	for _, e := range entries {
+		name := e.Name()
+		if strings.HasPrefix(name, ".") {
+			continue
+		}
+		if e.IsDir() {
+			err = g.generate(path.Join(rel, name))
+			if err != nil {
+				return err
+			}
+			idx.Dirs = append(idx.Dirs, xwd.IndexLink{Name: name, URL: name + "/index.html"})
+			continue
+		}
+		if !strings.HasSuffix(name, ".puz") {
+			continue
+		}
+		ip, err := g.generatePuzzle(rel, name)
+		if err != nil {
+			logger.Printf("%s: %v", filepath.Join(g.src, rel, name), err)
+		}
+		idx.Puzzles = append(idx.Puzzles, ip)
+	}
+
+	return g.writeFile(path.Join(rel, "index.html"), func(w io.Writer) error {
+		return xwd.WriteIndex(w, idx)
+	})
+}
+
+// generatePuzzle writes the pages and downloads for a single puzzle, and
+// returns its entry for the directory index.
+func (g *siteGenerator) generatePuzzle(rel, name string) (xwd.IndexPuzzle, error) {
+	base := strings.TrimSuffix(name, ".puz")
+	ip := xwd.IndexPuzzle{
+		Name:  name,
+		Solve: base + ".html",
+		Print: base + ".print.html",
+	}
+
+	puz, err := loadPuzzle(filepath.Join(g.src, rel, name))
+	if err != nil {
+		return ip, err
+	}
+
+	err = g.writeFile(path.Join(rel, ip.Solve), func(w io.Writer) error {
+		return xwd.WriteHTML(w, puz, g.opts)
+	})
+	if err != nil {
+		return ip, err
+	}
+
+	printOpts := g.opts
+	printOpts.Print = true
+	err = g.writeFile(path.Join(rel, ip.Print), func(w io.Writer) error {
+		return xwd.WriteHTML(w, puz, printOpts)
+	})
+	if err != nil {
+		return ip, err
+	}
+
+	err = g.copyFile(path.Join(rel, name))
+	if err != nil {
+		return ip, err
+	}
+	ip.Downloads = append(ip.Downloads, xwd.IndexLink{Name: "puz", URL: name})
+
+	for _, format := range formatNames() {
+		if format == "html" {
+			ip.Downloads = append(ip.Downloads, xwd.IndexLink{Name: format, URL: ip.Solve})
+			continue
+		}
+		out := base + "." + format
+		err = g.writeFile(path.Join(rel, out), func(w io.Writer) error {
+			return writers[format](w, puz)
+		})
+		if err != nil {
+			return ip, err
+		}
+		ip.Downloads = append(ip.Downloads, xwd.IndexLink{Name: format, URL: out})
+	}
+
+	ip.Puzzle = puz
+	g.search = append(g.search, xwd.SearchEntry{
+		Title:     puz.Title,
+		Author:    puz.Author,
+		Copyright: puz.Copyright,
+		URL:       path.Join(rel, ip.Solve),
+	})
+	return ip, nil
+}
+
+func (g *siteGenerator) writeFile(name string, write func(io.Writer) error) error {
+	f, err := os.Create(filepath.Join(g.dst, name))
+	if err != nil {
+		return err
+	}
+	err = write(f)
+	if err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func (g *siteGenerator) copyFile(name string) error {
+	in, err := os.Open(filepath.Join(g.src, name))
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	return g.writeFile(name, func(w io.Writer) error {
+		_, err := io.Copy(w, in)
+		return err
+	})
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/nickstenning/xwd"
+)
+
+// sources collects the repeatable -clues flag of "xwd solve".
+type sources []string
+
+func (s *sources) String() string { return strings.Join(*s, ", ") }
+
+func (s *sources) Set(v string) error {
+	*s = append(*s, v)
+	return nil
+}
+
+func solve(args []string) {
+	fs := flag.NewFlagSet("solve", flag.ExitOnError)
+	var clues sources
+	fs.Var(&clues, "clues", "clue database: a CSV file with clue and answer columns, or a puzzle file or directory (may be repeated)")
+	words := fs.String("words", "", "word list, in WORD;SCORE format")
+	steps := fs.Int("steps", xwd.DefaultSolverSteps, "the most search steps to take")
+	candidates := fs.Int("candidates", 0, "list this many of the top candidates for each entry")
+	fs.Usage = func() {
+		fmt.Fprintf(
+			os.Stderr,
+			"Usage: %s solve [options] <puzzlefile>\n\n",
+			path.Base(os.Args[0]),
+		)
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	puz, err := loadPuzzle(fs.Arg(0))
+	if err != nil {
+		logger.Fatal(err)
+	}
+
+	s := &xwd.Solver{MaxSteps: *steps}
+	if len(clues) > 0 {
+		s.Clues, err = loadClueDB(clues, puz)
+		if err != nil {
+			logger.Fatal(err)
+		}
+	}
+	if *words != "" {
+		s.Words, err = loadWordList(*words)
+		if err != nil {
+			logger.Fatal(err)
+		}
+	}
+	if s.Clues == nil && s.Words == nil {
+		logger.Fatal("you must supply a clue database (-clues) or a word list (-words)")
+	}
+
+	res := s.Solve(puz)
+	printGrid(puz, func(cell *xwd.Cell) string {
+		letter := res.Letter(cell.Coords[0], cell.Coords[1])
+		if letter == "" {
+			return "   "
+		}
+		text := fmt.Sprintf(" %s ", letter)
+		if res.Confidence(cell.Coords[0], cell.Coords[1]) < 0.5 {
+			return grey(text)
+		}
+		return text
+	})
+
+	if *candidates > 0 {
+		fmt.Println()
+		for _, e := range puz.Entries() {
+			cands := s.Candidates(e)
+			if len(cands) > *candidates {
+				cands = cands[:*candidates]
+			}
+			answers := make([]string, len(cands))
+			for i, c := range cands {
+				answers[i] = fmt.Sprintf("%s (%d)", c.Answer, c.Score)
+			}
+			fmt.Printf("%3d %-6s %s\n", e.Num+1, e.Direction, strings.Join(answers, ", "))
+		}
+	}
+
+	fmt.Println()
+	if res.Solved {
+		fmt.Printf("Solved in %d steps\n", res.Steps)
+	} else {
+		fmt.Printf("Not solved (%d steps)\n", res.Steps)
+	}
+	fmt.Printf("Accuracy: %.1f%%\n", 100*res.Accuracy(puz))
+}
+
+// loadClueDB builds a clue database from CSV files and solved puzzles, leaving
+// out any copy of the puzzle exclude, if given.
+func loadClueDB(names []string, exclude *xwd.Puzzle) (*xwd.ClueDB, error) {
+	db := xwd.NewClueDB()
+	fingerprint := ""
+	if exclude != nil {
+		fingerprint = exclude.Fingerprint()
+	}
+	for _, name := range names {
+		if strings.HasSuffix(name, ".csv") {
+			f, err := os.Open(name)
+			if err != nil {
+				return nil, err
+			}
+			csvDB, err := xwd.ReadClueDB(f)
+			f.Close()
+			if err != nil {
+				return nil, fmt.Errorf("%s: %v", name, err)
+			}
+			db.Merge(csvDB)
+			continue
+		}
+		files, err := puzzleFiles(name)
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range files {
+			puz, err := loadPuzzle(file)
+			if err != nil {
+				logger.Printf("%s: %v", file, err)
+				continue
+			}
+			if puz.Fingerprint() != fingerprint {
+				db.AddPuzzle(puz)
+			}
+		}
+	}
+	return db, nil
+}
+
+func loadWordList(name string) (*xwd.WordList, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	wl, err := xwd.ReadWordList(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+	return wl, nil
+}
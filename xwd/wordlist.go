@@ -0,0 +1,212 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/nickstenning/xwd"
+)
+
+// wordlistCommands maps the subcommands of "xwd wordlist" to the functions
+// implementing them.
+var wordlistCommands = map[string]func(args []string){
+	"build": wordlistBuild,
+	"check": wordlistCheck,
+	"merge": wordlistMerge,
+}
+
+func wordlistUsage() {
+	names := make([]string, 0, len(wordlistCommands))
+	for name := range wordlistCommands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	fmt.Fprintf(
+		os.Stderr,
+		"Usage: %s wordlist <command> [options] <args>\n\nCommands:\n",
+		path.Base(os.Args[0]),
+	)
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %s\n", name)
+	}
+}
+
+func wordlist(args []string) {
+	if len(args) == 0 {
+		wordlistUsage()
+		os.Exit(2)
+	}
+	cmd, ok := wordlistCommands[args[0]]
+	if !ok {
+		wordlistUsage()
+		os.Exit(2)
+	}
+	cmd(args[1:])
+}
+
+func wordlistFlags(name, usage string) *flag.FlagSet {
+	fs := flag.NewFlagSet("wordlist "+name, flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(
+			os.Stderr,
+			"Usage: %s wordlist %s [options] %s\n\n",
+			path.Base(os.Args[0]),
+			name,
+			usage,
+		)
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+func wordlistMerge(args []string) {
+	fs := wordlistFlags("merge", "<wordlist>...")
+	output := fs.String("o", "", "write the merged list to this file rather than stdout")
+	block := fs.String("block", "", "leave out the words in this blocklist")
+	fs.Parse(args)
+
+	if fs.NArg() == 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	wl := xwd.NewWordList()
+	for _, name := range fs.Args() {
+		other, err := loadWordList(name)
+		if err != nil {
+			logger.Fatal(err)
+		}
+		wl.Merge(other)
+	}
+	writeWordList(wl, *block, *output)
+}
+
+// copyrightYear finds a year in a puzzle's copyright notice.
+var copyrightYear = regexp.MustCompile(`\b(1[89]|20)\d\d\b`)
+
+// puzzleDate returns the date a puzzle was published, as well as can be told:
+// the start of the year in its copyright notice if there is one, and the
+// modification time of its file otherwise.
+func puzzleDate(filename string, p *xwd.Puzzle) time.Time {
+	if m := copyrightYear.FindString(p.Copyright); m != "" {
+		year, _ := strconv.Atoi(m)
+		return time.Date(year, 1, 1, 0, 0, 0, 0, time.UTC)
+	}
+	info, err := os.Stat(filename)
+	if err != nil {
+		return time.Now()
+	}
+	return info.ModTime()
+}
+
+func wordlistBuild(args []string) {
+	fs := wordlistFlags("build", "<puzzledir>")
+	output := fs.String("o", "", "write the list to this file rather than stdout")
+	block := fs.String("block", "", "leave out the words in this blocklist")
+	halfLife := fs.Float64("half-life", 10, "age in years at which an answer's use counts half (0 for no decay)")
+	workers := fs.Int("j", 0, "number of puzzles to load in parallel (default one per CPU)")
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	year := 365.25 * 24 * float64(time.Hour)
+	b := xwd.NewWordListBuilder(time.Now(), time.Duration(*halfLife*year))
+	var mu sync.Mutex
+	fn := func(ctx context.Context, filename string, p *xwd.Puzzle) (string, error) {
+		when := puzzleDate(filename, p)
+		mu.Lock()
+		defer mu.Unlock()
+		b.AddPuzzle(p, when)
+		return "", nil
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	report, err := xwd.Batch(ctx, fs.Arg(0), *workers, fn)
+	for _, r := range report.Results {
+		if r.Err != nil {
+			logger.Printf("%s: %v", r.Path, r.Err)
+		}
+	}
+	if err != nil {
+		logger.Fatal(err)
+	}
+	fmt.Fprintf(os.Stderr, "%d puzzles in %v: %d ok, %d failed\n",
+		len(report.Results), report.Duration.Round(1e6), len(report.Results)-report.Failed, report.Failed)
+
+	writeWordList(b.WordList(), *block, *output)
+}
+
+func wordlistCheck(args []string) {
+	fs := wordlistFlags("check", "<wordlist>...")
+	block := fs.String("block", "", "blocklist to check the word lists against")
+	fs.Parse(args)
+
+	if fs.NArg() == 0 || *block == "" {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	blocklist, err := loadWordList(*block)
+	if err != nil {
+		logger.Fatal(err)
+	}
+	found := false
+	for _, name := range fs.Args() {
+		wl, err := loadWordList(name)
+		if err != nil {
+			logger.Fatal(err)
+		}
+		for _, word := range wl.Blocked(blocklist) {
+			score, _ := wl.Score(word)
+			fmt.Printf("%s: %s;%d\n", name, word, score)
+			found = true
+		}
+	}
+	if found {
+		os.Exit(1)
+	}
+}
+
+// writeWordList writes a word list to the named file, or stdout, leaving out
+// any words in the named blocklist.
+func writeWordList(wl *xwd.WordList, block, output string) {
+	if block != "" {
+		blocklist, err := loadWordList(block)
+		if err != nil {
+			logger.Fatal(err)
+		}
+		for _, word := range wl.Blocked(blocklist) {
+			wl.Remove(word)
+		}
+	}
+
+	var w io.Writer = os.Stdout
+	if output != "" {
+		f, err := os.Create(output)
+		if err != nil {
+			logger.Fatal(err)
+		}
+		defer f.Close()
+		w = f
+	}
+	_, err := wl.WriteTo(w)
+	if err != nil {
+		logger.Fatal(err)
+	}
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"errors"
 	"flag"
 	"fmt"
 	"io/ioutil"
@@ -8,12 +9,18 @@ import (
 	"math"
 	"os"
 	"path"
+	"sort"
 	"strings"
 
 	"github.com/nickstenning/xwd"
 )
 
 var showSolution = flag.Bool("s", false, "show the solution rather than the blank puzzle")
+var showFill = flag.Bool("f", false, "show the progress saved in the puzzle file, with pencilled letters in grey")
+
+// recoverClues makes loadPuzzle tolerate puzzles with the wrong number of
+// clues for their grid, warning about any it couldn't place.
+var recoverClues bool
 var logger = log.New(os.Stderr, "xwd: ", log.LstdFlags)
 
 var boxTop = []string{"┌", "─", "┬", "┐"}
@@ -21,17 +28,54 @@ var boxLin = []string{"│", "█", "│", "│"}
 var boxMid = []string{"├", "─", "┼", "┤"}
 var boxBot = []string{"└", "─", "┴", "┘"}
 
+// commands maps subcommand names to the functions implementing them. Each is
+// passed the arguments following the subcommand name.
+var commands = map[string]func(args []string){
+	"anagram":    anagram,
+	"batch":      batch,
+	"book":       book,
+	"convert":    convert,
+	"difficulty": difficulty,
+	"fill":       fill,
+	"generate":   generate,
+	"inspect":    inspect,
+	"replay":     replay,
+	"set":        set,
+	"site":       site,
+	"solve":      solve,
+	"wordlist":   wordlist,
+}
+
 func usage() {
 	fmt.Fprintf(
 		os.Stderr,
-		"Usage: %s [options] <puzzlefile>\n\n",
+		"Usage: %s [options] <puzzlefile>\n"+
+			"       %s <command> [options] <args>\n\n",
+		path.Base(os.Args[0]),
 		path.Base(os.Args[0]),
 	)
-	fmt.Fprintf(os.Stderr, "Options:\n")
+	fmt.Fprintf(os.Stderr, "Commands:\n")
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %s\n", name)
+	}
+	fmt.Fprintf(os.Stderr, "\nOptions:\n")
 	flag.PrintDefaults()
 }
 
 func main() {
+	if len(os.Args) > 1 {
+		if cmd, ok := commands[os.Args[1]]; ok {
+			cmd(os.Args[2:])
+			return
+		}
+	}
+
+	flag.BoolVar(&recoverClues, "recover", false, "load puzzles with the wrong number of clues as well as possible")
 	flag.Usage = usage
 	flag.Parse()
 
@@ -39,23 +83,25 @@ func main() {
 		log.Fatal("you must supply a .puz file")
 	}
 
-	filename := flag.Arg(0)
-	f, err := os.Open(filename)
-	if err != nil {
-		logger.Fatal(err)
-	}
-	data, err := ioutil.ReadAll(f)
+	puz, err := loadPuzzle(flag.Arg(0))
 	if err != nil {
 		logger.Fatal(err)
 	}
 
-	puz := &xwd.Puzzle{}
-	err = puz.Load(data)
-	if err != nil {
-		logger.Fatal(err)
+	text := puzzleText(*showSolution)
+	assisted := false
+	if *showFill {
+		s, err := loadSession(flag.Arg(0))
+		if err != nil {
+			logger.Fatal(err)
+		}
+		text = sessionText(s)
+		assisted = s.Assisted()
+	}
+	printGrid(puz, text)
+	if assisted {
+		fmt.Printf("\n(filled with autocheck)\n")
 	}
-
-	printGrid(puz, *showSolution)
 
 	fmt.Printf("\nAcross:\n\n")
 	printClues(puz.CluesAcross())
@@ -64,6 +110,60 @@ func main() {
 	printClues(puz.CluesDown())
 }
 
+func loadPuzzle(filename string) (*xwd.Puzzle, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	data, err := ioutil.ReadAll(f)
+	if err != nil {
+		return nil, err
+	}
+	puz := &xwd.Puzzle{}
+	if !recoverClues {
+		err = puz.Load(data)
+		if err != nil {
+			return nil, err
+		}
+		return puz, nil
+	}
+
+	err = puz.Recover(data)
+	var cerr *xwd.ClueCountError
+	if errors.As(err, &cerr) {
+		logger.Printf("%s: %v", filename, cerr)
+		for _, e := range cerr.Unfilled {
+			logger.Printf("%s: no clue for %d %s %s", filename, e.Num+1, e.Direction, e.Enumeration())
+		}
+		for _, clue := range cerr.Unplaced {
+			logger.Printf("%s: couldn't place clue %q", filename, clue)
+		}
+		return puz, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return puz, nil
+}
+
+// loadSession reads the solving session saved in an AcrossLite file.
+func loadSession(filename string) (*xwd.Session, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	a := &xwd.AcrossLite{}
+	if !a.Sniff(data) {
+		return nil, xwd.NoProviderFound
+	}
+	err = a.Parse(data)
+	if err != nil {
+		return nil, err
+	}
+	return a.Session(), nil
+}
+
 func printClues(clues []xwd.Clue) {
 	max := clues[len(clues)-1].Num + 1
 	wrapw := int(math.Floor(math.Log10(float64(max)))) + 1
@@ -72,19 +172,65 @@ func printClues(clues []xwd.Clue) {
 	}
 }
 
-func printGrid(p *xwd.Puzzle, solution bool) {
+// cellText returns the three characters to show in a white cell of the grid.
+type cellText func(cell *xwd.Cell) string
+
+// puzzleText shows either the solution or the clue numbers in each cell.
+func puzzleText(solution bool) cellText {
+	return func(cell *xwd.Cell) string {
+		if solution {
+			return squareText(cell.Solution)
+		} else if cell.Num != -1 {
+			return fmt.Sprintf("%3d", cell.Num+1) // cell.Num is zero-indexed
+		}
+		return "   "
+	}
+}
+
+// sessionText shows the letters entered in a solving session, with pencilled
+// letters in grey, and the clue numbers in empty cells.
+func sessionText(s *xwd.Session) cellText {
+	numbers := puzzleText(false)
+	return func(cell *xwd.Cell) string {
+		i, j := cell.Coords[0], cell.Coords[1]
+		letter := s.Letter(i, j)
+		switch {
+		case letter == "":
+			return numbers(cell)
+		case s.Pencilled(i, j):
+			return grey(squareText(letter))
+		}
+		return squareText(letter)
+	}
+}
+
+// squareText centres a letter in the three characters of a square. A rebus
+// too long to fit is cut short, with its last shown character replaced by
+// "…".
+func squareText(s string) string {
+	r := []rune(s)
+	switch {
+	case len(r) <= 1:
+		return fmt.Sprintf(" %-1s ", s)
+	case len(r) <= 3:
+		return fmt.Sprintf("%-3s", s)
+	}
+	return string(r[:2]) + "…"
+}
+
+func printGrid(p *xwd.Puzzle, text cellText) {
 	for i := 0; i < p.Rows; i++ {
-		printRow(p, i, solution)
+		printRow(p, i, text)
 	}
 }
 
-func printRow(p *xwd.Puzzle, row int, solution bool) {
+func printRow(p *xwd.Puzzle, row int, text cellText) {
 	if row == 0 {
 		fmt.Print(boxDivider(p.Cols, boxTop))
 	} else {
 		fmt.Print(boxDivider(p.Cols, boxMid))
 	}
-	fmt.Print(boxRow(p, row, solution))
+	fmt.Print(boxRow(p, row, text))
 	if row+1 == p.Rows {
 		fmt.Print(boxDivider(p.Cols, boxBot))
 	}
@@ -100,7 +246,7 @@ func boxDivider(nCells int, box []string) string {
 	return strings.Join(out, "")
 }
 
-func boxRow(p *xwd.Puzzle, row int, solution bool) string {
+func boxRow(p *xwd.Puzzle, row int, text cellText) string {
 	out := []string{}
 	i := row
 	for j := 0; j < p.Cols; j++ {
@@ -108,13 +254,11 @@ func boxRow(p *xwd.Puzzle, row int, solution bool) string {
 		if err != nil {
 			panic(err)
 		}
-		cs := "   "
+		var cs string
 		if cell.Black {
 			cs = strings.Repeat(boxLin[1], 3)
-		} else if solution {
-			cs = fmt.Sprintf(" %s ", cell.Solution)
-		} else if cell.Num != -1 {
-			cs = fmt.Sprintf("%3d", cell.Num+1) // cell.Num is zero-indexed
+		} else {
+			cs = text(cell)
 		}
 		if j == 0 {
 			out = append(out, strings.Join([]string{boxLin[0], cs, boxLin[2]}, ""))
@@ -0,0 +1,381 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxArchiveCache is the most archive contents, in bytes unpacked, that
+// archiveFS keeps in memory. Beyond it, the archives used least recently are
+// dropped, to be read again when next needed.
+const maxArchiveCache = 64 << 20
+
+// archiveSuffixes are the file name suffixes of the archives that archiveFS
+// presents as directories.
+var archiveSuffixes = []string{".zip", ".tar", ".tar.gz", ".tgz"}
+
+func isArchive(name string) bool {
+	for _, suffix := range archiveSuffixes {
+		if strings.HasSuffix(name, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// archiveFS wraps a filesystem, presenting the zip and tar (optionally
+// gzipped) archives within it as directories, so that their contents can be
+// browsed and served without extracting them. Archives are read into memory
+// when first accessed, and reread if they change or have been dropped from
+// the cache.
+type archiveFS struct {
+	fsys   fs.FS
+	mu     sync.Mutex
+	cache  map[string]*cachedArchive
+	cached int64 // The total unpacked size of the cached archives
+	limit  int64 // The most that may be cached
+}
+
+type cachedArchive struct {
+	modTime  time.Time
+	size     int64
+	fsys     memFS
+	unpacked int64     // The total size of the files in fsys
+	used     time.Time // When it was last accessed
+}
+
+func newArchiveFS(fsys fs.FS) *archiveFS {
+	return &archiveFS{fsys: fsys, cache: make(map[string]*cachedArchive), limit: maxArchiveCache}
+}
+
+// split finds the archive, if any, containing the named file, and returns the
+// path of the archive and the path of the file within it.
+func (a *archiveFS) split(name string) (archive, rest string, ok bool) {
+	parts := strings.Split(name, "/")
+	for i, part := range parts {
+		if !isArchive(part) {
+			continue
+		}
+		archive = strings.Join(parts[:i+1], "/")
+		info, err := fs.Stat(a.fsys, archive)
+		if err != nil || info.IsDir() {
+			continue
+		}
+		rest = strings.Join(parts[i+1:], "/")
+		if rest == "" {
+			rest = "."
+		}
+		return archive, rest, true
+	}
+	return "", "", false
+}
+
+// archive returns a filesystem for the contents of the named archive.
+func (a *archiveFS) archive(name string) (fs.FS, error) {
+	info, err := fs.Stat(a.fsys, name)
+	if err != nil {
+		return nil, err
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	c, ok := a.cache[name]
+	if ok && c.modTime.Equal(info.ModTime()) && c.size == info.Size() {
+		c.used = time.Now()
+		return c.fsys, nil
+	}
+	if ok {
+		a.drop(name)
+	}
+
+	data, err := fs.ReadFile(a.fsys, name)
+	if err != nil {
+		return nil, err
+	}
+	var afs memFS
+	if strings.HasSuffix(name, ".zip") {
+		afs, err = readZip(data, info.ModTime())
+	} else {
+		afs, err = readTar(data, !strings.HasSuffix(name, ".tar"), info.ModTime())
+	}
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+
+	c = &cachedArchive{modTime: info.ModTime(), size: info.Size(), fsys: afs, unpacked: afs.size(), used: time.Now()}
+	for a.cached+c.unpacked > a.limit && len(a.cache) > 0 {
+		oldest := ""
+		for n, other := range a.cache {
+			if oldest == "" || other.used.Before(a.cache[oldest].used) {
+				oldest = n
+			}
+		}
+		a.drop(oldest)
+	}
+	a.cache[name] = c
+	a.cached += c.unpacked
+	return afs, nil
+}
+
+// drop removes an archive from the cache. The caller must hold a.mu.
+func (a *archiveFS) drop(name string) {
+	a.cached -= a.cache[name].unpacked
+	delete(a.cache, name)
+}
+
+func (a *archiveFS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	archive, rest, ok := a.split(name)
+	if !ok {
+		return a.fsys.Open(name)
+	}
+	afs, err := a.archive(archive)
+	if err != nil {
+		return nil, err
+	}
+	return afs.Open(rest)
+}
+
+func (a *archiveFS) Stat(name string) (fs.FileInfo, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrInvalid}
+	}
+	archive, rest, ok := a.split(name)
+	if !ok {
+		return fs.Stat(a.fsys, name)
+	}
+	if rest == "." {
+		info, err := fs.Stat(a.fsys, archive)
+		if err != nil {
+			return nil, err
+		}
+		return archiveInfo{info}, nil
+	}
+	afs, err := a.archive(archive)
+	if err != nil {
+		return nil, err
+	}
+	return fs.Stat(afs, rest)
+}
+
+func (a *archiveFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrInvalid}
+	}
+	archive, rest, ok := a.split(name)
+	if ok {
+		afs, err := a.archive(archive)
+		if err != nil {
+			return nil, err
+		}
+		return fs.ReadDir(afs, rest)
+	}
+
+	entries, err := fs.ReadDir(a.fsys, name)
+	if err != nil {
+		return nil, err
+	}
+	for i, e := range entries {
+		if !e.IsDir() && isArchive(e.Name()) {
+			entries[i] = archiveEntry{e}
+		}
+	}
+	return entries, nil
+}
+
+// archiveInfo and archiveEntry present an archive file as a directory.
+type archiveInfo struct{ fs.FileInfo }
+
+func (archiveInfo) IsDir() bool { return true }
+
+func (i archiveInfo) Mode() fs.FileMode { return fs.ModeDir | 0555 }
+
+type archiveEntry struct{ fs.DirEntry }
+
+func (archiveEntry) IsDir() bool { return true }
+
+func (archiveEntry) Type() fs.FileMode { return fs.ModeDir }
+
+func (e archiveEntry) Info() (fs.FileInfo, error) {
+	info, err := e.DirEntry.Info()
+	if err != nil {
+		return nil, err
+	}
+	return archiveInfo{info}, nil
+}
+
+// readZip reads the regular files from a zip archive into an in-memory
+// filesystem, as readTar does. The files a zip.Reader opens can't seek, which
+// http.FileServer needs them to.
+func readZip(data []byte, modTime time.Time) (memFS, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, err
+	}
+
+	m := memFS{".": &memFile{name: ".", mode: fs.ModeDir | 0555, modTime: modTime}}
+	for _, zf := range zr.File {
+		if !zf.Mode().IsRegular() {
+			continue
+		}
+		name := path.Clean(strings.TrimPrefix(zf.Name, "/"))
+		if !fs.ValidPath(name) {
+			continue
+		}
+		r, err := zf.Open()
+		if err != nil {
+			return nil, err
+		}
+		body, err := io.ReadAll(r)
+		r.Close()
+		if err != nil {
+			return nil, err
+		}
+		m.add(&memFile{name: name, data: body, mode: zf.Mode().Perm(), modTime: zf.Modified}, modTime)
+	}
+	return m, nil
+}
+
+// readTar reads the regular files from a (possibly gzipped) tar archive into
+// an in-memory filesystem. Directories are synthesised from the file paths,
+// and take their modification time from the archive itself.
+func readTar(data []byte, gzipped bool, modTime time.Time) (memFS, error) {
+	var r io.Reader = bytes.NewReader(data)
+	if gzipped {
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		r = gz
+	}
+
+	m := memFS{".": &memFile{name: ".", mode: fs.ModeDir | 0555, modTime: modTime}}
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		name := path.Clean(strings.TrimPrefix(hdr.Name, "/"))
+		if !fs.ValidPath(name) {
+			continue
+		}
+		body, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, err
+		}
+		m.add(&memFile{name: name, data: body, mode: fs.FileMode(hdr.Mode).Perm(), modTime: hdr.ModTime}, modTime)
+	}
+	return m, nil
+}
+
+// memFS is a read-only, in-memory filesystem, mapping cleaned paths to files
+// and directories.
+type memFS map[string]*memFile
+
+type memFile struct {
+	name     string
+	data     []byte
+	mode     fs.FileMode
+	modTime  time.Time
+	children []string // Sorted base names, for directories
+}
+
+// size returns the total size of the files in m.
+func (m memFS) size() int64 {
+	var n int64
+	for _, f := range m {
+		n += int64(len(f.data))
+	}
+	return n
+}
+
+// add adds a file, creating any missing parent directories.
+func (m memFS) add(f *memFile, dirModTime time.Time) {
+	m[f.name] = f
+	for name := f.name; name != "."; {
+		dir := path.Dir(name)
+		d, ok := m[dir]
+		if !ok {
+			d = &memFile{name: dir, mode: fs.ModeDir | 0555, modTime: dirModTime}
+			m[dir] = d
+		}
+		base := path.Base(name)
+		i := sort.SearchStrings(d.children, base)
+		if i < len(d.children) && d.children[i] == base {
+			return
+		}
+		d.children = append(d.children, "")
+		copy(d.children[i+1:], d.children[i:])
+		d.children[i] = base
+		name = dir
+	}
+}
+
+func (m memFS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	f, ok := m[name]
+	if !ok {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	return &openMemFile{memFile: f, fs: m, Reader: bytes.NewReader(f.data)}, nil
+}
+
+func (f *memFile) Name() string               { return path.Base(f.name) }
+func (f *memFile) Size() int64                { return int64(len(f.data)) }
+func (f *memFile) Mode() fs.FileMode          { return f.mode }
+func (f *memFile) ModTime() time.Time         { return f.modTime }
+func (f *memFile) IsDir() bool                { return f.mode.IsDir() }
+func (f *memFile) Sys() interface{}           { return nil }
+func (f *memFile) Type() fs.FileMode          { return f.mode.Type() }
+func (f *memFile) Info() (fs.FileInfo, error) { return f, nil }
+
+type openMemFile struct {
+	*memFile
+	*bytes.Reader
+	fs     memFS
+	offset int // The number of directory entries already read
+}
+
+func (f *openMemFile) Stat() (fs.FileInfo, error) { return f.memFile, nil }
+
+func (f *openMemFile) Close() error { return nil }
+
+func (f *openMemFile) ReadDir(n int) ([]fs.DirEntry, error) {
+	if !f.IsDir() {
+		return nil, &fs.PathError{Op: "readdir", Path: f.name, Err: fs.ErrInvalid}
+	}
+	names := f.children[f.offset:]
+	if n > 0 && len(names) == 0 {
+		return nil, io.EOF
+	}
+	if n > 0 && len(names) > n {
+		names = names[:n]
+	}
+	entries := make([]fs.DirEntry, len(names))
+	for i, base := range names {
+		entries[i] = f.fs[path.Join(f.name, base)]
+	}
+	f.offset += len(names)
+	return entries, nil
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"io/fs"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestServeZip(t *testing.T) {
+	puz, err := ioutil.ReadFile("../fixtures/version_13.puz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	files := map[string][]byte{
+		"b/c.puz":     puz,
+		"b/notes.txt": []byte("Some notes\n"),
+	}
+	for name, data := range files {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write(data)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	if err := ioutil.WriteFile(filepath.Join(dir, "a.zip"), buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	server := NewPuzzleServer(dir)
+
+	tests := []struct {
+		url      string
+		expected []byte
+	}{
+		{"/a.zip/b/c.puz?download", puz},
+		{"/a.zip/b/notes.txt", files["b/notes.txt"]},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		server.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.url, nil))
+		if rec.Code != http.StatusOK {
+			t.Errorf("%s: expectation failure (expected: %d, got: %d %s)", tt.url, http.StatusOK, rec.Code, rec.Body)
+			continue
+		}
+		if !bytes.Equal(rec.Body.Bytes(), tt.expected) {
+			t.Errorf("%s: expectation failure (expected: %d bytes, got: %d)", tt.url, len(tt.expected), rec.Body.Len())
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	server.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/a.zip/b/", nil))
+	if rec.Code != http.StatusOK || !bytes.Contains(rec.Body.Bytes(), []byte("c.puz")) {
+		t.Errorf("expected the index of the zip's directory to list its puzzle, got: %d", rec.Code)
+	}
+}
+
+func TestArchiveCacheLimit(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"a.zip", "b.zip"} {
+		var buf bytes.Buffer
+		zw := zip.NewWriter(&buf)
+		w, err := zw.Create("notes.txt")
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write(bytes.Repeat([]byte("x"), 100))
+		if err := zw.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(dir, name), buf.Bytes(), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	a := newArchiveFS(os.DirFS(dir))
+	a.limit = 150
+
+	for _, name := range []string{"a.zip", "b.zip", "a.zip"} {
+		data, err := fs.ReadFile(a, name+"/notes.txt")
+		if err != nil || len(data) != 100 {
+			t.Fatalf("%s: expected 100 bytes, got: %d (%v)", name, len(data), err)
+		}
+		if _, ok := a.cache[name]; !ok || len(a.cache) != 1 || a.cached != 100 {
+			t.Errorf("%s: expected only the archive last read to be cached, got: %d archives, %d bytes", name, len(a.cache), a.cached)
+		}
+	}
+}
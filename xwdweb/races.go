@@ -0,0 +1,354 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/nickstenning/xwd"
+)
+
+// raceLifetime is how long races are kept after they're created.
+const raceLifetime = 24 * time.Hour
+
+// maxRaces limits how many races can be in progress at once, so that
+// creating races can't use up the server's memory.
+const maxRaces = 1000
+
+// tooManyRaces is returned by raceRooms.add when there are maxRaces already.
+var tooManyRaces = errors.New("too many races in progress; try again later")
+
+// maxRaceUpdateSize limits the size of the requests made by racers.
+const maxRaceUpdateSize = 1 << 20
+
+// raceRooms holds the races in progress, in memory.
+type raceRooms struct {
+	mu    sync.Mutex
+	rooms map[string]*raceRoom
+
+	// spectate is whether the races can be watched, and delay the least
+	// delay with which spectators see them (which may be zero, for watching
+	// live).
+	spectate bool
+	delay    time.Duration
+
+	// results is the directory in which the results of the races are kept,
+	// or "" if they're only logged.
+	results string
+}
+
+// raceRoom is a race on one of the served puzzles.
+type raceRoom struct {
+	name string // The path of the puzzle
+	race *xwd.Race
+
+	// watch is the secret key in the URL at which the race can be watched,
+	// which is given only to the server's operator, so that the players
+	// can't read each other's grids. It's "" if spectating is disabled.
+	watch string
+
+	mu      sync.Mutex        // Held while the results are written
+	replays map[string]string // The IDs of the players' replays, by name
+}
+
+func newRaceRooms(spectate bool, delay time.Duration, results string) *raceRooms {
+	return &raceRooms{rooms: make(map[string]*raceRoom), spectate: spectate, delay: delay, results: results}
+}
+
+// add creates a race on the named puzzle, returning it and its ID, which is
+// random, so that the race's URL can't be guessed.
+func (rr *raceRooms) add(name string, puz *xwd.Puzzle) (string, *raceRoom, error) {
+	race, err := xwd.NewRace(puz)
+	if err != nil {
+		return "", nil, err
+	}
+	room := &raceRoom{name: name, race: race, replays: make(map[string]string)}
+	if rr.spectate {
+		room.watch, err = randomKey()
+		if err != nil {
+			return "", nil, err
+		}
+	}
+	rr.mu.Lock()
+	defer rr.mu.Unlock()
+	rr.expire()
+	if len(rr.rooms) >= maxRaces {
+		return "", nil, tooManyRaces
+	}
+	for {
+		id, err := randomKey()
+		if err != nil {
+			return "", nil, err
+		}
+		if _, ok := rr.rooms[id]; !ok {
+			rr.rooms[id] = room
+			return id, room, nil
+		}
+	}
+}
+
+// get returns the race with the given ID on the named puzzle, or nil if
+// there's none.
+func (rr *raceRooms) get(name, id string) *raceRoom {
+	rr.mu.Lock()
+	defer rr.mu.Unlock()
+	rr.expire()
+	room, ok := rr.rooms[id]
+	if !ok || room.name != name {
+		return nil
+	}
+	return room
+}
+
+// expire forgets the races that have outlived raceLifetime. The caller must
+// hold rr.mu.
+func (rr *raceRooms) expire() {
+	for id, room := range rr.rooms {
+		if time.Since(room.race.Created()) > raceLifetime {
+			delete(rr.rooms, id)
+		}
+	}
+}
+
+// randomKey returns a random hex string, for use where an ID must be unique
+// and mustn't be guessable.
+func randomKey() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// raceRequest is the body of the requests made by the race script.
+type raceRequest struct {
+	Name   string      `json:"name"`
+	Token  string      `json:"token"`
+	Events []xwd.Event `json:"events"`
+}
+
+// serveNewRace creates a race on a puzzle, in response to a POST to its
+// "?race" URL, and redirects to the race.
+func (p *PuzzleServer) serveNewRace(w http.ResponseWriter, r *http.Request, name string, puz *xwd.Puzzle) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	id, room, err := p.races.add(name, puz)
+	if err == tooManyRaces {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if room.watch != "" {
+		logger.Printf("%s: race %s created, to be watched at /%s?race=%s&watch=%s", name, id, name, id, room.watch)
+	}
+	w.Header().Set("Location", path.Base(name)+"?race="+id)
+	w.WriteHeader(http.StatusCreated)
+}
+
+// serveRace handles the "?race=<id>" URL of a puzzle, which serves the solver
+// page for the race, and those with "&status", "&join", "&start" and
+// "&progress" added, which the page uses to take part in it. If spectating
+// is enabled, "&watch=<key>", with the key logged when the race was created,
+// serves the spectator page instead, which polls "&view" added to it.
+// Spectators can ask to be kept further behind than the server's delay with
+// "&delay=<s>".
+func (p *PuzzleServer) serveRace(w http.ResponseWriter, r *http.Request, name string, puz *xwd.Puzzle, id string) {
+	room := p.races.get(name, id)
+	if room == nil {
+		http.NotFound(w, r)
+		return
+	}
+	race := room.race
+	query := r.URL.Query()
+
+	if query.Has("watch") {
+		key := query.Get("watch")
+		if room.watch == "" || subtle.ConstantTimeCompare([]byte(key), []byte(room.watch)) != 1 {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+		if query.Has("view") {
+			delay := p.races.delay
+			if s, err := strconv.Atoi(query.Get("delay")); err == nil && time.Duration(s)*time.Second > delay {
+				delay = time.Duration(s) * time.Second
+			}
+			writeJSON(w, race.Spectate(delay))
+			return
+		}
+		view := path.Base(name) + "?race=" + id + "&watch=" + key + "&view"
+		if query.Has("delay") {
+			view += "&delay=" + url.QueryEscape(query.Get("delay"))
+		}
+		err := xwd.WriteSpectatorHTML(w, puz, view)
+		if err != nil {
+			logger.Println(err)
+		}
+		return
+	}
+
+	if query.Has("status") {
+		writeJSON(w, race.Status())
+		return
+	}
+	if !query.Has("join") && !query.Has("start") && !query.Has("progress") {
+		opts := xwd.HTMLOptions{Race: path.Base(name) + "?race=" + id}
+		err := xwd.WriteHTML(w, puz, opts)
+		if err != nil {
+			logger.Println(err)
+		}
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req raceRequest
+	err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRaceUpdateSize)).Decode(&req)
+	if err != nil {
+		http.Error(w, "Bad request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	switch {
+	case query.Has("join"):
+		token, err := race.Join(req.Name)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		writeJSON(w, map[string]string{"token": token})
+	case query.Has("start"):
+		if race.Player(req.Token) == "" {
+			http.Error(w, xwd.UnknownRacer.Error(), http.StatusForbidden)
+			return
+		}
+		if err := race.Start(); err != nil {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		logger.Printf("%s: race %s started", name, id)
+		writeJSON(w, race.Status())
+	case query.Has("progress"):
+		finished, err := race.Update(req.Token, req.Events)
+		switch {
+		case err == xwd.UnknownRacer:
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		case err == xwd.RaceNotStarted:
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		case err != nil:
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		st := race.Status()
+		if finished {
+			p.recordFinish(name, id, room, race.Player(req.Token), st)
+		}
+		writeJSON(w, st)
+	}
+}
+
+// raceResult is the result of a race, as written to the results directory.
+type raceResult struct {
+	Puzzle  string           `json:"puzzle"` // The path of the puzzle
+	Race    string           `json:"race"`   // The ID of the race
+	Created time.Time        `json:"created"`
+	Players []xwd.RacePlayer `json:"players"`
+	Winner  string           `json:"winner,omitempty"`
+	Over    bool             `json:"over"` // Whether every player has finished
+
+	// Replays are the IDs of the replays of the players' solves, by name, if
+	// recordings are enabled.
+	Replays map[string]string `json:"replays,omitempty"`
+}
+
+// recordFinish logs a player finishing a race, keeps a recording of their
+// solve if recordings are enabled, and writes the race's results so far to
+// the results directory, if there is one.
+func (p *PuzzleServer) recordFinish(name, id string, room *raceRoom, player string, st xwd.RaceStatus) {
+	for _, pl := range st.Players {
+		if pl.Name == player {
+			logger.Printf("%s: race %s: %s finished in %v, placing %d", name, id, player, time.Duration(pl.Finished)*time.Millisecond, pl.Place)
+		}
+	}
+	if st.Over {
+		logger.Printf("%s: race %s: won by %s", name, id, st.Winner)
+	}
+
+	room.mu.Lock()
+	defer room.mu.Unlock()
+	if p.replays != nil {
+		replay, err := p.replays.add(room.race.Recording(player))
+		if err != nil {
+			logger.Println(err)
+		} else {
+			room.replays[player] = replay
+		}
+	}
+	if p.races.results == "" {
+		return
+	}
+	// The status is fetched again, as another player may have finished
+	// meanwhile, and the results must not go back in time.
+	st = room.race.Status()
+	err := writeResult(filepath.Join(p.races.results, id+".json"), raceResult{
+		Puzzle:  name,
+		Race:    id,
+		Created: room.race.Created(),
+		Players: st.Players,
+		Winner:  st.Winner,
+		Over:    st.Over,
+		Replays: room.replays,
+	})
+	if err != nil {
+		logger.Println(err)
+	}
+}
+
+// writeResult writes a race's result to the named file, replacing any
+// earlier one atomically.
+func writeResult(name string, result raceResult) error {
+	data, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(filepath.Dir(name), 0755)
+	if err != nil {
+		return err
+	}
+	tmp := name + ".tmp"
+	err = ioutil.WriteFile(tmp, data, 0644)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp, name)
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	err := json.NewEncoder(w).Encode(v)
+	if err != nil {
+		logger.Println(err)
+	}
+}
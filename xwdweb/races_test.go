@@ -0,0 +1,39 @@
+package main
+
+import (
+	"os"
+	"testing"
+
+	"github.com/nickstenning/xwd"
+)
+
+func TestRaceRoomsAdd(t *testing.T) {
+	puz, err := xwd.LoadFS(os.DirFS("../fixtures"), "version_13.puz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr := newRaceRooms(false, 0, "")
+	ids := make(map[string]bool)
+	for i := 0; i < maxRaces; i++ {
+		id, _, err := rr.add("a.puz", puz)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(id) != 32 || ids[id] {
+			t.Fatalf("expected a new random ID, got: %q", id)
+		}
+		ids[id] = true
+	}
+	if _, _, err := rr.add("a.puz", puz); err != tooManyRaces {
+		t.Errorf("expectation failure (expected: %v, got: %v)", tooManyRaces, err)
+	}
+	for id := range ids {
+		if rr.get("a.puz", id) == nil {
+			t.Errorf("%s: expected the race to be found", id)
+		}
+		if rr.get("b.puz", id) != nil {
+			t.Errorf("%s: expected the race not to be found on another puzzle", id)
+		}
+		break
+	}
+}
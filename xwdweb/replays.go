@@ -0,0 +1,167 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/nickstenning/xwd"
+)
+
+// maxRecordingSize limits the size of the recordings that can be shared.
+const maxRecordingSize = 4 << 20
+
+var replayID = regexp.MustCompile(`^[0-9a-z]+$`)
+
+// replayStore keeps recordings of solves in a directory, beneath a
+// subdirectory for each puzzle named by its fingerprint.
+type replayStore struct {
+	dir string
+}
+
+// add stores a recording, returning its ID, which is random.
+func (s *replayStore) add(rec *xwd.Recording) (string, error) {
+	dir := filepath.Join(s.dir, rec.Puzzle)
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return "", err
+	}
+	id, err := randomKey()
+	if err != nil {
+		return "", err
+	}
+	// The file is created exclusively, so that a recording can never replace
+	// another.
+	f, err := os.OpenFile(filepath.Join(dir, id+".json"), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return "", err
+	}
+	err = xwd.WriteRecording(f, rec)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return id, err
+}
+
+// get returns the recording of the puzzle with the given ID.
+func (s *replayStore) get(puzzle, id string) (*xwd.Recording, error) {
+	if !replayID.MatchString(id) {
+		return nil, os.ErrNotExist
+	}
+	f, err := os.Open(filepath.Join(s.dir, puzzle, id+".json"))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return xwd.ReadRecording(f)
+}
+
+// storedReplay is a recording in a replayStore, with its ID.
+type storedReplay struct {
+	ID        string
+	Recording *xwd.Recording
+}
+
+// list returns the recordings of the puzzle, most recent first.
+func (s *replayStore) list(puzzle string) ([]storedReplay, error) {
+	names, err := filepath.Glob(filepath.Join(s.dir, puzzle, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	replays := make([]storedReplay, 0, len(names))
+	for _, name := range names {
+		id := strings.TrimSuffix(filepath.Base(name), ".json")
+		rec, err := s.get(puzzle, id)
+		if err != nil {
+			logger.Printf("%s: %v", name, err)
+			continue
+		}
+		replays = append(replays, storedReplay{id, rec})
+	}
+	sort.Slice(replays, func(i, j int) bool {
+		return replays[i].Recording.Start.After(replays[j].Recording.Start)
+	})
+	return replays, nil
+}
+
+// serveReplays handles the "?replays" URL of a puzzle: POSTing a recording
+// to it shares the recording, and GETting it lists those shared.
+func (p *PuzzleServer) serveReplays(w http.ResponseWriter, r *http.Request, name string, puz *xwd.Puzzle) {
+	switch r.Method {
+	case http.MethodPost:
+		rec, err := xwd.ReadRecording(http.MaxBytesReader(w, r.Body, maxRecordingSize))
+		if err != nil {
+			http.Error(w, "Bad recording: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if rec.Puzzle != puz.Fingerprint() {
+			http.Error(w, "The recording isn't of this puzzle", http.StatusBadRequest)
+			return
+		}
+		if _, err := rec.Replay(puz, rec.Duration()); err != nil {
+			http.Error(w, "Bad recording: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		id, err := p.replays.add(rec)
+		if err != nil {
+			logger.Println(err)
+			http.Error(w, "Couldn't store the recording", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Location", path.Base(name)+"?replay="+id)
+		w.WriteHeader(http.StatusCreated)
+	case http.MethodGet, http.MethodHead:
+		replays, err := p.replays.list(puz.Fingerprint())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		title := puz.Title
+		if title == "" {
+			title = path.Base(name)
+		}
+		idx := &xwd.Index{Title: "Replays of " + title, Parent: path.Base(name)}
+		for _, rp := range replays {
+			label := rp.Recording.Player
+			if label == "" {
+				label = "Anonymous"
+			}
+			label += fmt.Sprintf(", %s", rp.Recording.Start.Format("2 Jan 2006 15:04"))
+			label += fmt.Sprintf(" (%s)", rp.Recording.Duration().Round(time.Second))
+			if rp.Recording.Assisted {
+				label += " with autocheck"
+			}
+			idx.Dirs = append(idx.Dirs, xwd.IndexLink{Name: label, URL: path.Base(name) + "?replay=" + rp.ID})
+		}
+		err = xwd.WriteIndex(w, idx)
+		if err != nil {
+			logger.Println(err)
+		}
+	default:
+		w.Header().Set("Allow", "GET, HEAD, POST")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// serveReplay serves the page replaying a shared recording of a solve.
+func (p *PuzzleServer) serveReplay(w http.ResponseWriter, r *http.Request, puz *xwd.Puzzle, id string) {
+	rec, err := p.replays.get(puz.Fingerprint(), id)
+	if os.IsNotExist(err) {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	err = xwd.WriteReplayHTML(w, puz, rec)
+	if err != nil {
+		logger.Println(err)
+	}
+}
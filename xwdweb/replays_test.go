@@ -0,0 +1,26 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nickstenning/xwd"
+)
+
+func TestReplayStoreAdd(t *testing.T) {
+	s := &replayStore{dir: t.TempDir()}
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	for _, player := range []string{"Ann", "Bob"} {
+		_, err := s.add(&xwd.Recording{Puzzle: "abc", Player: player, Start: start, Events: []xwd.Event{}})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	replays, err := s.list("abc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(replays) != 2 || replays[0].ID == replays[1].ID {
+		t.Errorf("expected two recordings made at once to be kept apart, got: %+v", replays)
+	}
+}
@@ -0,0 +1,371 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nickstenning/xwd"
+)
+
+// submitGrace is how long after a round's deadline submissions are still
+// accepted, to allow for those the solver page makes itself at the deadline.
+// They earn no time bonus.
+const submitGrace = 30 * time.Second
+
+// tournamentCookie holds the token identifying a registered player.
+const tournamentCookie = "xwd-tournament"
+
+// tournamentServer hosts a tournament, at /tournament/. Its progress (the
+// players registered and their submissions) is kept in a state file, so that
+// it survives restarts.
+type tournamentServer struct {
+	mu      sync.Mutex
+	t       *xwd.Tournament
+	puzzles map[string]*xwd.Puzzle // By file name
+	state   string                 // The name of the state file
+	csrf    string                 // The token the judging page POSTs with
+	tournamentState
+}
+
+type tournamentState struct {
+	Players     map[string]string `json:"players"` // Names by token
+	Submissions []*xwd.Submission `json:"submissions"`
+}
+
+// newTournamentServer loads the tournament described in the named file, with
+// its puzzles, and any progress saved in its state file.
+func newTournamentServer(name string) (*tournamentServer, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	t, err := xwd.ReadTournament(f)
+	f.Close()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+	ts := &tournamentServer{
+		t:       t,
+		puzzles: make(map[string]*xwd.Puzzle),
+		state:   name + ".state",
+		tournamentState: tournamentState{
+			Players:     make(map[string]string),
+			Submissions: make([]*xwd.Submission, 0),
+		},
+	}
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+	ts.csrf = hex.EncodeToString(b)
+	dir := os.DirFS(filepath.Dir(name))
+	for _, round := range t.Rounds {
+		for _, pname := range round.Puzzles {
+			ts.puzzles[pname], err = xwd.LoadFS(dir, pname)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %v", pname, err)
+			}
+		}
+	}
+
+	data, err := ioutil.ReadFile(ts.state)
+	if os.IsNotExist(err) {
+		return ts, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	err = json.Unmarshal(data, &ts.tournamentState)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", ts.state, err)
+	}
+	// The rounds may have been changed since the state was saved, leaving
+	// submissions to puzzles that are no longer in the tournament.
+	for i, sub := range ts.Submissions {
+		if sub.Round < 0 || sub.Round >= len(t.Rounds) || sub.Puzzle < 0 || sub.Puzzle >= len(t.Rounds[sub.Round].Puzzles) {
+			return nil, fmt.Errorf("%s: submission %d is to round %d, puzzle %d, which isn't in the tournament", ts.state, i, sub.Round+1, sub.Puzzle+1)
+		}
+	}
+	return ts, nil
+}
+
+// save writes the tournament's progress to its state file. The caller must
+// hold ts.mu.
+func (ts *tournamentServer) save() error {
+	data, err := json.MarshalIndent(ts.tournamentState, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := ts.state + ".tmp"
+	err = ioutil.WriteFile(tmp, data, 0644)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp, ts.state)
+}
+
+// ServeHTTP serves the tournament's front page at /tournament/, the solver
+// page for each puzzle at /tournament/<round>/<puzzle> (both numbered from
+// 1), the standings as JSON at /tournament/standings.json, and the judging
+// page at /tournament/admin.
+func (ts *tournamentServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rel := strings.TrimPrefix(r.URL.Path, "/tournament/")
+	switch rel {
+	case "":
+		ts.serveFront(w, r)
+	case "register":
+		ts.serveRegister(w, r)
+	case "standings.json":
+		ts.mu.Lock()
+		standings := ts.standings()
+		ts.mu.Unlock()
+		writeJSON(w, standings)
+	case "admin":
+		ts.serveJudging(w, r)
+	default:
+		var round, puzzle int
+		_, err := fmt.Sscanf(rel, "%d/%d", &round, &puzzle)
+		if err != nil || rel != fmt.Sprintf("%d/%d", round, puzzle) ||
+			round < 1 || round > len(ts.t.Rounds) || puzzle < 1 || puzzle > len(ts.t.Rounds[round-1].Puzzles) {
+			http.NotFound(w, r)
+			return
+		}
+		ts.servePuzzle(w, r, round-1, puzzle-1)
+	}
+}
+
+// player returns the name of the player making the request, or "" if they
+// haven't registered. The caller must hold ts.mu.
+func (ts *tournamentServer) player(r *http.Request) string {
+	c, err := r.Cookie(tournamentCookie)
+	if err != nil {
+		return ""
+	}
+	return ts.Players[c.Value]
+}
+
+// submission returns the player's submission of a puzzle, or nil if there's
+// none. The caller must hold ts.mu.
+func (ts *tournamentServer) submission(player string, round, puzzle int) *xwd.Submission {
+	for _, sub := range ts.Submissions {
+		if sub.Player == player && sub.Round == round && sub.Puzzle == puzzle {
+			return sub
+		}
+	}
+	return nil
+}
+
+// standings returns the standings of the players. The caller must hold ts.mu.
+func (ts *tournamentServer) standings() []xwd.Standing {
+	players := make([]string, 0, len(ts.Players))
+	for _, name := range ts.Players {
+		players = append(players, name)
+	}
+	return ts.t.Standings(players, ts.Submissions)
+}
+
+func (ts *tournamentServer) title(name string) string {
+	if p := ts.puzzles[name]; p.Title != "" {
+		return p.Title
+	}
+	return name
+}
+
+func (ts *tournamentServer) serveFront(w http.ResponseWriter, r *http.Request) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	page := &xwd.TournamentPage{
+		Tournament: ts.t,
+		Now:        time.Now(),
+		Player:     ts.player(r),
+		Register:   "register",
+		Standings:  ts.standings(),
+		Refresh:    30,
+	}
+	for k, round := range ts.t.Rounds {
+		tr := xwd.TournamentRound{Round: round}
+		for n, name := range round.Puzzles {
+			tp := xwd.TournamentPuzzle{Title: ts.title(name)}
+			if sub := ts.submission(page.Player, k, n); sub != nil {
+				tp.Score = &sub.Score
+			} else if page.Player != "" && round.Open(page.Now) {
+				tp.URL = fmt.Sprintf("%d/%d", k+1, n+1)
+			}
+			tr.Puzzles = append(tr.Puzzles, tp)
+		}
+		page.Rounds = append(page.Rounds, tr)
+	}
+	err := xwd.WriteTournamentHTML(w, page)
+	if err != nil {
+		logger.Println(err)
+	}
+}
+
+func (ts *tournamentServer) serveRegister(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	name := strings.TrimSpace(r.FormValue("name"))
+	if name == "" {
+		http.Error(w, "You must give a name", http.StatusBadRequest)
+		return
+	}
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	for _, other := range ts.Players {
+		if strings.EqualFold(other, name) {
+			http.Error(w, fmt.Sprintf("There's already a player called %q", other), http.StatusConflict)
+			return
+		}
+	}
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	token := hex.EncodeToString(b)
+	ts.Players[token] = name
+	if err := ts.save(); err != nil {
+		logger.Println(err)
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     tournamentCookie,
+		Value:    token,
+		Path:     "/tournament/",
+		Expires:  time.Now().AddDate(1, 0, 0),
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.Redirect(w, r, "./", http.StatusSeeOther)
+}
+
+// servePuzzle serves the solver page for a puzzle of an open round, and
+// accepts its submission.
+func (ts *tournamentServer) servePuzzle(w http.ResponseWriter, r *http.Request, round, puzzle int) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	player := ts.player(r)
+	if player == "" {
+		http.Error(w, "You must register to take part", http.StatusForbidden)
+		return
+	}
+	rd := ts.t.Rounds[round]
+	p := ts.puzzles[rd.Puzzles[puzzle]]
+	now := time.Now()
+
+	if r.URL.Query().Has("submit") {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", "POST")
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if now.Before(rd.Start) || now.After(rd.Deadline().Add(submitGrace)) {
+			http.Error(w, "The round is closed", http.StatusForbidden)
+			return
+		}
+		if ts.submission(player, round, puzzle) != nil {
+			http.Error(w, "Already submitted", http.StatusConflict)
+			return
+		}
+		var req struct {
+			Grid [][]string `json:"grid"`
+		}
+		err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRaceUpdateSize)).Decode(&req)
+		if err != nil {
+			http.Error(w, "Bad submission: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		sub := &xwd.Submission{Player: player, Round: round, Puzzle: puzzle, Time: now, Grid: req.Grid}
+		err = ts.t.Score(sub, p)
+		if err != nil {
+			http.Error(w, "Bad submission: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		ts.Submissions = append(ts.Submissions, sub)
+		if err := ts.save(); err != nil {
+			logger.Println(err)
+		}
+		logger.Printf("tournament: %s submitted %s (%d points)", player, rd.Puzzles[puzzle], sub.Score.Points)
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprintln(w, "Submitted")
+		return
+	}
+
+	if !rd.Open(now) || ts.submission(player, round, puzzle) != nil {
+		http.Redirect(w, r, "../", http.StatusSeeOther)
+		return
+	}
+	opts := xwd.HTMLOptions{Submit: strconv.Itoa(puzzle+1) + "?submit", Deadline: rd.Deadline()}
+	err := xwd.WriteHTML(w, p, opts)
+	if err != nil {
+		logger.Println(err)
+	}
+}
+
+// serveJudging serves the judging page, which is protected by the
+// tournament's admin password, and accepts the judges' decisions. Since the
+// browser sends the password with any request, even one made by another site,
+// decisions must also carry the token given in the page.
+func (ts *tournamentServer) serveJudging(w http.ResponseWriter, r *http.Request) {
+	_, password, ok := r.BasicAuth()
+	if ts.t.Admin == "" || !ok || subtle.ConstantTimeCompare([]byte(password), []byte(ts.t.Admin)) != 1 {
+		w.Header().Set("WWW-Authenticate", `Basic realm="xwd tournament"`)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	if r.Method == http.MethodPost {
+		if subtle.ConstantTimeCompare([]byte(r.PostFormValue("csrf")), []byte(ts.csrf)) != 1 {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+		id, err := strconv.Atoi(r.FormValue("submission"))
+		var i, j int
+		if err == nil {
+			_, err = fmt.Sscanf(r.FormValue("cell"), "%d,%d", &i, &j)
+		}
+		if err != nil || id < 0 || id >= len(ts.Submissions) {
+			http.Error(w, "Bad judgement", http.StatusBadRequest)
+			return
+		}
+		sub := ts.Submissions[id]
+		sub.Accept(i, j)
+		err = ts.t.Score(sub, ts.puzzles[ts.t.Rounds[sub.Round].Puzzles[sub.Puzzle]])
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if err := ts.save(); err != nil {
+			logger.Println(err)
+		}
+		http.Redirect(w, r, fmt.Sprintf("admin#submission-%d", id), http.StatusSeeOther)
+		return
+	}
+
+	page := &xwd.JudgingPage{Tournament: ts.t, Judge: "admin", CSRF: ts.csrf, Standings: ts.standings()}
+	for id, sub := range ts.Submissions {
+		p := ts.puzzles[ts.t.Rounds[sub.Round].Puzzles[sub.Puzzle]]
+		js := xwd.NewJudgedSubmission(id, sub, p)
+		js.Title = ts.title(ts.t.Rounds[sub.Round].Puzzles[sub.Puzzle])
+		page.Submissions = append(page.Submissions, js)
+	}
+	err := xwd.WriteJudgingHTML(w, page)
+	if err != nil {
+		logger.Println(err)
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/nickstenning/xwd"
+)
+
+func TestServeJudgingCSRF(t *testing.T) {
+	puz, err := ioutil.ReadFile("../fixtures/version_13.puz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	if err := ioutil.WriteFile(filepath.Join(dir, "r1.puz"), puz, 0644); err != nil {
+		t.Fatal(err)
+	}
+	tournament := `{"title": "Test", "admin": "secret", "rounds": [
+		{"name": "Round 1", "start": "2026-04-01T10:00:00Z", "limit": "20m", "puzzles": ["r1.puz"]}
+	]}`
+	name := filepath.Join(dir, "tournament.json")
+	if err := ioutil.WriteFile(name, []byte(tournament), 0644); err != nil {
+		t.Fatal(err)
+	}
+	ts, err := newTournamentServer(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := ts.puzzles["r1.puz"]
+	grid := make([][]string, p.Rows)
+	for i := range grid {
+		grid[i] = make([]string, p.Cols)
+	}
+	ts.Submissions = append(ts.Submissions, &xwd.Submission{Player: "Ann", Grid: grid})
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/tournament/admin", nil)
+	req.SetBasicAuth("admin", "secret")
+	ts.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), ts.csrf) {
+		t.Errorf("expected the judging page to carry the token, got: %d", rec.Code)
+	}
+
+	tests := []struct {
+		csrf     string
+		expected int
+	}{
+		{"", http.StatusForbidden},
+		{"wrong", http.StatusForbidden},
+		{ts.csrf, http.StatusSeeOther},
+	}
+	for _, tt := range tests {
+		form := url.Values{"csrf": {tt.csrf}, "submission": {"0"}, "cell": {"0,0"}}
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/tournament/admin", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.SetBasicAuth("admin", "secret")
+		ts.ServeHTTP(rec, req)
+		if rec.Code != tt.expected {
+			t.Errorf("%q: expectation failure (expected: %d, got: %d)", tt.csrf, tt.expected, rec.Code)
+		}
+	}
+}
+
+func TestTournamentStateRounds(t *testing.T) {
+	puz, err := ioutil.ReadFile("../fixtures/version_13.puz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	if err := ioutil.WriteFile(filepath.Join(dir, "r1.puz"), puz, 0644); err != nil {
+		t.Fatal(err)
+	}
+	tournament := `{"title": "Test", "rounds": [
+		{"name": "Round 1", "start": "2026-04-01T10:00:00Z", "limit": "20m", "puzzles": ["r1.puz"]}
+	]}`
+	name := filepath.Join(dir, "tournament.json")
+	if err := ioutil.WriteFile(name, []byte(tournament), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// A submission to a second round, since removed.
+	state := `{"players": {"abc": "Ann"}, "submissions": [{"player": "Ann", "round": 1, "puzzle": 0}]}`
+	if err := ioutil.WriteFile(name+".state", []byte(state), 0644); err != nil {
+		t.Fatal(err)
+	}
+	_, err = newTournamentServer(name)
+	if err == nil || !strings.Contains(err.Error(), "round 2, puzzle 1") {
+		t.Errorf("expected an error about the missing round, got: %v", err)
+	}
+}
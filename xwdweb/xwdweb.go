@@ -1,67 +1,153 @@
 package main
 
 import (
+	"errors"
 	"flag"
 	"fmt"
-	"html/template"
-	"io/ioutil"
+	"io/fs"
 	"log"
 	"net/http"
 	"os"
 	"path"
+	"path/filepath"
 	"strings"
 
 	"github.com/nickstenning/xwd"
 )
 
-var f = template.FuncMap{
-	"inc":       func(n int) int { return n + 1 },
-	"isnumcell": func(cell *xwd.Cell) bool { return cell.Num != -1 },
-}
-var t = template.Must(template.New("").Funcs(f).ParseFiles("puzzle.tpl"))
-
+var check = flag.Bool("check", false, "allow solvers to check their answers")
+var racing = flag.Bool("races", false, "allow solvers to race each other")
+var spectate = flag.Bool("spectate", false, "allow races to be watched (the URL to watch each race is logged when it's created)")
+var spectatorDelay = flag.Duration("spectator-delay", 0, "how far behind the players spectators see races, to stop them passing on answers")
+var tournament = flag.String("tournament", "", "host the tournament described in this JSON file at /tournament/ (its puzzles are loaded from the file's directory, and its progress is kept in <file>.state)")
+var results = flag.String("results", "", "directory in which to keep the results of races (by default, a \"races\" directory among the recordings)")
+var recordings = flag.String("recordings", "", "directory in which to keep recordings of solves shared by solvers, enabling replays")
 var logger = log.New(os.Stderr, "xwdweb: ", log.LstdFlags)
 
 type PuzzleServer struct {
-	puzzleRoot string
-	upstream   http.Handler
+	fsys     fs.FS
+	upstream http.Handler
+	replays  *replayStore // Where shared recordings are kept, or nil if sharing is disabled
+	races    *raceRooms   // The races in progress, or nil if racing is disabled
 }
 
+// NewPuzzleServer returns a server for the puzzles beneath puzzleRoot. Zip and
+// tar archives in the tree are served as directories.
 func NewPuzzleServer(puzzleRoot string) *PuzzleServer {
-	p := &PuzzleServer{puzzleRoot: puzzleRoot}
-	p.upstream = http.FileServer(http.Dir(puzzleRoot))
+	p := &PuzzleServer{fsys: newArchiveFS(os.DirFS(puzzleRoot))}
+	p.upstream = http.FileServer(http.FS(p.fsys))
 	return p
 }
 
+// ServeHTTP serves an index page for directories and a solver page for
+// puzzles. Adding "?print" to a puzzle URL gives a printable version instead,
+// and "?download" the original file. If recordings are enabled, "?replays"
+// lists the solves shared (and accepts new ones), and "?replay=<id>" replays
+// one. If racing is enabled, POSTing to "?race" creates a race, which is run
+// at "?race=<id>" (and, if spectating is enabled, can be watched at
+// "?race=<id>&watch=<key>"). Everything else is served as is.
 func (p *PuzzleServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	if !strings.HasSuffix(r.URL.Path, ".puz") {
+	name := strings.TrimPrefix(path.Clean("/"+r.URL.Path), "/")
+	if name == "" {
+		name = "."
+	}
+	query := r.URL.Query()
+
+	if info, err := fs.Stat(p.fsys, name); err == nil && info.IsDir() {
+		if !strings.HasSuffix(r.URL.Path, "/") {
+			http.Redirect(w, r, r.URL.Path+"/", http.StatusMovedPermanently)
+			return
+		}
+		p.serveIndex(w, r, name)
+		return
+	}
+
+	if !strings.HasSuffix(r.URL.Path, ".puz") || query.Has("download") {
 		p.upstream.ServeHTTP(w, r)
+		return
 	}
 
-	puz, err := loadPuzzle(path.Join(p.puzzleRoot, r.URL.Path))
+	puz, err := xwd.LoadFS(p.fsys, name)
+	if errors.Is(err, fs.ErrNotExist) {
+		http.NotFound(w, r)
+		return
+	}
 	if err != nil {
-		w.Write([]byte("Puzzle failed to load: " + err.Error()))
+		http.Error(w, "Puzzle failed to load: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	t.ExecuteTemplate(w, "puzzle.tpl", puz)
-}
+	if p.replays != nil && query.Has("replays") {
+		p.serveReplays(w, r, name, puz)
+		return
+	}
+	if p.replays != nil && query.Has("replay") {
+		p.serveReplay(w, r, puz, query.Get("replay"))
+		return
+	}
 
-func loadPuzzle(path string) (*xwd.Puzzle, error) {
-	f, err := os.Open(path)
+	if p.races != nil && query.Has("race") {
+		if id := query.Get("race"); id != "" {
+			p.serveRace(w, r, name, puz, id)
+		} else {
+			p.serveNewRace(w, r, name, puz)
+		}
+		return
+	}
+
+	opts := xwd.HTMLOptions{Check: *check, Print: query.Has("print")}
+	if p.replays != nil {
+		opts.Share = path.Base(name) + "?replays"
+	}
+	if p.races != nil {
+		opts.NewRace = path.Base(name) + "?race"
+	}
+	err = xwd.WriteHTML(w, puz, opts)
 	if err != nil {
-		return nil, err
+		logger.Println(err)
 	}
-	data, err := ioutil.ReadAll(f)
+}
+
+func (p *PuzzleServer) serveIndex(w http.ResponseWriter, r *http.Request, dir string) {
+	entries, err := fs.ReadDir(p.fsys, dir)
 	if err != nil {
-		return nil, err
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
 	}
-	puz := &xwd.Puzzle{}
-	err = puz.Load(data)
+
+	idx := &xwd.Index{Title: r.URL.Path}
+	if r.URL.Path != "/" {
+		idx.Parent = "../"
+	}
+	for _, e := range entries {
+		name := e.Name()
+		if strings.HasPrefix(name, ".") {
+			continue
+		}
+		if e.IsDir() {
+			idx.Dirs = append(idx.Dirs, xwd.IndexLink{Name: name, URL: name + "/"})
+			continue
+		}
+		if !strings.HasSuffix(name, ".puz") {
+			continue
+		}
+		ip := xwd.IndexPuzzle{
+			Name:      name,
+			Solve:     name,
+			Print:     name + "?print",
+			Downloads: []xwd.IndexLink{{Name: "puz", URL: name + "?download"}},
+		}
+		ip.Puzzle, err = xwd.LoadFS(p.fsys, path.Join(dir, name))
+		if err != nil {
+			logger.Printf("%s: %v", path.Join(dir, name), err)
+		}
+		idx.Puzzles = append(idx.Puzzles, ip)
+	}
+
+	err = xwd.WriteIndex(w, idx)
 	if err != nil {
-		return nil, err
+		logger.Println(err)
 	}
-	return puz, nil
 }
 
 func usage() {
@@ -83,8 +169,28 @@ func main() {
 
 	puzzles := flag.Arg(0)
 	server := NewPuzzleServer(puzzles)
+	if *recordings != "" {
+		server.replays = &replayStore{dir: *recordings}
+	}
+	if *racing {
+		dir := *results
+		if dir == "" && *recordings != "" {
+			dir = filepath.Join(*recordings, "races")
+		}
+		if dir == "" {
+			logger.Println("warning: the results of races will only be logged, as neither -results nor -recordings was given")
+		}
+		server.races = newRaceRooms(*spectate, *spectatorDelay, dir)
+	}
 
 	http.Handle("/", server)
+	if *tournament != "" {
+		ts, err := newTournamentServer(*tournament)
+		if err != nil {
+			logger.Fatal(err)
+		}
+		http.Handle("/tournament/", ts)
+	}
 
 	port := os.Getenv("PORT")
 	if len(port) == 0 {